@@ -0,0 +1,83 @@
+package ppmerge
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/threadedstream/ppmerge/profile"
+)
+
+// WithSampleAggregation makes merger sum values of samples of the same entry which have identical
+// stacks and labels, so that every entry holds just one sample of each. Unpacked profiles are
+// semantically equal to the inputs, but have fewer samples, and archives get smaller.
+func WithSampleAggregation() MergerOption {
+	return func(pw *ProfileMerger) {
+		pw.aggregateSamples = true
+	}
+}
+
+// aggregatedSample is a sample of the current entry identical samples are added to
+type aggregatedSample struct {
+	offset uint64
+	// values of merged sample are shared with input until the first identical sample is added
+	ownsValue bool
+}
+
+// putSample appends sample having labels to merged profile. If samples are aggregated and
+// the current entry has identical sample already, values of sample are added to it instead.
+func (pw *ProfileMerger) putSample(sample *MergeSample, labels *profile.Labels) {
+	mp := pw.mergedProfile
+	if pw.aggregateSamples {
+		key := getSampleKey(sample, labels)
+		if aggregated, ok := pw.entrySamples[key]; ok {
+			existing := mp.Samples[aggregated.offset]
+			if !aggregated.ownsValue {
+				existing.Value = append(make([]int64, 0, len(existing.Value)), existing.Value...)
+				pw.entrySamples[key] = aggregatedSample{offset: aggregated.offset, ownsValue: true}
+			}
+			for i, v := range sample.Value {
+				if i < len(existing.Value) {
+					existing.Value[i] += v
+				}
+			}
+			return
+		}
+
+		if pw.entrySamples == nil {
+			pw.entrySamples = make(map[string]aggregatedSample)
+		}
+		pw.entrySamples[key] = aggregatedSample{offset: uint64(len(mp.Samples))}
+	}
+
+	mp.Samples = append(mp.Samples, sample)
+	if labels != nil {
+		if mp.Labels == nil {
+			mp.Labels = make(map[uint64]*profile.Labels)
+		}
+		mp.Labels[uint64(len(mp.Samples)-1)] = labels
+	}
+}
+
+// getSampleKey returns key of sample having labels, identical samples have equal keys
+func getSampleKey(sample *MergeSample, labels *profile.Labels) string {
+	var sb strings.Builder
+	for _, locID := range sample.LocationId {
+		sb.WriteString(strconv.FormatInt(locID, 16))
+		sb.WriteByte('|')
+	}
+	// number of values is a part of key, so that samples of different length are never added up
+	sb.WriteString(strconv.Itoa(len(sample.Value)))
+	if labels != nil {
+		for _, label := range labels.Labels {
+			sb.WriteByte(';')
+			sb.WriteString(strconv.FormatInt(label.Key, 16))
+			sb.WriteByte(',')
+			sb.WriteString(strconv.FormatInt(label.Str, 16))
+			sb.WriteByte(',')
+			sb.WriteString(strconv.FormatInt(label.Num, 16))
+			sb.WriteByte(',')
+			sb.WriteString(strconv.FormatInt(label.NumUnit, 16))
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,95 @@
+package ppmerge
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/threadedstream/ppmerge/profile"
+	"google.golang.org/protobuf/proto"
+)
+
+// duplicatedProfiles returns profiles of paths having every sample repeated twice
+func duplicatedProfiles(t *testing.T, paths ...string) []*profile.Profile {
+	ps := getProfilesVtProto(t, false, paths...)
+	for _, p := range ps {
+		p.Sample = append(p.Sample, p.Sample...)
+	}
+	return ps
+}
+
+func TestSampleAggregation(t *testing.T) {
+	paths := []string{"hprof1", "labels.prof", "parca_cpu", "hprof2"}
+	profiles := duplicatedProfiles(t, paths...)
+	values := make([][]int64, 0, len(profiles[0].Sample))
+	for _, s := range profiles[0].Sample {
+		values = append(values, slices.Clone(s.Value))
+	}
+
+	expected := NewProfileMerger().Merge(profiles...)
+	aggregated := NewProfileMerger(WithSampleAggregation()).Merge(profiles...)
+	for idx, p := range profiles {
+		require.LessOrEqual(t, aggregated.NumSamples[idx], uint64(len(p.Sample)/2), paths[idx])
+	}
+	require.Len(t, aggregated.Samples, int(sum(aggregated.NumSamples)))
+
+	// repeated samples add nothing but values
+	once := NewProfileMerger(WithSampleAggregation()).Merge(getProfilesVtProto(t, false, paths...)...)
+	require.Equal(t, once.NumSamples, aggregated.NumSamples)
+	require.Len(t, aggregated.Labels, len(once.Labels))
+
+	// inputs are left intact
+	for i, s := range profiles[0].Sample {
+		require.Equal(t, values[i], s.Value)
+	}
+
+	requireSameTotals(t, expected, aggregated)
+
+	for _, workers := range []int{1, 4} {
+		actual := NewProfileMerger(WithSampleAggregation()).MergeParallel(workers, profiles...)
+		require.True(t, proto.Equal(aggregated, actual), "workers %d", workers)
+	}
+
+	bb := bytes.NewBuffer(nil)
+	sm := NewStreamMerger(bb, WithSampleAggregation())
+	for _, p := range profiles {
+		require.NoError(t, sm.Add(p))
+	}
+	require.NoError(t, sm.Close())
+	streamed := new(MergedProfile)
+	require.NoError(t, streamed.UnmarshalVT(bb.Bytes()))
+	require.Equal(t, aggregated.NumSamples, streamed.NumSamples)
+	require.Len(t, streamed.Labels, len(aggregated.Labels))
+	requireSameTotals(t, expected, streamed)
+
+	plain, err := expected.MarshalDeterministic()
+	require.NoError(t, err)
+	smaller, err := aggregated.MarshalDeterministic()
+	require.NoError(t, err)
+	require.Less(t, len(smaller), len(plain))
+}
+
+// requireSameTotals checks that every entry of actual has the same stacks and values as in expected
+func requireSameTotals(t *testing.T, expected, actual *MergedProfile) {
+	require.Equal(t, len(expected.NumSamples), len(actual.NumSamples))
+
+	expectedUnpacker := NewProfileUnPacker(expected)
+	actualUnpacker := NewProfileUnPacker(actual)
+	for idx := range expected.NumSamples {
+		expectedProfile, err := expectedUnpacker.Unpack(uint64(idx))
+		require.NoError(t, err)
+		actualProfile, err := actualUnpacker.Unpack(uint64(idx))
+		require.NoError(t, err)
+		require.NoError(t, actualProfile.CheckValid())
+		require.Equal(t, stackTotals(expectedProfile), stackTotals(actualProfile), "entry %d", idx)
+	}
+}
+
+func sum(ns []uint64) uint64 {
+	var total uint64
+	for _, n := range ns {
+		total += n
+	}
+	return total
+}
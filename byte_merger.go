@@ -8,6 +8,10 @@ import (
 	"google.golang.org/protobuf/proto"
 )
 
+// ByteProfileMerger stores several raw profiles in a single one.
+//
+// MergedByteProfile returned by Merge is owned by the merger: it stays valid until the next
+// call to Merge, Reset or Release. It references inputs without copying them.
 type ByteProfileMerger struct {
 	mergedProfile *MergedByteProfile
 }
@@ -18,6 +22,20 @@ func NewByteProfileMerger() *ByteProfileMerger {
 	}
 }
 
+// Reset clears merged profile, so that merger can be reused. It may also be called after Release.
+func (bm *ByteProfileMerger) Reset() {
+	if bm.mergedProfile == nil {
+		bm.mergedProfile = new(MergedByteProfile)
+	}
+	bm.mergedProfile.Reset()
+}
+
+// Release drops merged profile. MergedByteProfile isn't pooled, so it only
+// lets inputs be garbage collected. Merger must not be used afterwards until Reset is called.
+func (bm *ByteProfileMerger) Release() {
+	bm.mergedProfile = nil
+}
+
 func (bm *ByteProfileMerger) Merge(profiles ...[]byte) *MergedByteProfile {
 	bm.mergedProfile.Profiles = make([][]byte, len(profiles))
 	for i, p := range profiles {
@@ -52,6 +70,7 @@ func (bm *ByteProfileMerger) WriteUncompressed(w io.Writer) error {
 // ByteProfileUnPacker is the unpacker for MergedByteProfile
 type ByteProfileUnPacker struct {
 	mergedProfile *MergedByteProfile
+	ownsProfile   bool
 }
 
 // NewByteProfileUnPacker returns new ByteProfileUnPacker instance
@@ -61,6 +80,19 @@ func NewByteProfileUnPacker(mergedProfile *MergedByteProfile) *ByteProfileUnPack
 	}
 }
 
+// Reset clears merged profile decoded by UnpackRaw
+func (pu *ByteProfileUnPacker) Reset() {
+	if pu.ownsProfile {
+		pu.mergedProfile.Reset()
+	}
+}
+
+// Release drops reference to merged profile
+func (pu *ByteProfileUnPacker) Release() {
+	pu.mergedProfile = nil
+	pu.ownsProfile = false
+}
+
 func (pu *ByteProfileUnPacker) UnpackRaw(compressedRawProfile []byte, idx uint64) ([]byte, error) {
 	bb := bytes.NewBuffer(compressedRawProfile)
 
@@ -76,6 +108,7 @@ func (pu *ByteProfileUnPacker) UnpackRaw(compressedRawProfile []byte, idx uint64
 
 	if pu.mergedProfile == nil {
 		pu.mergedProfile = new(MergedByteProfile)
+		pu.ownsProfile = true
 	}
 
 	if err = pu.mergedProfile.UnmarshalVT(rawProfile); err != nil {
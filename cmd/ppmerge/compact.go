@@ -0,0 +1,81 @@
+package main
+
+import (
+	"compress/gzip"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/threadedstream/ppmerge"
+)
+
+func compact(args []string, w io.Writer) error {
+	fs := flag.NewFlagSet("compact", flag.ContinueOnError)
+	nodeFraction := fs.Float64("nodefraction", 0, "drop samples below this fraction of entry's total")
+	output := fs.String("o", "", "path of compacted archive")
+	dictionaries := fs.String("dictionaries", "", "directory of symbol dictionaries archive refers to")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 || *output == "" {
+		return errors.New("usage: ppmerge compact [-nodefraction f] [-dictionaries dir] -o output archive")
+	}
+	resolver, err := dictionaryDir(*dictionaries)
+	if err != nil {
+		return err
+	}
+
+	path := fs.Arg(0)
+	data, err := readArchive(path)
+	if err != nil {
+		return errors.Wrapf(err, "read %s", path)
+	}
+	mergedProfile := new(ppmerge.MergedProfile)
+	if err = mergedProfile.UnmarshalVT(data); err != nil {
+		return errors.Wrapf(err, "unmarshal %s", path)
+	}
+	if mergedProfile.Dictionary != "" {
+		// compacted archive holds tables of dictionary, so that it's readable on its own
+		if mergedProfile, err = mergedProfile.Decoded(resolver); err != nil {
+			return errors.Wrapf(err, "decode %s", path)
+		}
+	}
+
+	compacted, res, err := mergedProfile.Compacted(*nodeFraction)
+	if err != nil {
+		return errors.Wrapf(err, "compact %s", path)
+	}
+	if err = writeCompressed(*output, compacted); err != nil {
+		return errors.Wrapf(err, "write %s", *output)
+	}
+
+	_, err = fmt.Fprintf(w, "%s: folded %d and pruned %d of %d samples, %d -> %d bytes compressed (saved %s)\n",
+		path, res.FoldedSamples, res.PrunedSamples, res.Samples, res.CompressedSize, res.CompactedCompressedSize,
+		percent(res.Saved(), res.CompressedSize))
+	return err
+}
+
+// writeCompressed writes mergedProfile to path the way WriteCompressed does
+func writeCompressed(path string, mergedProfile *ppmerge.MergedProfile) error {
+	data, err := mergedProfile.MarshalDeterministic()
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(file)
+	if _, err = zw.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err = zw.Close(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
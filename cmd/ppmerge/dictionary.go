@@ -0,0 +1,47 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"github.com/threadedstream/ppmerge"
+)
+
+// dictionaryDir returns resolver of symbol dictionaries stored in dir, every file of which holds
+// one dictionary marshaled by MarshalVT. Dictionaries are found by their keys rather than file
+// names, as keys hold slashes. Resolver is nil if dir is empty.
+func dictionaryDir(dir string) (ppmerge.DictionaryResolver, error) {
+	if dir == "" {
+		return nil, nil
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, errors.Wrap(err, "read dictionaries")
+	}
+	dicts := make(map[string]*ppmerge.SymbolDictionary, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, errors.Wrap(err, "read dictionary")
+		}
+		dict := new(ppmerge.SymbolDictionary)
+		if err = dict.UnmarshalVT(data); err != nil {
+			return nil, errors.Wrapf(err, "unmarshal dictionary %s", path)
+		}
+		dicts[dict.Key] = dict
+	}
+
+	return ppmerge.DictionaryResolverFunc(func(key string) (*ppmerge.SymbolDictionary, error) {
+		dict, ok := dicts[key]
+		if !ok {
+			return nil, errors.Errorf("no dictionary %s in %s", key, dir)
+		}
+		return dict, nil
+	}), nil
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/pkg/errors"
+	"github.com/threadedstream/ppmerge"
+)
+
+func inspect(args []string, w io.Writer) error {
+	fs := flag.NewFlagSet("inspect", flag.ContinueOnError)
+	format := fs.String("format", "proto", "archive format: proto, goroutine or raw")
+	entries := fs.Bool("entries", false, "print number of samples of every entry")
+	asJSON := fs.Bool("json", false, "print statistics as JSON")
+	dictionaries := fs.String("dictionaries", "", "directory of symbol dictionaries archives refer to")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return errors.New("usage: ppmerge inspect [-format proto|goroutine|raw] [-entries] [-json] [-dictionaries dir] archive...")
+	}
+	resolver, err := dictionaryDir(*dictionaries)
+	if err != nil {
+		return err
+	}
+
+	for _, path := range fs.Args() {
+		stats, err := archiveStats(path, *format, resolver)
+		if err != nil {
+			return errors.Wrapf(err, "inspect %s", path)
+		}
+
+		if *asJSON {
+			err = json.NewEncoder(w).Encode(struct {
+				Archive string
+				*ppmerge.Stats
+			}{path, stats})
+		} else {
+			err = writeStats(w, path, stats, *entries)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// readArchive reads archive at path, both gzipped and plain archives are accepted
+func readArchive(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if bytes.HasPrefix(data, []byte{0x1f, 0x8b}) {
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, errors.Wrap(err, "decompress")
+		}
+		if data, err = io.ReadAll(zr); err != nil {
+			return nil, errors.Wrap(err, "decompress")
+		}
+	}
+	return data, nil
+}
+
+// archiveStats returns statistics of archive at path, archive referring to symbol dictionary
+// is checked to resolve if resolver is given
+func archiveStats(path, format string, resolver ppmerge.DictionaryResolver) (*ppmerge.Stats, error) {
+	data, err := readArchive(path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case "proto":
+		mergedProfile := new(ppmerge.MergedProfile)
+		if err = mergedProfile.UnmarshalVT(data); err != nil {
+			return nil, errors.Wrap(err, "unmarshal")
+		}
+		if mergedProfile.Dictionary != "" && resolver != nil {
+			if _, err = mergedProfile.Decoded(resolver); err != nil {
+				return nil, err
+			}
+		}
+		return mergedProfile.Stats()
+	case "goroutine":
+		mergedProfile := new(ppmerge.MergedGoroutineProfile)
+		if err = mergedProfile.UnmarshalVT(data); err != nil {
+			return nil, errors.Wrap(err, "unmarshal")
+		}
+		return mergedProfile.Stats()
+	case "raw":
+		mergedProfile := new(ppmerge.MergedByteProfile)
+		if err = mergedProfile.UnmarshalVT(data); err != nil {
+			return nil, errors.Wrap(err, "unmarshal")
+		}
+		return mergedProfile.Stats()
+	default:
+		return nil, errors.Errorf("unknown format %q", format)
+	}
+}
+
+func writeStats(w io.Writer, path string, stats *ppmerge.Stats, entries bool) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "archive:\t%s\n", path)
+	fmt.Fprintf(tw, "entries:\t%d\n", stats.Entries)
+	if stats.Dictionary != "" {
+		fmt.Fprintf(tw, "dictionary:\t%s\n", stats.Dictionary)
+	}
+	if len(stats.EntrySamples) > 0 {
+		minSamples, maxSamples, total := stats.EntrySamples[0], stats.EntrySamples[0], uint64(0)
+		for _, n := range stats.EntrySamples {
+			minSamples, maxSamples, total = min(minSamples, n), max(maxSamples, n), total+n
+		}
+		fmt.Fprintf(tw, "samples per entry:\tmin %d, max %d, avg %.1f\n",
+			minSamples, maxSamples, float64(total)/float64(len(stats.EntrySamples)))
+	}
+	fmt.Fprintf(tw, "size:\t%d bytes\n", stats.Size)
+	fmt.Fprintf(tw, "compressed:\t%d bytes (%s)\n", stats.CompressedSize, percent(stats.CompressedSize, stats.Size))
+
+	if entries {
+		fmt.Fprintln(tw)
+		fmt.Fprintln(tw, "entry\tsamples")
+		for i, n := range stats.EntrySamples {
+			fmt.Fprintf(tw, "%d\t%d\n", i, n)
+		}
+	}
+
+	if len(stats.Tables) > 0 {
+		fmt.Fprintln(tw)
+		fmt.Fprintln(tw, "table\tlen\tlookups\thits\thit rate")
+		for _, ts := range stats.Tables {
+			// lookups are known only to mergers, archives hold just the tables
+			if ts.Lookups == 0 {
+				fmt.Fprintf(tw, "%s\t%d\t-\t-\t-\n", ts.Name, ts.Len)
+				continue
+			}
+			fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%.1f%%\n", ts.Name, ts.Len, ts.Lookups, ts.Hits, ts.HitRate()*100)
+		}
+	}
+
+	fmt.Fprintln(tw)
+	fmt.Fprintln(tw, "field\tsize\tshare\tcompressed\tratio")
+	for _, fs := range stats.Fields {
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%d\t%s\n", fs.Name, fs.Size, percent(fs.Size, stats.Size),
+			fs.CompressedSize, percent(fs.CompressedSize, fs.Size))
+	}
+	fmt.Fprintln(tw)
+
+	return tw.Flush()
+}
+
+func percent(n, total int) string {
+	if total == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f%%", float64(n)*100/float64(total))
+}
@@ -0,0 +1,52 @@
+// Command ppmerge works with archives of merged profiles.
+//
+// Usage:
+//
+//	ppmerge inspect [-format proto|goroutine|raw] [-entries] [-json] [-dictionaries dir] archive...
+//	ppmerge compact [-nodefraction f] [-dictionaries dir] -o output archive
+//
+// inspect prints number of entries, sizes of shared tables and space taken by every field
+// of archives written by WriteCompressed or WriteUncompressed.
+//
+// compact writes lossy copy of archive which keeps just enough to draw flame graphs,
+// see MergedProfile.Compacted.
+//
+// Archives written with WithDictionary refer to symbol dictionaries, which are looked up in
+// -dictionaries directory, every file of which holds one dictionary marshaled by MarshalVT.
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+const usage = `usage: ppmerge <command> [flags]
+
+commands:
+  inspect    print statistics of archives
+  compact    drop addresses and fold samples of archive for cold storage`
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "ppmerge:", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, w io.Writer) error {
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+
+	switch args[0] {
+	case "inspect":
+		return inspect(args[1:], w)
+	case "compact":
+		return compact(args[1:], w)
+	default:
+		return errors.Errorf("unknown command %q\n%s", args[0], usage)
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/threadedstream/ppmerge"
+	"github.com/threadedstream/ppmerge/profile"
+)
+
+func TestInspect(t *testing.T) {
+	dir := t.TempDir()
+
+	var profiles []*profile.Profile
+	for _, name := range []string{"hprof1", "hprof2"} {
+		file, err := os.Open(filepath.Join("..", "..", "testdata", name))
+		require.NoError(t, err)
+		p, err := profile.ParseProfile(file)
+		require.NoError(t, err)
+		require.NoError(t, file.Close())
+		profiles = append(profiles, p)
+	}
+
+	profileMerger := ppmerge.NewProfileMerger()
+	profileMerger.Merge(profiles...)
+
+	compressed := filepath.Join(dir, "heap")
+	file, err := os.Create(compressed)
+	require.NoError(t, err)
+	require.NoError(t, profileMerger.WriteCompressed(file))
+	require.NoError(t, file.Close())
+
+	uncompressed := filepath.Join(dir, "heap.pb")
+	file, err = os.Create(uncompressed)
+	require.NoError(t, err)
+	require.NoError(t, profileMerger.WriteUncompressed(file))
+	require.NoError(t, file.Close())
+
+	var out bytes.Buffer
+	require.NoError(t, run([]string{"inspect", "-entries", compressed, uncompressed}, &out))
+	for _, expected := range []string{"entries:", "samples per entry:", "locations", "samples", "string_table", "hit rate"} {
+		require.Contains(t, out.String(), expected)
+	}
+	require.Equal(t, 2, bytes.Count(out.Bytes(), []byte("archive:")))
+
+	out.Reset()
+	require.NoError(t, run([]string{"inspect", "-json", compressed}, &out))
+	var stats struct {
+		Archive string
+		ppmerge.Stats
+	}
+	require.NoError(t, json.Unmarshal(out.Bytes(), &stats))
+	require.Equal(t, compressed, stats.Archive)
+	require.Equal(t, 2, stats.Entries)
+
+	require.Error(t, run(nil, &out))
+	require.Error(t, run([]string{"unknown"}, &out))
+	require.Error(t, run([]string{"inspect"}, &out))
+	require.Error(t, run([]string{"inspect", "-format", "xml", compressed}, &out))
+	require.Error(t, run([]string{"inspect", filepath.Join(dir, "missing")}, &out))
+}
+
+func TestCompact(t *testing.T) {
+	dir := t.TempDir()
+
+	file, err := os.Open(filepath.Join("..", "..", "testdata", "parca_cpu"))
+	require.NoError(t, err)
+	p, err := profile.ParseProfile(file)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	profileMerger := ppmerge.NewProfileMerger()
+	profileMerger.Merge(p)
+	archive := filepath.Join(dir, "cpu")
+	file, err = os.Create(archive)
+	require.NoError(t, err)
+	require.NoError(t, profileMerger.WriteCompressed(file))
+	require.NoError(t, file.Close())
+
+	compacted := filepath.Join(dir, "cpu.cold")
+	var out bytes.Buffer
+	require.NoError(t, run([]string{"compact", "-nodefraction", "0.001", "-o", compacted, archive}, &out))
+	require.Contains(t, out.String(), "saved")
+
+	before, err := os.Stat(archive)
+	require.NoError(t, err)
+	after, err := os.Stat(compacted)
+	require.NoError(t, err)
+	require.Less(t, after.Size(), before.Size())
+
+	data, err := os.ReadFile(compacted)
+	require.NoError(t, err)
+	recovered, err := ppmerge.NewProfileUnPacker(nil).UnpackRaw(data, 0)
+	require.NoError(t, err)
+	require.NoError(t, recovered.CheckValid())
+	require.Empty(t, recovered.Mapping)
+
+	require.Error(t, run([]string{"compact", archive}, &out))
+	require.Error(t, run([]string{"compact", "-nodefraction", "2", "-o", compacted, archive}, &out))
+}
+
+func TestDictionaries(t *testing.T) {
+	dir := t.TempDir()
+
+	var profiles []*profile.Profile
+	for _, name := range []string{"hprof1", "hprof2", "hprof3"} {
+		file, err := os.Open(filepath.Join("..", "..", "testdata", name))
+		require.NoError(t, err)
+		p, err := profile.ParseProfile(file)
+		require.NoError(t, err)
+		require.NoError(t, file.Close())
+		profiles = append(profiles, p)
+	}
+
+	dict, err := ppmerge.NewSymbolDictionary(ppmerge.NewProfileMerger().Merge(profiles[:2]...))
+	require.NoError(t, err)
+	data, err := dict.MarshalVT()
+	require.NoError(t, err)
+	dictionaries := filepath.Join(dir, "dictionaries")
+	require.NoError(t, os.Mkdir(dictionaries, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dictionaries, "app"), data, 0644))
+
+	profileMerger := ppmerge.NewProfileMerger(ppmerge.WithDictionary(dict))
+	profileMerger.Merge(profiles[2])
+	archive := filepath.Join(dir, "heap")
+	file, err := os.Create(archive)
+	require.NoError(t, err)
+	require.NoError(t, profileMerger.WriteCompressed(file))
+	require.NoError(t, file.Close())
+
+	var out bytes.Buffer
+	require.NoError(t, run([]string{"inspect", "-dictionaries", dictionaries, archive}, &out))
+	require.Contains(t, out.String(), "dictionary:")
+	require.Contains(t, out.String(), dict.Key)
+	require.Error(t, run([]string{"inspect", "-dictionaries", t.TempDir(), archive}, &out))
+
+	compacted := filepath.Join(dir, "heap.cold")
+	require.Error(t, run([]string{"compact", "-o", compacted, archive}, &out))
+	require.NoError(t, run([]string{"compact", "-dictionaries", dictionaries, "-o", compacted, archive}, &out))
+
+	// compacted archive holds tables of dictionary
+	data, err = os.ReadFile(compacted)
+	require.NoError(t, err)
+	recovered, err := ppmerge.NewProfileUnPacker(nil).UnpackRaw(data, 0)
+	require.NoError(t, err)
+	require.NoError(t, recovered.CheckValid())
+	require.NotEmpty(t, recovered.Sample)
+}
@@ -0,0 +1,288 @@
+// Package collector scrapes net/http/pprof endpoints on a schedule and stores
+// the results as rolling ppmerge archives.
+package collector
+
+import (
+	"context"
+	stderrors "errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultInterval    = time.Minute
+	defaultTimeout     = 30 * time.Second
+	defaultConcurrency = 4
+)
+
+// Target is a process exposing net/http/pprof endpoints
+type Target struct {
+	// Name identifies target in archive names, so it must be unique and free of path separators
+	Name string
+	// Addr is the base address of target, e.g. http://localhost:6060
+	Addr string
+}
+
+// Config configures Collector
+type Config struct {
+	// Targets to be scraped
+	Targets []Target
+	// Kinds of profiles to be scraped, see ParseKind
+	Kinds []string
+	// Dir is the directory archives are written to
+	Dir string
+
+	// Interval between consecutive scrapes
+	Interval time.Duration
+	// Timeout of a single scrape on top of the time profile takes to be collected
+	Timeout time.Duration
+	// Concurrency limits number of scrapes running at the same time
+	Concurrency int
+
+	// MaxArchiveSize rotates archive once total size of raw profiles reaches it
+	MaxArchiveSize int64
+	// MaxArchiveAge rotates archive once its first profile gets older than it
+	MaxArchiveAge time.Duration
+
+	// Client is used to make requests to targets
+	Client *http.Client
+	// OnError is called on every failed scrape
+	OnError func(target Target, kind Kind, err error)
+}
+
+type seriesKey struct {
+	target, kind string
+}
+
+// Collector periodically scrapes profiles from targets and rotates them into archives
+type Collector struct {
+	cfg   Config
+	kinds []Kind
+
+	mu     sync.Mutex
+	series map[seriesKey]*series
+
+	now func() time.Time
+}
+
+// New returns Collector instance
+func New(cfg Config) (*Collector, error) {
+	if len(cfg.Targets) == 0 {
+		return nil, errors.New("no targets specified")
+	}
+	if cfg.Dir == "" {
+		return nil, errors.New("no archive directory specified")
+	}
+
+	// target and kind names make up archive names, so they must be unique and path safe
+	targetNames := make(map[string]bool, len(cfg.Targets))
+	for _, target := range cfg.Targets {
+		if !validTargetName(target.Name) {
+			return nil, errors.Errorf("invalid target name %q", target.Name)
+		}
+		if targetNames[target.Name] {
+			return nil, errors.Errorf("duplicate target name %q", target.Name)
+		}
+		targetNames[target.Name] = true
+	}
+
+	kinds := make([]Kind, 0, len(cfg.Kinds))
+	kindNames := make(map[string]bool, len(cfg.Kinds))
+	for _, desc := range cfg.Kinds {
+		kind, err := ParseKind(desc)
+		if err != nil {
+			return nil, err
+		}
+		if kindNames[kind.Name] {
+			return nil, errors.Errorf("duplicate profile kind %q", kind.Name)
+		}
+		kindNames[kind.Name] = true
+		kinds = append(kinds, kind)
+	}
+	if len(kinds) == 0 {
+		return nil, errors.New("no profile kinds specified")
+	}
+
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultInterval
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultTimeout
+	}
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = defaultConcurrency
+	}
+	if cfg.Client == nil {
+		cfg.Client = http.DefaultClient
+	}
+	if cfg.OnError == nil {
+		cfg.OnError = func(Target, Kind, error) {}
+	}
+
+	c := &Collector{
+		cfg:    cfg,
+		kinds:  kinds,
+		series: make(map[seriesKey]*series),
+		now:    time.Now,
+	}
+
+	for _, target := range cfg.Targets {
+		for _, kind := range kinds {
+			c.series[seriesKey{target.Name, kind.Name}] = newSeries(target, kind)
+		}
+	}
+
+	return c, nil
+}
+
+func validTargetName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsAny(name, "/\\")
+}
+
+// Run scrapes targets every Config.Interval until ctx is done. Pending profiles
+// are written out before Run returns.
+func (c *Collector) Run(ctx context.Context) error {
+	ticker := time.NewTicker(c.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := c.Scrape(ctx); err != nil {
+			return stderrors.Join(err, c.Flush())
+		}
+
+		select {
+		case <-ctx.Done():
+			return c.Flush()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Scrape scrapes every kind of profile from every target once and rotates
+// archives that reached their size or age limit
+func (c *Collector) Scrape(ctx context.Context) error {
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, c.cfg.Concurrency)
+
+	for _, target := range c.cfg.Targets {
+		for _, kind := range c.kinds {
+			select {
+			case <-ctx.Done():
+				wg.Wait()
+				return c.rotate(false)
+			case sem <- struct{}{}:
+			}
+
+			wg.Add(1)
+			go func(target Target, kind Kind) {
+				defer func() {
+					<-sem
+					wg.Done()
+				}()
+				if err := c.scrape(ctx, target, kind); err != nil {
+					c.cfg.OnError(target, kind, err)
+				}
+			}(target, kind)
+		}
+	}
+	wg.Wait()
+
+	return c.rotate(false)
+}
+
+// Flush writes out all pending profiles regardless of archive limits
+func (c *Collector) Flush() error {
+	return c.rotate(true)
+}
+
+func (c *Collector) scrape(ctx context.Context, target Target, kind Kind) error {
+	ctx, cancel := context.WithTimeout(ctx, c.cfg.Timeout+kind.Duration())
+	defer cancel()
+
+	url := target.Addr + kind.Path
+	if len(kind.Query) > 0 {
+		url += "?" + kind.Query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return errors.Wrap(err, "create request")
+	}
+
+	resp, err := c.cfg.Client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "fetch profile")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("fetch profile: unexpected status %s", resp.Status)
+	}
+
+	rawProfile, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return errors.Wrap(err, "read profile")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.series[seriesKey{target.Name, kind.Name}].add(rawProfile, c.now())
+}
+
+func (c *Collector) rotate(force bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	var errs []error
+	for _, s := range c.series {
+		if s.len() == 0 {
+			continue
+		}
+
+		full := c.cfg.MaxArchiveSize > 0 && s.size >= c.cfg.MaxArchiveSize
+		expired := c.cfg.MaxArchiveAge > 0 && now.Sub(s.start) >= c.cfg.MaxArchiveAge
+		if !force && !full && !expired {
+			continue
+		}
+
+		if err := c.writeArchive(s); err != nil {
+			// archive that can't be written doesn't hold back the rest
+			errs = append(errs, err)
+			continue
+		}
+		s.reset()
+	}
+
+	return stderrors.Join(errs...)
+}
+
+// writeArchive writes series to a temporary file first, so that readers never see partially written archives
+func (c *Collector) writeArchive(s *series) error {
+	path := filepath.Join(c.cfg.Dir, s.archiveName())
+
+	file, err := os.CreateTemp(c.cfg.Dir, ".archive-*")
+	if err != nil {
+		return errors.Wrap(err, "create archive")
+	}
+	defer os.Remove(file.Name())
+
+	if err = s.writeTo(file); err != nil {
+		file.Close()
+		return errors.Wrapf(err, "write archive %s", path)
+	}
+
+	if err = file.Close(); err != nil {
+		return errors.Wrapf(err, "write archive %s", path)
+	}
+
+	return errors.Wrapf(os.Rename(file.Name(), path), "write archive %s", path)
+}
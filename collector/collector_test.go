@@ -0,0 +1,230 @@
+package collector
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/http/pprof"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/threadedstream/ppmerge"
+)
+
+func newPprofServer() *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	return httptest.NewServer(mux)
+}
+
+func readArchives(t *testing.T, dir, kind string) [][]byte {
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+
+	var archives [][]byte
+	for _, entry := range entries {
+		if !strings.Contains(entry.Name(), "-"+kind+"-") {
+			continue
+		}
+		b, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		require.NoError(t, err)
+		archives = append(archives, b)
+	}
+	return archives
+}
+
+func TestParseKind(t *testing.T) {
+	kind, err := ParseKind("cpu?seconds=10")
+	require.NoError(t, err)
+	require.Equal(t, "cpu", kind.Name)
+	require.Equal(t, "/debug/pprof/profile", kind.Path)
+	require.Equal(t, FormatProto, kind.Format)
+	require.Equal(t, 10*time.Second, kind.Duration())
+
+	kind, err = ParseKind("goroutine?debug=1")
+	require.NoError(t, err)
+	require.Equal(t, "goroutine-debug1", kind.Name)
+	require.Equal(t, FormatGoroutineDebug, kind.Format)
+
+	kind, err = ParseKind("goroutine?debug=2")
+	require.NoError(t, err)
+	require.Equal(t, FormatRaw, kind.Format)
+
+	for _, desc := range []string{"../heap", "..", "goroutine?debug=%2F..%2F..%2Fx", "goroutine?debug=a/b", "goroutine?debug=.."} {
+		_, err = ParseKind(desc)
+		require.Error(t, err, desc)
+	}
+}
+
+func TestNewInvalidNames(t *testing.T) {
+	for _, cfg := range []Config{
+		{Targets: []Target{{Name: ""}}, Kinds: []string{"heap"}},
+		{Targets: []Target{{Name: "../app"}}, Kinds: []string{"heap"}},
+		{Targets: []Target{{Name: "app\\x"}}, Kinds: []string{"heap"}},
+		{Targets: []Target{{Name: ".."}}, Kinds: []string{"heap"}},
+		{Targets: []Target{{Name: "app"}, {Name: "app"}}, Kinds: []string{"heap"}},
+		{Targets: []Target{{Name: "app"}}, Kinds: []string{"heap", "heap?gc=1"}},
+		{Targets: []Target{{Name: "app"}}, Kinds: []string{"goroutine?debug=1", "goroutine?debug=1&x=y"}},
+		{Targets: []Target{{Name: "app"}}, Kinds: []string{"goroutine?debug=%2F..%2F..%2Fx"}},
+	} {
+		cfg.Dir = t.TempDir()
+		_, err := New(cfg)
+		require.Error(t, err, "%+v", cfg)
+	}
+
+	_, err := New(Config{Targets: []Target{{Name: "app"}, {Name: "db"}}, Kinds: []string{"heap", "goroutine?debug=1"}, Dir: t.TempDir()})
+	require.NoError(t, err)
+}
+
+func TestCollector(t *testing.T) {
+	srv := newPprofServer()
+	defer srv.Close()
+
+	dir := t.TempDir()
+	c, err := New(Config{
+		Targets:     []Target{{Name: "app", Addr: srv.URL}},
+		Kinds:       []string{"cpu?seconds=1", "heap", "goroutine?debug=1", "goroutine?debug=2"},
+		Dir:         dir,
+		Concurrency: 2,
+		OnError: func(target Target, kind Kind, err error) {
+			t.Errorf("scrape %s %s: %v", target.Name, kind.Name, err)
+		},
+	})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	require.NoError(t, c.Scrape(ctx))
+	require.NoError(t, c.Scrape(ctx))
+
+	// no limits configured, so nothing is written until flush
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+
+	require.NoError(t, c.Flush())
+
+	for _, kind := range []string{"cpu", "heap"} {
+		archives := readArchives(t, dir, kind)
+		require.Len(t, archives, 1)
+		for idx := uint64(0); idx < 2; idx++ {
+			p, err := ppmerge.NewProfileUnPacker(nil).UnpackRaw(archives[0], idx)
+			require.NoError(t, err)
+			require.NotEmpty(t, p.SampleType)
+		}
+	}
+
+	archives := readArchives(t, dir, "goroutine-debug1")
+	require.Len(t, archives, 1)
+	goroutineUnpacker := ppmerge.NewGoroutineProfileUnPacker(nil)
+	gp, err := goroutineUnpacker.UnpackRaw(archives[0], 1)
+	require.NoError(t, err)
+	require.NotZero(t, gp.GetTotal())
+	entry, err := goroutineUnpacker.UnpackEntry(1)
+	require.NoError(t, err)
+	require.Equal(t, srv.URL, entry.Source)
+	require.False(t, entry.Time.IsZero())
+
+	archives = readArchives(t, dir, "goroutine-debug2")
+	require.Len(t, archives, 1)
+	raw, err := ppmerge.NewByteProfileUnPacker(nil).UnpackRaw(archives[0], 1)
+	require.NoError(t, err)
+	require.Contains(t, string(raw), "goroutine")
+}
+
+func TestCollectorRotation(t *testing.T) {
+	srv := newPprofServer()
+	defer srv.Close()
+
+	dir := t.TempDir()
+	c, err := New(Config{
+		Targets:        []Target{{Name: "app", Addr: srv.URL}},
+		Kinds:          []string{"heap", "goroutine?debug=1"},
+		Dir:            dir,
+		MaxArchiveSize: 1,
+		MaxArchiveAge:  time.Hour,
+	})
+	require.NoError(t, err)
+
+	now := time.Unix(1700000000, 0)
+	c.now = func() time.Time { return now }
+
+	// every single profile exceeds size limit
+	require.NoError(t, c.Scrape(context.Background()))
+	now = now.Add(time.Second)
+	require.NoError(t, c.Scrape(context.Background()))
+	require.Len(t, readArchives(t, dir, "heap"), 2)
+
+	// age limit
+	c.cfg.MaxArchiveSize = 0
+	now = now.Add(time.Second)
+	require.NoError(t, c.Scrape(context.Background()))
+	require.Len(t, readArchives(t, dir, "heap"), 2)
+
+	now = now.Add(time.Hour)
+	require.NoError(t, c.Scrape(context.Background()))
+	require.Len(t, readArchives(t, dir, "heap"), 3)
+	require.Len(t, readArchives(t, dir, "goroutine-debug1"), 3)
+}
+
+func TestCollectorRun(t *testing.T) {
+	srv := newPprofServer()
+	defer srv.Close()
+
+	dir := t.TempDir()
+	c, err := New(Config{
+		Targets:  []Target{{Name: "app", Addr: srv.URL}},
+		Kinds:    []string{"heap"},
+		Dir:      dir,
+		Interval: 10 * time.Millisecond,
+	})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	require.NoError(t, c.Run(ctx))
+
+	archives := readArchives(t, dir, "heap")
+	require.Len(t, archives, 1)
+	p, err := ppmerge.NewProfileUnPacker(nil).UnpackRaw(archives[0], 1)
+	require.NoError(t, err)
+	require.NotNil(t, p)
+}
+
+func TestCollectorRunFlushesOnError(t *testing.T) {
+	// goroutine dumps are stored as is, so that target big exceeds size limit while small doesn't
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		size := 10
+		if strings.HasPrefix(r.URL.Path, "/big/") {
+			size = 1000
+		}
+		_, _ = w.Write([]byte(strings.Repeat("x", size)))
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	c, err := New(Config{
+		Targets:        []Target{{Name: "big", Addr: srv.URL + "/big"}, {Name: "small", Addr: srv.URL + "/small"}},
+		Kinds:          []string{"goroutine?debug=2"},
+		Dir:            dir,
+		MaxArchiveSize: 100,
+	})
+	require.NoError(t, err)
+	now := time.Unix(1700000000, 0)
+	c.now = func() time.Time { return now }
+
+	// archive of big can't be written, as its path is taken by a directory
+	big := c.series[seriesKey{"big", "goroutine-debug2"}]
+	big.start = now
+	require.NoError(t, os.Mkdir(filepath.Join(dir, big.archiveName()), 0755))
+
+	require.Error(t, c.Run(context.Background()))
+	small := newSeries(Target{Name: "small"}, Kind{Name: "goroutine-debug2"})
+	small.start = now
+	_, err = os.Stat(filepath.Join(dir, small.archiveName()))
+	require.NoError(t, err)
+}
@@ -0,0 +1,99 @@
+package collector
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Format is the format profile of certain kind is served in
+type Format int
+
+const (
+	// FormatProto is gzipped pprof protobuf
+	FormatProto Format = iota
+	// FormatGoroutineDebug is goroutine profile in debug=1 text format
+	FormatGoroutineDebug
+	// FormatRaw is any other format, e.g. debug=2 goroutine dump
+	FormatRaw
+)
+
+// Kind describes a single /debug/pprof endpoint
+type Kind struct {
+	// Name is the short name of profile kind, e.g. cpu, heap or goroutine-debug1
+	Name string
+	// Path is the endpoint path relative to target address
+	Path string
+	// Query is the query string sent along with request
+	Query url.Values
+	// Format is the format endpoint responds with
+	Format Format
+}
+
+// ParseKind parses profile kind descriptions such as cpu?seconds=10, heap or goroutine?debug=1.
+// Name and debug level make up archive names, so they must be path safe and alphanumeric respectively.
+func ParseKind(desc string) (Kind, error) {
+	name, rawQuery, _ := strings.Cut(desc, "?")
+	if !validTargetName(name) {
+		return Kind{}, errors.Errorf("invalid profile kind %q", desc)
+	}
+
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return Kind{}, errors.Wrapf(err, "invalid profile kind %q", desc)
+	}
+
+	kind := Kind{
+		Name:   name,
+		Path:   "/debug/pprof/" + name,
+		Query:  query,
+		Format: FormatProto,
+	}
+
+	if name == "cpu" {
+		kind.Path = "/debug/pprof/profile"
+	}
+
+	if debug := query.Get("debug"); debug != "" && debug != "0" {
+		// debug level becomes part of archive names
+		if !alphanumeric(debug) {
+			return Kind{}, errors.Errorf("invalid debug level of profile kind %q", desc)
+		}
+		kind.Name += "-debug" + debug
+		kind.Format = FormatRaw
+		if name == "goroutine" && debug == "1" {
+			kind.Format = FormatGoroutineDebug
+		}
+	}
+
+	if !validTargetName(kind.Name) {
+		return Kind{}, errors.Errorf("invalid profile kind %q", desc)
+	}
+
+	return kind, nil
+}
+
+// alphanumeric tells whether s is made of ASCII letters and digits only
+func alphanumeric(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') && (r < '0' || r > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// Duration returns the time profile takes to be collected on the target side
+func (k Kind) Duration() time.Duration {
+	secs, err := strconv.ParseFloat(k.Query.Get("seconds"), 64)
+	if err != nil || secs <= 0 {
+		return 0
+	}
+	return time.Duration(secs * float64(time.Second))
+}
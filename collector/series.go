@@ -0,0 +1,115 @@
+package collector
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/threadedstream/ppmerge"
+	"github.com/threadedstream/ppmerge/profile"
+)
+
+// series accumulates profiles of a single kind scraped from a single target
+// until they're written out as an archive
+type series struct {
+	target Target
+	kind   Kind
+
+	start time.Time
+	size  int64
+
+	profiles          []*profile.Profile
+	goroutineProfiles []*profile.GoroutineProfile
+	goroutineTimes    []time.Time
+	rawProfiles       [][]byte
+}
+
+func newSeries(target Target, kind Kind) *series {
+	return &series{
+		target: target,
+		kind:   kind,
+	}
+}
+
+func (s *series) add(rawProfile []byte, now time.Time) error {
+	switch s.kind.Format {
+	case FormatProto:
+		p, err := profile.ParseProfileData(rawProfile)
+		if err != nil {
+			return errors.Wrap(err, "parse profile")
+		}
+		s.profiles = append(s.profiles, p)
+	case FormatGoroutineDebug:
+		gp := profile.GoroutineProfileFromVTPool()
+		if err := gp.Parse(rawProfile); err != nil {
+			gp.ReturnToVTPool()
+			return errors.Wrap(err, "parse goroutine profile")
+		}
+		s.goroutineProfiles = append(s.goroutineProfiles, gp)
+		// debug=1 text has no timestamp, so it's recorded along with the profile
+		s.goroutineTimes = append(s.goroutineTimes, now)
+	default:
+		s.rawProfiles = append(s.rawProfiles, rawProfile)
+	}
+
+	if s.len() == 1 {
+		s.start = now
+	}
+	s.size += int64(len(rawProfile))
+	return nil
+}
+
+func (s *series) len() int {
+	return len(s.profiles) + len(s.goroutineProfiles) + len(s.rawProfiles)
+}
+
+// archiveName returns name of the file the series is written to
+func (s *series) archiveName() string {
+	return fmt.Sprintf("%s-%s-%s.pb.gz", s.target.Name, s.kind.Name, s.start.UTC().Format("20060102T150405.000Z"))
+}
+
+// writeTo merges accumulated profiles and writes them to w as a compressed archive
+func (s *series) writeTo(w io.Writer) error {
+	switch s.kind.Format {
+	case FormatProto:
+		profileMerger := ppmerge.NewProfileMerger()
+		defer profileMerger.Release()
+		profileMerger.Merge(s.profiles...)
+		return profileMerger.WriteCompressed(w)
+	case FormatGoroutineDebug:
+		profileMerger := ppmerge.NewGoroutineProfileMerger()
+		defer profileMerger.Release()
+		entries := make([]ppmerge.GoroutineEntry, len(s.goroutineProfiles))
+		for i, gp := range s.goroutineProfiles {
+			entries[i] = ppmerge.GoroutineEntry{
+				Profile: gp,
+				Time:    s.goroutineTimes[i],
+				Source:  s.target.Addr,
+			}
+		}
+		profileMerger.MergeEntries(entries...)
+		return profileMerger.WriteCompressed(w)
+	default:
+		profileMerger := ppmerge.NewByteProfileMerger()
+		defer profileMerger.Release()
+		profileMerger.Merge(s.rawProfiles...)
+		return profileMerger.WriteCompressed(w)
+	}
+}
+
+func (s *series) reset() {
+	for _, p := range s.profiles {
+		p.ReturnToVTPool()
+	}
+	for _, gp := range s.goroutineProfiles {
+		gp.ReturnToVTPool()
+	}
+
+	s.profiles = nil
+	s.goroutineProfiles = nil
+	s.goroutineTimes = nil
+	s.rawProfiles = nil
+	s.size = 0
+	s.start = time.Time{}
+}
@@ -0,0 +1,140 @@
+package ppmerge
+
+import (
+	pprofile "github.com/google/pprof/profile"
+	"github.com/pkg/errors"
+	"github.com/threadedstream/ppmerge/profile"
+)
+
+// CompactResult reports what Compacted did. Sizes are the ones of archives written by
+// WriteUncompressed and WriteCompressed before and after compaction.
+type CompactResult struct {
+	// Samples is number of samples before compaction
+	Samples int
+	// FoldedSamples is number of samples folded into identical ones or dropped for having zero values
+	FoldedSamples int
+	// PrunedSamples is number of samples dropped for being below node fraction
+	PrunedSamples int
+
+	Size                    int
+	CompactedSize           int
+	CompressedSize          int
+	CompactedCompressedSize int
+}
+
+// Saved returns number of compressed bytes saved by compaction
+func (r *CompactResult) Saved() int {
+	return r.CompressedSize - r.CompactedCompressedSize
+}
+
+// Compacted returns lossy copy of x meant for cold storage, which keeps just enough to draw
+// flame graphs. Mappings and folding flags are dropped and so are addresses of symbolized
+// locations, addresses of unsymbolized ones are kept as they are the only name of their frames.
+// Samples of an entry which become identical, i.e. have the same stack and labels, are folded
+// into one. If nodeFraction is positive, samples all values of which are below nodeFraction of
+// the entry's total of the corresponding sample type are dropped, like pprof's nodefraction does.
+// Archives referring to symbol dictionary must be decoded with Decoded first.
+func (x *MergedProfile) Compacted(nodeFraction float64) (*MergedProfile, *CompactResult, error) {
+	if nodeFraction < 0 || nodeFraction >= 1 {
+		return nil, nil, errors.Errorf("node fraction %v is out of [0, 1)", nodeFraction)
+	}
+
+	res := &CompactResult{Samples: len(x.Samples)}
+	var err error
+	if res.Size, res.CompressedSize, err = archiveSizes(x); err != nil {
+		return nil, nil, err
+	}
+
+	unpacker := NewProfileUnPacker(x)
+	ps := make([]*profile.Profile, len(x.NumSamples))
+	for idx := range ps {
+		p, err := unpacker.Unpack(uint64(idx))
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "unpack entry %d", idx)
+		}
+
+		numSamples := len(p.Sample)
+		if p, err = compactProfile(p); err != nil {
+			return nil, nil, errors.Wrapf(err, "compact entry %d", idx)
+		}
+		res.FoldedSamples += numSamples - len(p.Sample)
+
+		numSamples = len(p.Sample)
+		pruneSamples(p, nodeFraction)
+		res.PrunedSamples += numSamples - len(p.Sample)
+
+		ps[idx] = new(profile.Profile)
+		ps[idx].From(p)
+	}
+
+	compacted := NewProfileMerger().Merge(ps...)
+	if res.CompactedSize, res.CompactedCompressedSize, err = archiveSizes(compacted); err != nil {
+		return nil, nil, err
+	}
+
+	return compacted, res, nil
+}
+
+// compactProfile strips addresses off p and folds its identical samples
+func compactProfile(p *pprofile.Profile) (*pprofile.Profile, error) {
+	for _, loc := range p.Location {
+		loc.Mapping = nil
+		loc.IsFolded = false
+		if len(loc.Line) > 0 {
+			loc.Address = 0
+		}
+	}
+	p.Mapping = nil
+
+	return pprofile.Merge([]*pprofile.Profile{p})
+}
+
+// pruneSamples drops samples of p all values of which are below nodeFraction of total
+func pruneSamples(p *pprofile.Profile, nodeFraction float64) {
+	if nodeFraction <= 0 {
+		return
+	}
+
+	thresholds := make([]float64, len(p.SampleType))
+	for _, s := range p.Sample {
+		for i, v := range s.Value {
+			if i < len(thresholds) {
+				thresholds[i] += float64(abs(v))
+			}
+		}
+	}
+	for i := range thresholds {
+		thresholds[i] *= nodeFraction
+	}
+
+	samples := p.Sample[:0]
+	for _, s := range p.Sample {
+		for i, v := range s.Value {
+			if i < len(thresholds) && float64(abs(v)) >= thresholds[i] {
+				samples = append(samples, s)
+				break
+			}
+		}
+	}
+	p.Sample = samples
+}
+
+// archiveSizes returns sizes of x written by WriteUncompressed and WriteCompressed
+func archiveSizes(x *MergedProfile) (int, int, error) {
+	data, err := x.MarshalDeterministic()
+	if err != nil {
+		return 0, 0, errors.Wrap(err, "marshal")
+	}
+	compressedSize, err := gzipSize(data)
+	if err != nil {
+		return 0, 0, errors.Wrap(err, "compress")
+	}
+	return len(data), compressedSize, nil
+}
+
+func abs(v int64) int64 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -0,0 +1,117 @@
+package ppmerge
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	pprofile "github.com/google/pprof/profile"
+	"github.com/stretchr/testify/require"
+)
+
+// stackTotals sums non-zero values of every sample type of p by stack of frame names and labels
+func stackTotals(p *pprofile.Profile) map[string]int64 {
+	totals := make(map[string]int64)
+	for _, s := range p.Sample {
+		var frames []string
+		for _, loc := range s.Location {
+			if len(loc.Line) == 0 {
+				frames = append(frames, fmt.Sprintf("%#x", loc.Address))
+			}
+			for _, line := range loc.Line {
+				frames = append(frames, fmt.Sprintf("%s:%d", line.Function.Name, line.Line))
+			}
+		}
+		stack := strings.Join(frames, ";") + fmt.Sprint(s.Label, s.NumLabel)
+		for i, v := range s.Value {
+			if v != 0 {
+				totals[fmt.Sprintf("%s#%d", stack, i)] += v
+			}
+		}
+	}
+	return totals
+}
+
+func TestCompacted(t *testing.T) {
+	paths := []string{"hprof1", "parca_cpu", "labels.prof", "hprof2", "parca_heap"}
+	mergedProfile := NewProfileMerger().Merge(getProfilesVtProto(t, false, paths...)...)
+
+	compacted, res, err := mergedProfile.Compacted(0)
+	require.NoError(t, err)
+	require.Empty(t, compacted.Mappings)
+	for _, loc := range compacted.Locations {
+		require.Zero(t, loc.MappingId)
+		require.False(t, loc.IsFolded)
+		if len(loc.Line) > 0 {
+			require.Zero(t, loc.Address)
+		}
+	}
+	require.Less(t, len(compacted.Locations), len(mergedProfile.Locations))
+
+	require.Equal(t, len(mergedProfile.Samples), res.Samples)
+	require.Positive(t, res.FoldedSamples)
+	require.Zero(t, res.PrunedSamples)
+	require.Equal(t, res.Samples-res.FoldedSamples, len(compacted.Samples))
+	require.Less(t, res.CompactedSize, res.Size)
+	require.Positive(t, res.Saved())
+
+	// flame graphs stay the same
+	unpacker := NewProfileUnPacker(mergedProfile)
+	compactedUnpacker := NewProfileUnPacker(compacted)
+	for idx := range paths {
+		expected, err := unpacker.Unpack(uint64(idx))
+		require.NoError(t, err)
+		actual, err := compactedUnpacker.Unpack(uint64(idx))
+		require.NoError(t, err)
+		require.NoError(t, actual.CheckValid())
+
+		require.Equal(t, stackTotals(expected), stackTotals(actual), paths[idx])
+		require.Equal(t, expected.SampleType, actual.SampleType)
+		require.Equal(t, expected.TimeNanos, actual.TimeNanos)
+	}
+
+	// original profile is left intact
+	original, err := unpacker.Unpack(0)
+	require.NoError(t, err)
+	require.NotEmpty(t, original.Mapping)
+}
+
+func TestCompactedNodeFraction(t *testing.T) {
+	mergedProfile := NewProfileMerger().Merge(getProfilesVtProto(t, false, "parca_cpu", "hprof1")...)
+	const nodeFraction = 0.01
+
+	compacted, res, err := mergedProfile.Compacted(nodeFraction)
+	require.NoError(t, err)
+	require.Positive(t, res.PrunedSamples)
+	require.Equal(t, res.Samples-res.FoldedSamples-res.PrunedSamples, len(compacted.Samples))
+
+	folded, _, err := mergedProfile.Compacted(0)
+	require.NoError(t, err)
+	require.Less(t, len(compacted.Samples), len(folded.Samples))
+
+	for idx := range compacted.NumSamples {
+		all, err := NewProfileUnPacker(folded).Unpack(uint64(idx))
+		require.NoError(t, err)
+		pruned, err := NewProfileUnPacker(compacted).Unpack(uint64(idx))
+		require.NoError(t, err)
+
+		totals := make([]int64, len(all.SampleType))
+		for _, s := range all.Sample {
+			for i, v := range s.Value {
+				totals[i] += abs(v)
+			}
+		}
+		for _, s := range pruned.Sample {
+			kept := false
+			for i, v := range s.Value {
+				kept = kept || float64(abs(v)) >= nodeFraction*float64(totals[i])
+			}
+			require.True(t, kept)
+		}
+	}
+
+	for _, nodeFraction := range []float64{-0.1, 1} {
+		_, _, err = mergedProfile.Compacted(nodeFraction)
+		require.Error(t, err)
+	}
+}
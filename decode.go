@@ -0,0 +1,80 @@
+package ppmerge
+
+import (
+	"github.com/pkg/errors"
+)
+
+// Decoded returns copy of x with front coded strings, tables of symbol dictionary and delta encoded
+// values restored, or x itself if there's nothing to restore. Unlike DecodeStrings, AttachDictionary
+// and DecodeDeltas it leaves x intact, so that x may be read concurrently. Dictionary is resolved
+// with resolver, which may be nil if x refers to no dictionary. The copy shares the rest with x.
+func (x *MergedProfile) Decoded(resolver DictionaryResolver) (*MergedProfile, error) {
+	if len(x.FrontCodedStrings) == 0 && x.Dictionary == "" && !x.DeltaValues {
+		return x, nil
+	}
+
+	y := x.shallowCopy()
+	if err := y.DecodeStrings(); err != nil {
+		return nil, errors.Wrap(err, "decode strings")
+	}
+	if y.Dictionary != "" {
+		if resolver == nil {
+			return nil, errors.Wrapf(noDictionaryResolverErr, "dictionary %s", y.Dictionary)
+		}
+		dict, err := resolver.Resolve(y.Dictionary)
+		if err != nil {
+			return nil, errors.Wrapf(err, "resolve dictionary %s", y.Dictionary)
+		}
+		if err := y.AttachDictionary(dict); err != nil {
+			return nil, err
+		}
+	}
+	if err := y.DecodeDeltas(); err != nil {
+		return nil, errors.Wrap(err, "decode deltas")
+	}
+	return y, nil
+}
+
+// Decoded returns copy of x with front coded strings and delta encoded totals restored, or x itself
+// if there's nothing to restore, like MergedProfile.Decoded does
+func (x *MergedGoroutineProfile) Decoded() (*MergedGoroutineProfile, error) {
+	if len(x.FrontCodedStrings) == 0 && !x.DeltaTotals {
+		return x, nil
+	}
+
+	y := x.shallowCopy()
+	if err := y.DecodeStrings(); err != nil {
+		return nil, errors.Wrap(err, "decode strings")
+	}
+	if err := y.DecodeDeltas(); err != nil {
+		return nil, errors.Wrap(err, "decode deltas")
+	}
+	return y, nil
+}
+
+// decode decodes merged profile into a copy kept until merged profile is replaced, merged profile
+// itself is left intact
+func (pu *ProfileUnPacker) decode() error {
+	if pu.decoded != nil {
+		return nil
+	}
+	decoded, err := pu.mergedProfile.Decoded(pu.resolver)
+	if err != nil {
+		return err
+	}
+	pu.decoded = decoded
+	return nil
+}
+
+// decode decodes merged profile like ProfileUnPacker.decode does
+func (gpu *GoroutineProfileUnPacker) decode() error {
+	if gpu.decoded != nil {
+		return nil
+	}
+	decoded, err := gpu.mergedProfile.Decoded()
+	if err != nil {
+		return err
+	}
+	gpu.decoded = decoded
+	return nil
+}
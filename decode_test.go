@@ -0,0 +1,120 @@
+package ppmerge
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+// run with -race: unpackers and readers share one encoded archive
+func TestDecodedConcurrent(t *testing.T) {
+	profiles := cumulativeProfiles(t, "hprof1", 4)
+	expected := NewProfileMerger().Merge(profiles...)
+	dict, err := NewSymbolDictionary(expected)
+	require.NoError(t, err)
+	resolver := dictionaryResolver(dict)
+
+	profileMerger := NewProfileMerger(WithDictionary(dict), WithDeltaValues(), WithFrontCodedStrings())
+	profileMerger.Merge(profiles...)
+	var uncompressed bytes.Buffer
+	require.NoError(t, profileMerger.WriteUncompressed(&uncompressed))
+	mergedProfile := new(MergedProfile)
+	require.NoError(t, mergedProfile.UnmarshalVT(uncompressed.Bytes()))
+	require.NotEmpty(t, mergedProfile.FrontCodedStrings)
+	require.NotEmpty(t, mergedProfile.Dictionary)
+	require.True(t, mergedProfile.DeltaValues)
+	original := proto.Clone(mergedProfile)
+
+	decoded, err := mergedProfile.Decoded(resolver)
+	require.NoError(t, err)
+	requireSameEntries(t, expected, decoded)
+	selector, err := ParseLabelSelector("bytes>=1")
+	require.NoError(t, err)
+	expectedTotals, err := expected.AggregateByLabel("bytes", "alloc_space")
+	require.NoError(t, err)
+	expectedIdxs, err := expected.FindEntries(selector)
+	require.NoError(t, err)
+
+	goroutinePaths := []string{"parca_goroutine_debug_1_1", "parca_goroutine_debug_1_2", "parca_goroutine_debug_1_3"}
+	expectedGoroutines := NewGoroutineProfileMerger().Merge(getGoroutineProfiles(t, goroutinePaths...)...)
+	goroutineMerger := NewGoroutineProfileMerger(WithDeltaGoroutineTotals(), WithFrontCodedGoroutineStrings())
+	goroutineMerger.Merge(getGoroutineProfiles(t, goroutinePaths...)...)
+	var compressed bytes.Buffer
+	require.NoError(t, goroutineMerger.WriteCompressed(&compressed))
+	goroutineProfile := new(MergedGoroutineProfile)
+	_, err = NewGoroutineProfileUnPacker(goroutineProfile).UnpackRaw(compressed.Bytes(), 0)
+	require.NoError(t, err)
+	require.True(t, goroutineProfile.DeltaTotals)
+	originalGoroutines := proto.Clone(goroutineProfile)
+	expectedLeaks, err := expectedGoroutines.Leaks(0, 0)
+	require.NoError(t, err)
+
+	const workers = 4
+	type result struct {
+		profiles, goroutines []string
+		entries              []uint64
+		totals               map[string]int64
+		leaks                []GoroutineLeak
+		errs                 []error
+	}
+	results := make([]result, workers)
+	var wg sync.WaitGroup
+	for i := range results {
+		wg.Add(1)
+		go func(res *result) {
+			defer wg.Done()
+			unpacker := NewProfileUnPacker(mergedProfile, WithDictionaryResolver(resolver))
+			for idx := range profiles {
+				p, err := unpacker.Unpack(uint64(idx))
+				res.errs = append(res.errs, err)
+				if err == nil {
+					res.profiles = append(res.profiles, p.String())
+				}
+			}
+			goroutineUnpacker := NewGoroutineProfileUnPacker(goroutineProfile)
+			for idx := range goroutinePaths {
+				gp, err := goroutineUnpacker.Unpack(uint64(idx))
+				res.errs = append(res.errs, err)
+				if err == nil {
+					res.goroutines = append(res.goroutines, gp.MarshalDebug())
+				}
+			}
+			var err error
+			res.entries, err = decoded.FindEntries(selector)
+			res.errs = append(res.errs, err)
+			res.totals, err = decoded.AggregateByLabel("bytes", "alloc_space")
+			res.errs = append(res.errs, err)
+			res.leaks, err = goroutineProfile.Leaks(0, 0)
+			res.errs = append(res.errs, err)
+		}(&results[i])
+	}
+	wg.Wait()
+
+	expectedUnpacker := NewProfileUnPacker(expected)
+	expectedGoroutineUnpacker := NewGoroutineProfileUnPacker(expectedGoroutines)
+	for _, res := range results {
+		for _, err := range res.errs {
+			require.NoError(t, err)
+		}
+		for idx, actual := range res.profiles {
+			p, err := expectedUnpacker.Unpack(uint64(idx))
+			require.NoError(t, err)
+			require.Equal(t, p.String(), actual)
+		}
+		for idx, actual := range res.goroutines {
+			gp, err := expectedGoroutineUnpacker.Unpack(uint64(idx))
+			require.NoError(t, err)
+			require.Equal(t, gp.MarshalDebug(), actual)
+		}
+		require.Equal(t, expectedIdxs, res.entries)
+		require.Equal(t, expectedTotals, res.totals)
+		require.Equal(t, expectedLeaks, res.leaks)
+	}
+
+	// encoded archives are left as is
+	require.True(t, proto.Equal(original, mergedProfile))
+	require.True(t, proto.Equal(originalGoroutines, goroutineProfile))
+}
@@ -0,0 +1,291 @@
+package ppmerge
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/threadedstream/ppmerge/profile"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// WithDeltaValues makes WriteCompressed, WriteUncompressed and StreamMerger store values of every
+// sample as difference with the identical sample of the previous entry of the same sample types,
+// see MergedProfile.DeltaEncoded. Cumulative values, like alloc_objects and alloc_space of heap
+// profiles taken one after another, then mostly turn into small numbers.
+func WithDeltaValues() MergerOption {
+	return func(pw *ProfileMerger) {
+		pw.deltaValues = true
+	}
+}
+
+// WithDeltaGoroutineTotals makes WriteCompressed store totals of entries and stacktraces
+// as differences with the previous entry, see MergedGoroutineProfile.DeltaEncoded
+func WithDeltaGoroutineTotals() GoroutineMergerOption {
+	return func(gpm *GoroutineProfileMerger) {
+		gpm.deltaTotals = true
+	}
+}
+
+// deltaCoder codes values of samples as differences with the identical sample of the previous
+// entry of the same series. Identical samples of one entry are matched in order of occurrence.
+type deltaCoder struct {
+	// absolute values of samples of the last entry of every series by sample key
+	prev map[string]map[string][]int64
+	// absolute values of samples of the current entry and number of occurrences of every key
+	entry       map[string][]int64
+	occurrences map[string]int
+}
+
+// code returns values of sample having key encoded, or decoded if decode is set
+func (dc *deltaCoder) code(series, key string, values []int64, decode bool) []int64 {
+	if dc.entry == nil {
+		dc.entry = make(map[string][]int64)
+		dc.occurrences = make(map[string]int)
+	}
+	n := dc.occurrences[key]
+	dc.occurrences[key] = n + 1
+	key += "#" + strconv.Itoa(n)
+
+	prev := dc.prev[series][key]
+	coded := make([]int64, len(values))
+	absolute := coded
+	if !decode {
+		absolute = values
+	}
+	for i, v := range values {
+		var base int64
+		if i < len(prev) {
+			base = prev[i]
+		}
+		if decode {
+			coded[i] = int64(protowire.DecodeZigZag(uint64(v))) + base
+		} else {
+			coded[i] = int64(protowire.EncodeZigZag(v - base))
+		}
+	}
+	dc.entry[key] = absolute
+	return coded
+}
+
+// finishEntry makes samples of the current entry the base of the next entry of series
+func (dc *deltaCoder) finishEntry(series string) {
+	if dc.prev == nil {
+		dc.prev = make(map[string]map[string][]int64)
+	}
+	if dc.entry == nil {
+		dc.entry = make(map[string][]int64)
+	}
+	dc.prev[series] = dc.entry
+	dc.entry, dc.occurrences = nil, nil
+}
+
+// profileSeries returns key of series of p, profiles have equal keys if and only if entries
+// they are merged into have equal keys returned by MergedProfile.entrySeries
+func profileSeries(p *profile.Profile) string {
+	var sb strings.Builder
+	for _, vt := range p.SampleType {
+		sb.WriteString(strconv.Quote(p.StringTable[vt.Type]))
+		sb.WriteString(strconv.Quote(p.StringTable[vt.Unit]))
+	}
+	sb.WriteByte(';')
+	if pt := p.PeriodType; pt != nil {
+		sb.WriteString(strconv.Quote(p.StringTable[pt.Type]))
+		sb.WriteString(strconv.Quote(p.StringTable[pt.Unit]))
+	}
+	return sb.String()
+}
+
+// DeltaEncoded returns copy of x with values of every sample replaced by zigzag encoded difference
+// with the identical sample of the previous entry having the same sample and period types. Samples
+// having no such sample are coded against zero. Unpackers decode such profiles transparently, other
+// readers must call Decoded first. Samples are copied, the rest is shared with x.
+func (x *MergedProfile) DeltaEncoded() *MergedProfile {
+	if x.DeltaValues {
+		return x
+	}
+
+	y := x.shallowCopy()
+	y.Samples = slices.Clone(x.Samples)
+	y.DeltaValues = true
+	x.codeDeltas(func(offset uint64, values []int64) {
+		y.Samples[offset] = &MergeSample{
+			LocationId: x.Samples[offset].LocationId,
+			Value:      values,
+		}
+	}, false)
+	return y
+}
+
+// DecodeDeltas restores values of samples encoded by DeltaEncoded, x with absolute values is left
+// as is. Samples are replaced rather than updated, so that copies of x sharing them are intact,
+// but x itself is modified and must not be read concurrently, see Decoded.
+func (x *MergedProfile) DecodeDeltas() error {
+	if !x.DeltaValues {
+		return nil
+	}
+
+	var offset uint64
+	for _, n := range x.NumSamples {
+		offset += n
+	}
+	if offset > uint64(len(x.Samples)) {
+		return indexOutOfRangeErr
+	}
+
+	samples := slices.Clone(x.Samples)
+	x.codeDeltas(func(offset uint64, values []int64) {
+		samples[offset] = &MergeSample{
+			LocationId: x.Samples[offset].LocationId,
+			Value:      values,
+		}
+	}, true)
+	x.Samples, x.DeltaValues = samples, false
+	return nil
+}
+
+// codeDeltas codes values of all samples of x entry by entry and passes them to put
+func (x *MergedProfile) codeDeltas(put func(offset uint64, values []int64), decode bool) {
+	var (
+		dc                  deltaCoder
+		offset, typesOffset uint64
+	)
+	for idx, numSamples := range x.NumSamples {
+		series := x.entrySeries(uint64(idx), typesOffset)
+		if idx < len(x.NumSampleTypes) {
+			typesOffset += x.NumSampleTypes[idx] * 2
+		}
+
+		for limit := offset + numSamples; offset < limit && offset < uint64(len(x.Samples)); offset++ {
+			sample := x.Samples[offset]
+			key := getSampleKey(sample, x.Labels[offset])
+			put(offset, dc.code(series, key, sample.Value, decode))
+		}
+		dc.finishEntry(series)
+	}
+}
+
+// entrySeries returns key of series of entry idx, i.e. ids of its sample and period types
+func (x *MergedProfile) entrySeries(idx, typesOffset uint64) string {
+	var sb strings.Builder
+	if idx < uint64(len(x.NumSampleTypes)) {
+		limit := min(typesOffset+x.NumSampleTypes[idx]*2, uint64(len(x.SampleType)))
+		for i := typesOffset; i < limit; i++ {
+			sb.WriteString(strconv.FormatInt(x.SampleType[i], 16))
+			sb.WriteByte(',')
+		}
+	}
+	sb.WriteByte(';')
+	if limit := idx*2 + 2; limit <= uint64(len(x.PeriodTypes)) {
+		sb.WriteString(strconv.FormatInt(x.PeriodTypes[idx*2], 16))
+		sb.WriteByte(',')
+		sb.WriteString(strconv.FormatInt(x.PeriodTypes[idx*2+1], 16))
+	}
+	return sb.String()
+}
+
+// DeltaEncoded returns copy of x with total of every entry replaced by zigzag encoded difference
+// with the previous one, and total of every stacktrace by difference with the stacktrace having
+// the same frames in the previous entry. Unpackers decode such profiles transparently, other
+// readers must call Decoded first. Stacktraces are copied, the rest is shared with x.
+func (x *MergedGoroutineProfile) DeltaEncoded() *MergedGoroutineProfile {
+	if x.DeltaTotals {
+		return x
+	}
+
+	y := x.shallowCopy()
+	y.Totals = make([]uint64, len(x.Totals))
+	y.Stacktraces = slices.Clone(x.Stacktraces)
+	y.DeltaTotals = true
+	x.codeDeltas(func(idx int, total uint64) {
+		y.Totals[idx] = total
+	}, func(offset int, total uint64) {
+		st := x.Stacktraces[offset]
+		y.Stacktraces[offset] = &profile.Stacktrace{
+			Total:  total,
+			PC:     st.PC,
+			Frames: st.Frames,
+			Labels: st.Labels,
+		}
+	}, false)
+	return y
+}
+
+// DecodeDeltas restores totals encoded by DeltaEncoded like MergedProfile.DecodeDeltas does
+func (x *MergedGoroutineProfile) DecodeDeltas() error {
+	if !x.DeltaTotals {
+		return nil
+	}
+
+	var offset uint64
+	for _, n := range x.NumStacktraces {
+		offset += n
+	}
+	if offset > uint64(len(x.Stacktraces)) {
+		return indexOutOfRangeErr
+	}
+
+	totals := make([]uint64, len(x.Totals))
+	stacktraces := slices.Clone(x.Stacktraces)
+	x.codeDeltas(func(idx int, total uint64) {
+		totals[idx] = total
+	}, func(offset int, total uint64) {
+		st := x.Stacktraces[offset]
+		stacktraces[offset] = &profile.Stacktrace{
+			Total:  total,
+			PC:     st.PC,
+			Frames: st.Frames,
+			Labels: st.Labels,
+		}
+	}, true)
+	x.Totals, x.Stacktraces, x.DeltaTotals = totals, stacktraces, false
+	return nil
+}
+
+// codeDeltas codes totals of all entries and stacktraces of x and passes them to putTotal
+// and putStacktrace respectively
+func (x *MergedGoroutineProfile) codeDeltas(putTotal func(idx int, total uint64), putStacktrace func(offset int, total uint64), decode bool) {
+	var (
+		dc     deltaCoder
+		offset int
+	)
+	for idx, total := range x.Totals {
+		putTotal(idx, uint64(dc.code("", "", []int64{int64(total)}, decode)[0]))
+		if idx >= len(x.NumStacktraces) {
+			dc.finishEntry("")
+			continue
+		}
+
+		for limit := offset + int(x.NumStacktraces[idx]); offset < limit && offset < len(x.Stacktraces); offset++ {
+			st := x.Stacktraces[offset]
+			putStacktrace(offset, uint64(dc.code("", getStacktraceKey(st), []int64{int64(st.Total)}, decode)[0]))
+		}
+		dc.finishEntry("")
+	}
+}
+
+// getStacktraceKey returns key of st, stacktraces having the same frames and labels have equal keys
+func getStacktraceKey(st *profile.Stacktrace) string {
+	var sb strings.Builder
+	// key of entry total is empty, so that it never matches key of a stacktrace
+	sb.WriteByte('|')
+	sb.WriteString(strconv.FormatUint(st.Labels, 16))
+	sb.WriteByte('|')
+	for _, pc := range st.PC {
+		sb.WriteString(strconv.FormatUint(pc, 16))
+		sb.WriteByte(',')
+	}
+	for _, f := range st.Frames {
+		sb.WriteByte('|')
+		sb.WriteString(strconv.FormatUint(f.FunctionName, 16))
+		sb.WriteByte(',')
+		sb.WriteString(strconv.FormatUint(f.Filename, 16))
+		sb.WriteByte(',')
+		sb.WriteString(strconv.FormatUint(f.Line, 16))
+		sb.WriteByte(',')
+		sb.WriteString(strconv.FormatUint(f.Offset, 16))
+		sb.WriteByte(',')
+		sb.WriteString(strconv.FormatUint(f.Address, 16))
+	}
+	return sb.String()
+}
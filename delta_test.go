@@ -0,0 +1,145 @@
+package ppmerge
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/threadedstream/ppmerge/profile"
+)
+
+// cumulativeProfiles returns n snapshots of heap profile at path, alloc values of every snapshot
+// are a bit larger than of the previous one, like in profiles of a running process
+func cumulativeProfiles(t *testing.T, path string, n int) []*profile.Profile {
+	ps := make([]*profile.Profile, 0, n)
+	for i := 0; i < n; i++ {
+		p := getProfilesVtProto(t, false, path)[0]
+		for _, s := range p.Sample {
+			values := make([]int64, len(s.Value))
+			copy(values, s.Value)
+			// alloc_objects and alloc_space precede inuse values
+			values[0] += int64(i * 3)
+			values[1] += int64(i * 3 * 512)
+			s.Value = values
+		}
+		p.TimeNanos += int64(i) * 10e9
+		ps = append(ps, p)
+	}
+	return ps
+}
+
+func TestDeltaValues(t *testing.T) {
+	for _, profiles := range [][]*profile.Profile{
+		cumulativeProfiles(t, "hprof1", 4),
+		getProfilesVtProto(t, false, "hprof1", "parca_cpu", "hprof2", "parca_cpu", "hprof3", "hprof4"),
+		duplicatedProfiles(t, "hprof1", "hprof2", "labels.prof", "multilabels.prof", "labels.prof"),
+	} {
+		expected := NewProfileMerger().Merge(profiles...)
+		plain, err := expected.MarshalDeterministic()
+		require.NoError(t, err)
+
+		encoded := expected.DeltaEncoded()
+		require.True(t, encoded.DeltaValues)
+		require.False(t, expected.DeltaValues)
+		data, err := encoded.MarshalDeterministic()
+		require.NoError(t, err)
+		plainCompressed, err := gzipSize(plain)
+		require.NoError(t, err)
+		encodedCompressed, err := gzipSize(data)
+		require.NoError(t, err)
+		t.Logf("archive: %d -> %d bytes, compressed %d -> %d bytes", len(plain), len(data), plainCompressed, encodedCompressed)
+
+		decoded := new(MergedProfile)
+		require.NoError(t, decoded.UnmarshalVT(data))
+		require.NoError(t, decoded.DecodeDeltas())
+		actual, err := decoded.MarshalDeterministic()
+		require.NoError(t, err)
+		require.Equal(t, plain, actual)
+
+		profileMerger := NewProfileMerger(WithDeltaValues(), WithFrontCodedStrings())
+		profileMerger.Merge(profiles...)
+		var compressed bytes.Buffer
+		require.NoError(t, profileMerger.WriteCompressed(&compressed))
+		unpacker := NewProfileUnPacker(nil)
+		for idx := range profiles {
+			expectedProfile, err := NewProfileUnPacker(expected).Unpack(uint64(idx))
+			require.NoError(t, err)
+			actual, err := unpacker.UnpackRaw(compressed.Bytes(), uint64(idx))
+			require.NoError(t, err)
+			require.Equal(t, expectedProfile.String(), actual.String())
+		}
+
+		for _, opts := range [][]MergerOption{{WithDeltaValues()}, {WithDeltaValues(), WithSampleAggregation()}} {
+			var streamed bytes.Buffer
+			sm := NewStreamMerger(&streamed, opts...)
+			for _, p := range profiles {
+				require.NoError(t, sm.Add(p))
+			}
+			require.NoError(t, sm.Close())
+			streamedProfile := new(MergedProfile)
+			require.NoError(t, streamedProfile.UnmarshalVT(streamed.Bytes()))
+			require.True(t, streamedProfile.DeltaValues)
+			requireSameTotals(t, expected, streamedProfile)
+		}
+	}
+}
+
+func TestDeltaValuesCumulative(t *testing.T) {
+	profiles := cumulativeProfiles(t, "hprof1", 8)
+	plainMerger := NewProfileMerger()
+	plainMerger.Merge(profiles...)
+	var plain bytes.Buffer
+	require.NoError(t, plainMerger.WriteCompressed(&plain))
+
+	profileMerger := NewProfileMerger(WithDeltaValues())
+	mergedProfile := profileMerger.Merge(profiles...)
+	require.False(t, mergedProfile.DeltaValues)
+	var compressed bytes.Buffer
+	require.NoError(t, profileMerger.WriteCompressed(&compressed))
+	t.Logf("archive: %d -> %d bytes compressed", plain.Len(), compressed.Len())
+	require.Less(t, compressed.Len(), plain.Len())
+
+	// queries decode values as well
+	var uncompressed bytes.Buffer
+	require.NoError(t, profileMerger.WriteUncompressed(&uncompressed))
+	decoded := new(MergedProfile)
+	require.NoError(t, decoded.UnmarshalVT(uncompressed.Bytes()))
+	expectedTotals, err := mergedProfile.AggregateByLabel("bytes", "alloc_space")
+	require.NoError(t, err)
+	totals, err := decoded.AggregateByLabel("bytes", "alloc_space")
+	require.NoError(t, err)
+	require.Equal(t, expectedTotals, totals)
+}
+
+func TestDeltaGoroutineTotals(t *testing.T) {
+	paths := []string{"parca_goroutine_debug_1_1", "parca_goroutine_debug_1_2", "parca_goroutine_debug_1_3", "parca_goroutine_debug_1_1"}
+	mergedProfile := NewGoroutineProfileMerger().Merge(getGoroutineProfiles(t, paths...)...)
+	plain, err := mergedProfile.MarshalVT()
+	require.NoError(t, err)
+
+	encoded := mergedProfile.DeltaEncoded()
+	data, err := encoded.MarshalVT()
+	require.NoError(t, err)
+	decoded := new(MergedGoroutineProfile)
+	require.NoError(t, decoded.UnmarshalVT(data))
+	require.True(t, decoded.DeltaTotals)
+	require.NoError(t, decoded.DecodeDeltas())
+	actual, err := decoded.MarshalVT()
+	require.NoError(t, err)
+	require.Equal(t, plain, actual)
+
+	goroutineMerger := NewGoroutineProfileMerger(WithDeltaGoroutineTotals(), WithFrontCodedGoroutineStrings())
+	goroutineMerger.Merge(getGoroutineProfiles(t, paths...)...)
+	var compressed bytes.Buffer
+	require.NoError(t, goroutineMerger.WriteCompressed(&compressed))
+
+	expectedUnpacker := NewGoroutineProfileUnPacker(mergedProfile)
+	unpacker := NewGoroutineProfileUnPacker(nil)
+	for idx := range paths {
+		expected, err := expectedUnpacker.Unpack(uint64(idx))
+		require.NoError(t, err)
+		actual, err := unpacker.UnpackRaw(compressed.Bytes(), uint64(idx))
+		require.NoError(t, err)
+		require.Equal(t, expected.String(), actual.String())
+	}
+}
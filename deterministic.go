@@ -0,0 +1,88 @@
+package ppmerge
+
+import (
+	"slices"
+	"sort"
+
+	"github.com/threadedstream/ppmerge/profile"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// labelsFieldNumber is the number of labels field of MergedProfile
+const labelsFieldNumber = 16
+
+// MarshalDeterministic marshals x like MarshalVT does, but writes labels ordered by sample offset
+// instead of map iteration order, so that equal profiles are always encoded to the same bytes.
+func (x *MergedProfile) MarshalDeterministic() ([]byte, error) {
+	if len(x.Labels) <= 1 {
+		return x.MarshalVT()
+	}
+
+	withoutLabels := x.shallowCopy()
+	withoutLabels.Labels = nil
+	data, err := withoutLabels.MarshalVT()
+	if err != nil {
+		return nil, err
+	}
+
+	offsets := make([]uint64, 0, len(x.Labels))
+	for offset := range x.Labels {
+		offsets = append(offsets, offset)
+	}
+	sort.Slice(offsets, func(i, j int) bool {
+		return offsets[i] < offsets[j]
+	})
+
+	for _, offset := range offsets {
+		if data, err = appendLabelsEntry(data, offset, x.Labels[offset]); err != nil {
+			return nil, err
+		}
+	}
+
+	return data, nil
+}
+
+// shallowCopy returns copy of x sharing all of its fields
+func (x *MergedProfile) shallowCopy() *MergedProfile {
+	return shallowCopy(x)
+}
+
+// shallowCopy returns copy of x sharing all of its fields
+func (x *MergedGoroutineProfile) shallowCopy() *MergedGoroutineProfile {
+	return shallowCopy(x)
+}
+
+// shallowCopy returns copy of x sharing all of its fields. Fields are copied by reflection,
+// so that fields added to messages later are never left out.
+func shallowCopy[T proto.Message](x T) T {
+	src := x.ProtoReflect()
+	dst := src.New()
+	src.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		// setting list or map value shares it instead of copying
+		dst.Set(fd, v)
+		return true
+	})
+	return dst.Interface().(T)
+}
+
+// appendLabelsEntry appends entry of labels map field of MergedProfile to data
+func appendLabelsEntry(data []byte, offset uint64, labels *profile.Labels) ([]byte, error) {
+	size := labels.SizeVT()
+	entrySize := protowire.SizeTag(1) + protowire.SizeVarint(offset) +
+		protowire.SizeTag(2) + protowire.SizeBytes(size)
+
+	data = protowire.AppendTag(data, labelsFieldNumber, protowire.BytesType)
+	data = protowire.AppendVarint(data, uint64(entrySize))
+	data = protowire.AppendTag(data, 1, protowire.VarintType)
+	data = protowire.AppendVarint(data, offset)
+	data = protowire.AppendTag(data, 2, protowire.BytesType)
+	data = protowire.AppendVarint(data, uint64(size))
+
+	data = slices.Grow(data, size)
+	if _, err := labels.MarshalToSizedBufferVT(data[len(data) : len(data)+size]); err != nil {
+		return nil, err
+	}
+	return data[:len(data)+size], nil
+}
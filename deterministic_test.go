@@ -0,0 +1,147 @@
+package ppmerge
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+func TestMarshalDeterministic(t *testing.T) {
+	paths := []string{"labels.prof", "hprof1", "parca_cpu", "labels.prof"}
+
+	var expected []byte
+	for i := 0; i < 20; i++ {
+		profileMerger := NewProfileMerger()
+		mergedProfile := profileMerger.Merge(getProfilesVtProto(t, false, paths...)...)
+		require.Greater(t, len(mergedProfile.Labels), 1)
+
+		bb := bytes.NewBuffer(nil)
+		require.NoError(t, profileMerger.WriteCompressed(bb))
+		if expected == nil {
+			expected = bb.Bytes()
+			continue
+		}
+		require.Equal(t, expected, bb.Bytes(), "run %d", i)
+	}
+
+	mergedProfile := NewProfileMerger().Merge(getProfilesVtProto(t, false, paths...)...)
+	data, err := mergedProfile.MarshalDeterministic()
+	require.NoError(t, err)
+
+	decoded := new(MergedProfile)
+	require.NoError(t, decoded.UnmarshalVT(data))
+	require.True(t, proto.Equal(mergedProfile, decoded))
+
+	vtData, err := mergedProfile.MarshalVT()
+	require.NoError(t, err)
+	require.Len(t, data, len(vtData))
+}
+
+func TestMarshalDeterministicParallel(t *testing.T) {
+	paths := []string{"labels.prof", "hprof1", "hprof2", "labels.prof"}
+
+	expected, err := NewProfileMerger().Merge(getProfilesVtProto(t, false, paths...)...).MarshalDeterministic()
+	require.NoError(t, err)
+
+	actual, err := NewProfileMerger().MergeParallel(2, getProfilesVtProto(t, false, paths...)...).MarshalDeterministic()
+	require.NoError(t, err)
+	require.Equal(t, expected, actual)
+}
+
+func TestGoroutineMergerDeterministic(t *testing.T) {
+	var expected []byte
+	for i := 0; i < 20; i++ {
+		profileMerger := NewGoroutineProfileMerger()
+		profileMerger.Merge(getGoroutineProfiles(t, "parca_goroutine_debug_1_1", "parca_goroutine_debug_1_2", "parca_goroutine_debug_1_3")...)
+
+		bb := bytes.NewBuffer(nil)
+		require.NoError(t, profileMerger.WriteCompressed(bb))
+		if expected == nil {
+			expected = bb.Bytes()
+			continue
+		}
+		require.Equal(t, expected, bb.Bytes(), "run %d", i)
+	}
+}
+
+func TestShallowCopy(t *testing.T) {
+	for _, x := range []proto.Message{new(MergedProfile), new(MergedGoroutineProfile)} {
+		populateFields(x.ProtoReflect())
+
+		var y proto.Message
+		switch x := x.(type) {
+		case *MergedProfile:
+			y = x.shallowCopy()
+			data, err := x.MarshalDeterministic()
+			require.NoError(t, err)
+			decoded := new(MergedProfile)
+			require.NoError(t, decoded.UnmarshalVT(data))
+			require.True(t, proto.Equal(x, decoded))
+		case *MergedGoroutineProfile:
+			y = x.shallowCopy()
+		}
+
+		fields := x.ProtoReflect().Descriptor().Fields()
+		for i := 0; i < fields.Len(); i++ {
+			require.True(t, y.ProtoReflect().Has(fields.Get(i)), "%s", fields.Get(i).FullName())
+		}
+		require.True(t, proto.Equal(x, y))
+	}
+}
+
+func TestFieldNumbers(t *testing.T) {
+	// fields encoded by hand must match the messages
+	fields := new(MergedProfile).ProtoReflect().Descriptor().Fields()
+	require.Equal(t, protoreflect.FieldNumber(labelsFieldNumber), fields.ByName("labels").Number())
+	require.Equal(t, protoreflect.FieldNumber(samplesFieldNumber), fields.ByName("samples").Number())
+	fields = new(ShardedProfile).ProtoReflect().Descriptor().Fields()
+	require.Equal(t, protoreflect.FieldNumber(shardsFieldNumber), fields.ByName("shards").Number())
+}
+
+// populateFields sets every field of m to a non-default value
+func populateFields(m protoreflect.Message) {
+	fields := m.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		switch {
+		case fd.IsList():
+			list := m.Mutable(fd).List()
+			list.Append(populatedValue(fd, list.NewElement))
+		case fd.IsMap():
+			mp := m.Mutable(fd).Map()
+			for key := uint64(1); key <= 2; key++ {
+				mp.Set(protoreflect.ValueOfUint64(key).MapKey(), populatedValue(fd.MapValue(), mp.NewValue))
+			}
+		default:
+			m.Set(fd, populatedValue(fd, func() protoreflect.Value { return m.NewField(fd) }))
+		}
+	}
+}
+
+func populatedValue(fd protoreflect.FieldDescriptor, newValue func() protoreflect.Value) protoreflect.Value {
+	switch fd.Kind() {
+	case protoreflect.MessageKind:
+		v := newValue()
+		populateFields(v.Message())
+		return v
+	case protoreflect.BoolKind:
+		return protoreflect.ValueOfBool(true)
+	case protoreflect.StringKind:
+		return protoreflect.ValueOfString("x")
+	case protoreflect.BytesKind:
+		return protoreflect.ValueOfBytes([]byte{1})
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return protoreflect.ValueOfInt64(1)
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return protoreflect.ValueOfUint64(1)
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		return protoreflect.ValueOfInt32(1)
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return protoreflect.ValueOfUint32(1)
+	default:
+		panic("unexpected kind " + fd.Kind().String())
+	}
+}
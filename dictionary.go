@@ -0,0 +1,258 @@
+package ppmerge
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// dictionaryKeyPrefix prefixes hashes of dictionary contents keys end with
+const dictionaryKeyPrefix = "sha256:"
+
+var (
+	noDictionaryResolverErr = errors.New("archive refers to symbol dictionary, but no resolver is given")
+	dictionaryMismatchErr   = errors.New("symbol dictionary doesn't match archive")
+)
+
+// DictionaryResolver returns symbol dictionary having key
+type DictionaryResolver interface {
+	Resolve(key string) (*SymbolDictionary, error)
+}
+
+// DictionaryResolverFunc is an adapter to use ordinary function as DictionaryResolver
+type DictionaryResolverFunc func(key string) (*SymbolDictionary, error)
+
+func (f DictionaryResolverFunc) Resolve(key string) (*SymbolDictionary, error) {
+	return f(key)
+}
+
+// UnPackerOption configures ProfileUnPacker
+type UnPackerOption func(pu *ProfileUnPacker)
+
+// WithDictionaryResolver makes unpacker resolve symbol dictionaries archives written
+// with WithDictionary refer to
+func WithDictionaryResolver(resolver DictionaryResolver) UnPackerOption {
+	return func(pu *ProfileUnPacker) {
+		pu.resolver = resolver
+	}
+}
+
+// NewSymbolDictionary returns dictionary holding functions and mappings of x along with strings
+// they refer to. Dictionary is keyed by hash of its contents prefixed with build ID of the first
+// mapping having one, i.e. "<build ID>/sha256:<hex>", so that dictionaries built from different
+// archives of the same binary get different keys. Build dictionary from an archive of a typical
+// process of the binary and store it once, then pass it to WithDictionary when merging other
+// profiles of the same binary.
+func NewSymbolDictionary(x *MergedProfile) (*SymbolDictionary, error) {
+	x, err := x.Decoded(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	dict := &SymbolDictionary{
+		StringTable: []string{""},
+		Functions:   make([]*MergeFunction, 0, len(x.Functions)),
+		Mappings:    make([]*MergeMapping, 0, len(x.Mappings)),
+	}
+	// strings are interned the way merger does, so that the empty string gets an id of its own
+	stringIDs := make(map[string]int64)
+	putString := func(id int64) int64 {
+		if id < 0 || id >= int64(len(x.StringTable)) {
+			return 0
+		}
+		s := x.StringTable[id]
+		newID, ok := stringIDs[s]
+		if !ok {
+			newID = int64(len(dict.StringTable))
+			stringIDs[s] = newID
+			dict.StringTable = append(dict.StringTable, s)
+		}
+		return newID
+	}
+
+	for i, fn := range x.Functions {
+		fn = fn.clone()
+		fn.Id = uint64(i + 1)
+		fn.Name, fn.SystemName, fn.Filename = putString(fn.Name), putString(fn.SystemName), putString(fn.Filename)
+		dict.Functions = append(dict.Functions, fn)
+	}
+	var buildID string
+	for i, m := range x.Mappings {
+		m = m.clone()
+		m.Id = uint64(i + 1)
+		m.Filename, m.BuildId = putString(m.Filename), putString(m.BuildId)
+		dict.Mappings = append(dict.Mappings, m)
+		if buildID == "" && m.BuildId > 0 {
+			buildID = dict.StringTable[m.BuildId]
+		}
+	}
+
+	hash, err := dict.hash()
+	if err != nil {
+		return nil, err
+	}
+	dict.Key = hash
+	if buildID != "" {
+		dict.Key = buildID + "/" + hash
+	}
+	return dict, nil
+}
+
+// hash returns hash of contents of x, its key aside
+func (x *SymbolDictionary) hash() (string, error) {
+	contents := &SymbolDictionary{
+		StringTable: x.StringTable,
+		Functions:   x.Functions,
+		Mappings:    x.Mappings,
+	}
+	data, err := contents.MarshalVT()
+	if err != nil {
+		return "", errors.Wrap(err, "marshal dictionary")
+	}
+	sum := sha256.Sum256(data)
+	return dictionaryKeyPrefix + hex.EncodeToString(sum[:]), nil
+}
+
+// WithDictionary makes merger start from tables of dict, so that functions, mappings and strings
+// of the same binary aren't written again. Archives written by WriteCompressed, WriteUncompressed
+// and StreamMerger then hold only the key of dict and the rest of tables, unpackers resolve dict
+// by key, see WithDictionaryResolver. Merged profile returned by Merge holds full tables.
+// dict must be built by NewSymbolDictionary and must not be modified afterwards.
+func WithDictionary(dict *SymbolDictionary) MergerOption {
+	return func(pw *ProfileMerger) {
+		pw.dictionary = dict
+	}
+}
+
+// seedDictionary interns tables of dictionary of pw, it's called whenever merger starts from scratch
+func (pw *ProfileMerger) seedDictionary() {
+	dict := pw.dictionary
+	if dict == nil {
+		return
+	}
+
+	for id := 1; id < len(dict.StringTable); id++ {
+		pw.internString(dict.StringTable[id])
+	}
+
+	mp := pw.mergedProfile
+	for _, m := range dict.Mappings {
+		m = m.clone()
+		key := pw.getMappingKey(m)
+		pw.mappingTable[key] = m.Id
+		if pw.normalizeAddresses && m.MemoryStart == 0 && key.buildIDOrFile > 0 {
+			// mappings of dictionary built from normalized archive are relative
+			key.normalized = true
+			pw.mappingTable[key] = m.Id
+		}
+		mp.Mappings = append(mp.Mappings, m)
+	}
+	for _, fn := range dict.Functions {
+		fn = fn.clone()
+		pw.functionTable[pw.getFunctionKey(fn)] = fn.Id
+		mp.Functions = append(mp.Functions, fn)
+	}
+}
+
+// written returns merged profile the way it's written: without tables of dictionary, with
+// delta encoded values and front coded strings if options say so
+func (pw *ProfileMerger) written() *MergedProfile {
+	mp := pw.mergedProfile
+	if pw.deltaValues {
+		mp = mp.DeltaEncoded()
+	}
+	if pw.dictionary != nil {
+		mp = mp.withoutDictionary(pw.dictionary)
+	}
+	if pw.frontCodeStrings {
+		mp = mp.FrontCoded()
+	}
+	return mp
+}
+
+// withoutDictionary returns copy of x referring to dict instead of holding its tables
+func (x *MergedProfile) withoutDictionary(dict *SymbolDictionary) *MergedProfile {
+	y := x.shallowCopy()
+	y.Dictionary = dict.Key
+	y.DictionaryStrings = uint64(len(dict.StringTable))
+	y.StringTable = x.StringTable[min(len(dict.StringTable), len(x.StringTable)):]
+	y.Functions = x.Functions[min(len(dict.Functions), len(x.Functions)):]
+	y.Mappings = x.Mappings[min(len(dict.Mappings), len(x.Mappings)):]
+	return y
+}
+
+// AttachDictionary restores tables of x taken from dict, x referring to no dictionary is left
+// as is. Contents of dict must match the hash its key ends with, so that dictionary rebuilt under
+// the same build ID isn't taken for the one x was written with. It modifies x, so it must not run
+// concurrently with other readers of x, see Decoded.
+func (x *MergedProfile) AttachDictionary(dict *SymbolDictionary) error {
+	if x.Dictionary == "" {
+		return nil
+	}
+	if dict == nil || dict.Key != x.Dictionary || uint64(len(dict.StringTable)) != x.DictionaryStrings {
+		return errors.Wrapf(dictionaryMismatchErr, "dictionary %s", x.Dictionary)
+	}
+	hash, err := dict.hash()
+	if err != nil {
+		return err
+	}
+	if !strings.HasSuffix(x.Dictionary, hash) {
+		return errors.Wrapf(dictionaryMismatchErr, "dictionary %s has contents of %s", x.Dictionary, hash)
+	}
+	if err := x.DecodeStrings(); err != nil {
+		return errors.Wrap(err, "decode strings")
+	}
+
+	x.StringTable = append(append(make([]string, 0, len(dict.StringTable)+len(x.StringTable)), dict.StringTable...), x.StringTable...)
+
+	functions := make([]*MergeFunction, 0, len(dict.Functions)+len(x.Functions))
+	for _, fn := range dict.Functions {
+		functions = append(functions, fn.clone())
+	}
+	x.Functions = append(functions, x.Functions...)
+
+	mappings := make([]*MergeMapping, 0, len(dict.Mappings)+len(x.Mappings))
+	for _, m := range dict.Mappings {
+		mappings = append(mappings, m.clone())
+	}
+	x.Mappings = append(mappings, x.Mappings...)
+
+	x.Dictionary, x.DictionaryStrings = "", 0
+	return nil
+}
+
+// decodeTables decodes tables of x in place for readers rewriting them: front coded strings are
+// decoded, while archives referring to dictionary must have it attached with AttachDictionary first
+func (x *MergedProfile) decodeTables() error {
+	if x.Dictionary != "" {
+		return errors.Errorf("dictionary %s isn't attached", x.Dictionary)
+	}
+	return x.DecodeStrings()
+}
+
+func (x *MergeFunction) clone() *MergeFunction {
+	return &MergeFunction{
+		Id:         x.Id,
+		Name:       x.Name,
+		SystemName: x.SystemName,
+		Filename:   x.Filename,
+		StartLine:  x.StartLine,
+	}
+}
+
+func (x *MergeMapping) clone() *MergeMapping {
+	return &MergeMapping{
+		Id:              x.Id,
+		MemoryStart:     x.MemoryStart,
+		MemoryLimit:     x.MemoryLimit,
+		FileOffset:      x.FileOffset,
+		Filename:        x.Filename,
+		BuildId:         x.BuildId,
+		HasFunctions:    x.HasFunctions,
+		HasFilenames:    x.HasFilenames,
+		HasLineNumbers:  x.HasLineNumbers,
+		HasInlineFrames: x.HasInlineFrames,
+	}
+}
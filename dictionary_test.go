@@ -0,0 +1,167 @@
+package ppmerge
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+// dictionaryResolver returns resolver knowing dicts only
+func dictionaryResolver(dicts ...*SymbolDictionary) DictionaryResolver {
+	return DictionaryResolverFunc(func(key string) (*SymbolDictionary, error) {
+		for _, dict := range dicts {
+			if dict.Key == key {
+				return dict, nil
+			}
+		}
+		return nil, errors.Errorf("unknown dictionary %s", key)
+	})
+}
+
+func TestSymbolDictionary(t *testing.T) {
+	dict, err := NewSymbolDictionary(NewProfileMerger().Merge(getProfilesVtProto(t, false, "hprof1", "hprof2")...))
+	require.NoError(t, err)
+	buildID, hash, ok := strings.Cut(dict.Key, "/")
+	require.True(t, ok, dict.Key)
+	require.NotEmpty(t, buildID)
+	require.True(t, strings.HasPrefix(hash, dictionaryKeyPrefix), dict.Key)
+	require.Equal(t, "", dict.StringTable[0])
+
+	paths := []string{"hprof3", "hprof4"}
+	profiles := getProfilesVtProto(t, false, paths...)
+	expected := NewProfileMerger().Merge(profiles...)
+
+	plainMerger := NewProfileMerger()
+	plainMerger.Merge(profiles...)
+	var plain bytes.Buffer
+	require.NoError(t, plainMerger.WriteCompressed(&plain))
+
+	profileMerger := NewProfileMerger(WithDictionary(dict))
+	mergedProfile := profileMerger.Merge(profiles...)
+	require.Empty(t, mergedProfile.Dictionary)
+	requireSameEntries(t, expected, mergedProfile)
+
+	var compressed bytes.Buffer
+	require.NoError(t, profileMerger.WriteCompressed(&compressed))
+	t.Logf("archive: %d -> %d bytes compressed, dictionary: %d bytes", plain.Len(), compressed.Len(), dict.SizeVT())
+	require.Less(t, compressed.Len(), plain.Len())
+
+	unpacker := NewProfileUnPacker(nil, WithDictionaryResolver(dictionaryResolver(dict)))
+	for idx := range paths {
+		expectedProfile, err := NewProfileUnPacker(expected).Unpack(uint64(idx))
+		require.NoError(t, err)
+		actual, err := unpacker.UnpackRaw(compressed.Bytes(), uint64(idx))
+		require.NoError(t, err)
+		require.Equal(t, expectedProfile.String(), actual.String())
+	}
+
+	_, err = NewProfileUnPacker(nil).UnpackRaw(compressed.Bytes(), 0)
+	require.Error(t, err)
+	other, err := NewSymbolDictionary(NewProfileMerger().Merge(getProfilesVtProto(t, false, "hprof1")...))
+	require.NoError(t, err)
+	other.StringTable = other.StringTable[:1]
+	_, err = NewProfileUnPacker(nil, WithDictionaryResolver(dictionaryResolver(other))).UnpackRaw(compressed.Bytes(), 0)
+	require.Error(t, err)
+
+	// dictionary of another archive of the same binary has a key of its own
+	other, err = NewSymbolDictionary(NewProfileMerger().Merge(getProfilesVtProto(t, false, "hprof1")...))
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(other.Key, buildID+"/"), other.Key)
+	require.NotEqual(t, dict.Key, other.Key)
+	// and it can't pass for dict, even with as many strings
+	other.Key = dict.Key
+	other.StringTable = append(other.StringTable, make([]string, len(dict.StringTable)-len(other.StringTable))...)
+	_, err = NewProfileUnPacker(nil, WithDictionaryResolver(dictionaryResolver(other))).UnpackRaw(compressed.Bytes(), 0)
+	require.ErrorIs(t, err, dictionaryMismatchErr)
+
+	// archives referring to dictionary can't be queried until it's attached
+	var uncompressed bytes.Buffer
+	require.NoError(t, profileMerger.WriteUncompressed(&uncompressed))
+	written := new(MergedProfile)
+	require.NoError(t, written.UnmarshalVT(uncompressed.Bytes()))
+	require.Equal(t, dict.Key, written.Dictionary)
+	_, err = written.AggregateByLabel("bytes", "")
+	require.Error(t, err)
+	require.NoError(t, written.AttachDictionary(dict))
+	requireSameEntries(t, expected, written)
+
+	// merger started from scratch starts from dictionary again
+	profileMerger.Reset()
+	require.True(t, proto.Equal(mergedProfile, profileMerger.Merge(profiles...)))
+}
+
+func TestSymbolDictionaryWriters(t *testing.T) {
+	dict, err := NewSymbolDictionary(NewProfileMerger().Merge(getProfilesVtProto(t, false, "hprof1", "hprof2")...))
+	require.NoError(t, err)
+	resolver := WithDictionaryResolver(dictionaryResolver(dict))
+
+	paths := []string{"hprof3", "hprof4", "parca_heap"}
+	profiles := getProfilesVtProto(t, false, paths...)
+	expected := NewProfileMerger().Merge(profiles...)
+
+	sequential := NewProfileMerger(WithDictionary(dict)).Merge(profiles...)
+	for _, workers := range []int{1, 2, 4} {
+		actual := NewProfileMerger(WithDictionary(dict)).MergeParallel(workers, profiles...)
+		require.True(t, proto.Equal(sequential, actual), "workers %d", workers)
+	}
+
+	frontCodedMerger := NewProfileMerger(WithDictionary(dict), WithFrontCodedStrings())
+	frontCodedMerger.Merge(profiles...)
+	var frontCoded bytes.Buffer
+	require.NoError(t, frontCodedMerger.WriteCompressed(&frontCoded))
+	unpacker := NewProfileUnPacker(nil, resolver)
+	for idx := range paths {
+		expectedProfile, err := NewProfileUnPacker(expected).Unpack(uint64(idx))
+		require.NoError(t, err)
+		actual, err := unpacker.UnpackRaw(frontCoded.Bytes(), uint64(idx))
+		require.NoError(t, err)
+		require.Equal(t, expectedProfile.String(), actual.String())
+	}
+
+	filePaths := make([]string, len(paths))
+	for i, path := range paths {
+		filePaths[i] = filepath.Join("testdata", path)
+	}
+	for _, opts := range [][]MergerOption{{WithDictionary(dict)}, {WithDictionary(dict), WithFrontCodedStrings()}} {
+		var streamed bytes.Buffer
+		require.NoError(t, MergeStream(&streamed, FileIterator(filePaths...), opts...))
+		streamedProfile := new(MergedProfile)
+		require.NoError(t, streamedProfile.UnmarshalVT(streamed.Bytes()))
+		require.Equal(t, dict.Key, streamedProfile.Dictionary)
+		require.NoError(t, streamedProfile.AttachDictionary(dict))
+		requireSameEntries(t, expected, streamedProfile)
+	}
+
+	shardedMerger := NewShardedMerger(WithDictionary(dict))
+	shardedMerger.Merge(profiles...)
+	var sharded bytes.Buffer
+	require.NoError(t, shardedMerger.WriteCompressed(&sharded))
+	shardedUnpacker := NewShardedProfileUnPacker(nil, resolver)
+	for idx := range paths {
+		expectedProfile, err := NewProfileUnPacker(expected).Unpack(uint64(idx))
+		require.NoError(t, err)
+		actual, err := shardedUnpacker.UnpackRaw(sharded.Bytes(), uint64(idx))
+		require.NoError(t, err)
+		require.Equal(t, expectedProfile.String(), actual.String())
+	}
+}
+
+func TestSymbolDictionaryContentKey(t *testing.T) {
+	paths := []string{"parca_cpu", "parca_heap"}
+	dict, err := NewSymbolDictionary(NewProfileMerger().Merge(getProfilesVtProto(t, false, paths...)...))
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(dict.Key, dictionaryKeyPrefix))
+
+	again, err := NewSymbolDictionary(NewProfileMerger().Merge(getProfilesVtProto(t, false, paths...)...))
+	require.NoError(t, err)
+	require.Equal(t, dict.Key, again.Key)
+
+	other, err := NewSymbolDictionary(NewProfileMerger().Merge(getProfilesVtProto(t, false, "parca_cpu")...))
+	require.NoError(t, err)
+	require.NotEqual(t, dict.Key, other.Key)
+}
@@ -0,0 +1,153 @@
+package ppmerge
+
+import (
+	"html/template"
+	"io"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// flameNode is a node of flame graph tree
+type flameNode struct {
+	Name     string       `json:"n"`
+	Value    int64        `json:"v"`
+	Children []*flameNode `json:"c,omitempty"`
+
+	childByName map[string]*flameNode
+}
+
+func (fn *flameNode) add(frames []stackFrame, v int64) {
+	fn.Value += v
+	node := fn
+	for _, f := range frames {
+		child, ok := node.childByName[f.name]
+		if !ok {
+			child = &flameNode{Name: f.name}
+			if node.childByName == nil {
+				node.childByName = make(map[string]*flameNode)
+			}
+			node.childByName[f.name] = child
+			node.Children = append(node.Children, child)
+		}
+		child.Value += v
+		node = child
+	}
+}
+
+// sort orders children by name, so that output doesn't depend on sample order
+func (fn *flameNode) sort() {
+	sort.Slice(fn.Children, func(i, j int) bool {
+		return fn.Children[i].Name < fn.Children[j].Name
+	})
+	for _, child := range fn.Children {
+		child.sort()
+	}
+}
+
+type flameGraphPage struct {
+	Title string
+	Unit  string
+	Root  *flameNode
+}
+
+// WriteFlameGraphHTML writes entries idxs aggregated together as a self-contained
+// HTML page rendering them as an interactive flame graph. Values are taken from
+// sampleType, the last sample type of entry is used if it's empty.
+func (pu *ProfileUnPacker) WriteFlameGraphHTML(w io.Writer, sampleType string, idxs ...uint64) error {
+	page := flameGraphPage{
+		Root: &flameNode{Name: "root"},
+	}
+
+	for _, idx := range idxs {
+		p, err := pu.Unpack(idx)
+		if err != nil {
+			return errors.Wrapf(err, "unpack profile %d", idx)
+		}
+
+		valueIdx := sampleTypeIndex(p, sampleType)
+		if valueIdx < 0 {
+			return errors.Errorf("profile %d has no sample type %q", idx, sampleType)
+		}
+		page.Title = p.SampleType[valueIdx].Type
+		page.Unit = p.SampleType[valueIdx].Unit
+
+		for _, s := range p.Sample {
+			if v := s.Value[valueIdx]; v != 0 {
+				page.Root.add(sampleFrames(s), v)
+			}
+		}
+	}
+	page.Root.sort()
+
+	return flameGraphTemplate.Execute(w, page)
+}
+
+var flameGraphTemplate = template.Must(template.New("flamegraph").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}} flame graph</title>
+<style>
+body { font: 12px monospace; margin: 8px; }
+#info { height: 18px; white-space: nowrap; overflow: hidden; }
+#graph { position: relative; width: 100%; }
+.frame { position: absolute; height: 17px; box-sizing: border-box; border: 1px solid #fff; overflow: hidden;
+	white-space: nowrap; cursor: pointer; padding-left: 2px; line-height: 15px; }
+.frame:hover { border-color: #000; }
+</style>
+</head>
+<body>
+<div id="info">{{.Title}} ({{.Unit}}), click a frame to zoom in, click root to reset</div>
+<div id="graph"></div>
+<script>
+const unit = {{.Unit}};
+const root = {{.Root}};
+const graph = document.getElementById("graph");
+const info = document.getElementById("info");
+const rowHeight = 18;
+
+function depth(node) {
+	return 1 + Math.max(0, ...(node.c || []).map(depth));
+}
+
+function color(name) {
+	let h = 0;
+	for (let i = 0; i < name.length; i++) h = (h * 31 + name.charCodeAt(i)) >>> 0;
+	return "hsl(" + (10 + h % 40) + ",85%," + (55 + h % 15) + "%)";
+}
+
+function render(focus) {
+	graph.innerHTML = "";
+	graph.style.height = depth(focus) * rowHeight + "px";
+	const width = graph.clientWidth;
+	const draw = (node, x, level, total) => {
+		const w = node.v / total * width;
+		if (w < 1) return;
+		const div = document.createElement("div");
+		div.className = "frame";
+		div.style.left = x + "px";
+		div.style.top = level * rowHeight + "px";
+		div.style.width = w + "px";
+		div.style.background = color(node.n);
+		div.textContent = node.n;
+		const pct = (node.v / root.v * 100).toFixed(2);
+		div.title = node.n + " " + node.v + " " + unit + " (" + pct + "%)";
+		div.onmouseover = () => { info.textContent = div.title; };
+		div.onclick = () => render(node === focus ? root : node);
+		graph.appendChild(div);
+		let cx = x;
+		for (const child of node.c || []) {
+			draw(child, cx, level + 1, total);
+			cx += child.v / total * width;
+		}
+	};
+	draw(focus, 0, 0, focus.v || 1);
+}
+
+render(root);
+window.onresize = () => render(root);
+</script>
+</body>
+</html>
+`))
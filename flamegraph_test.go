@@ -0,0 +1,52 @@
+package ppmerge
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteFlameGraphHTML(t *testing.T) {
+	profiles := getProfilesVtProto(t, false, "parca_cpu")
+	actualProfile := getProfiles(t, "parca_cpu")[0]
+
+	mergedProfile := NewProfileMerger().Merge(profiles...)
+
+	bb := bytes.NewBuffer(nil)
+	require.NoError(t, NewProfileUnPacker(mergedProfile).WriteFlameGraphHTML(bb, "cpu", 0))
+
+	html := bb.String()
+	require.True(t, strings.HasPrefix(html, "<!DOCTYPE html>"))
+	require.Contains(t, html, "<title>cpu flame graph</title>")
+	require.Contains(t, html, `"n":"github.com/dgraph-io/ristretto.(*Cache).processItems"`)
+	require.NotContains(t, html, "http://", "page must be self-contained")
+
+	// tree embedded into page must add up to totals of the original profile
+	const prefix, suffix = "const root = ", ";\n"
+	start := strings.Index(html, prefix)
+	require.Positive(t, start)
+	data, _, ok := strings.Cut(html[start+len(prefix):], suffix)
+	require.True(t, ok)
+	var root flameNode
+	require.NoError(t, json.Unmarshal([]byte(data), &root))
+
+	var total int64
+	expectedChildren := make(map[string]int64)
+	for _, s := range actualProfile.Sample {
+		total += s.Value[1]
+		if frames := sampleFrames(s); len(frames) > 0 && s.Value[1] != 0 {
+			expectedChildren[frames[0].name] += s.Value[1]
+		}
+	}
+	require.Equal(t, "root", root.Name)
+	require.Equal(t, total, root.Value)
+
+	children := make(map[string]int64, len(root.Children))
+	for _, child := range root.Children {
+		children[child.Name] = child.Value
+	}
+	require.Equal(t, expectedChildren, children)
+}
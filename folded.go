@@ -0,0 +1,235 @@
+package ppmerge
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	pprofile "github.com/google/pprof/profile"
+	"github.com/pkg/errors"
+	"github.com/threadedstream/ppmerge/profile"
+)
+
+// foldedStacks maps collapsed stack "root;...;leaf" to its value
+type foldedStacks map[string]int64
+
+// writeTo writes stacks in Brendan Gregg's collapsed format sorted by stack
+func (fs foldedStacks) writeTo(w io.Writer) error {
+	stacks := make([]string, 0, len(fs))
+	for stack := range fs {
+		stacks = append(stacks, stack)
+	}
+	sort.Strings(stacks)
+
+	bw := bufio.NewWriter(w)
+	for _, stack := range stacks {
+		if _, err := fmt.Fprintf(bw, "%s %d\n", stack, fs[stack]); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// WriteFolded writes entries idxs aggregated together as collapsed stacks, one
+// "frame1;frame2;frame3 value" line per unique stack with the root frame first.
+// Values are taken from sampleType, the last sample type of entry is used if it's empty.
+func (pu *ProfileUnPacker) WriteFolded(w io.Writer, sampleType string, idxs ...uint64) error {
+	stacks := make(foldedStacks)
+	for _, idx := range idxs {
+		p, err := pu.Unpack(idx)
+		if err != nil {
+			return errors.Wrapf(err, "unpack profile %d", idx)
+		}
+
+		valueIdx := sampleTypeIndex(p, sampleType)
+		if valueIdx < 0 {
+			return errors.Errorf("profile %d has no sample type %q", idx, sampleType)
+		}
+
+		for _, s := range p.Sample {
+			if v := s.Value[valueIdx]; v != 0 {
+				stacks[foldSample(s)] += v
+			}
+		}
+	}
+
+	return stacks.writeTo(w)
+}
+
+// stackFrame is a single frame of sample stack with inline frames expanded
+type stackFrame struct {
+	name, file string
+	line       int64
+}
+
+// sampleFrames expands inline frames of sample locations and returns them ordered from root to leaf
+func sampleFrames(s *pprofile.Sample) []stackFrame {
+	frames := make([]stackFrame, 0, len(s.Location))
+	for i := len(s.Location) - 1; i >= 0; i-- {
+		loc := s.Location[i]
+		if len(loc.Line) == 0 {
+			frames = append(frames, stackFrame{name: fmt.Sprintf("%#x", loc.Address)})
+			continue
+		}
+		// the last line is the caller the preceding ones were inlined into
+		for j := len(loc.Line) - 1; j >= 0; j-- {
+			frames = append(frames, lineFrame(loc.Line[j], loc.Address))
+		}
+	}
+	return frames
+}
+
+func lineFrame(line pprofile.Line, address uint64) stackFrame {
+	if line.Function == nil || line.Function.Name == "" {
+		return stackFrame{name: fmt.Sprintf("%#x", address)}
+	}
+	return stackFrame{
+		name: line.Function.Name,
+		file: line.Function.Filename,
+		line: line.Function.StartLine,
+	}
+}
+
+// foldSample joins sample frames from root to leaf
+func foldSample(s *pprofile.Sample) string {
+	frames := sampleFrames(s)
+	names := make([]string, len(frames))
+	for i, f := range frames {
+		names[i] = f.name
+	}
+	return strings.Join(names, ";")
+}
+
+// sampleTypeIndex returns index of sampleType in p, the last sample type is used if it's empty
+func sampleTypeIndex(p *pprofile.Profile, sampleType string) int {
+	if sampleType == "" {
+		return len(p.SampleType) - 1
+	}
+	for i, st := range p.SampleType {
+		if st.Type == sampleType {
+			return i
+		}
+	}
+	return -1
+}
+
+// WriteFolded writes entries idxs aggregated together as collapsed stacks, one
+// "frame1;frame2;frame3 count" line per unique stack with the root frame first
+func (gpu *GoroutineProfileUnPacker) WriteFolded(w io.Writer, idxs ...uint64) error {
+	stacks := make(foldedStacks)
+	for _, idx := range idxs {
+		gp, err := gpu.Unpack(idx)
+		if err != nil {
+			return errors.Wrapf(err, "unpack profile %d", idx)
+		}
+
+		for _, st := range gp.GetStacktraces() {
+			frames := st.GetFrames()
+			names := make([]string, 0, len(st.PC))
+			switch {
+			case len(frames) > 0:
+				for i := len(frames) - 1; i >= 0; i-- {
+					if name := gp.StringTable[frames[i].FunctionName]; name != "" {
+						names = append(names, name)
+					} else {
+						names = append(names, fmt.Sprintf("%#x", frames[i].Address))
+					}
+				}
+			default:
+				// unsymbolized stack
+				for i := len(st.PC) - 1; i >= 0; i-- {
+					names = append(names, fmt.Sprintf("%#x", st.PC[i]))
+				}
+			}
+			stacks[strings.Join(names, ";")] += int64(st.Total)
+		}
+	}
+
+	return stacks.writeTo(w)
+}
+
+// ParseFolded parses collapsed stacks into a profile with a single sample type,
+// so that they can be merged along with other profiles
+func ParseFolded(r io.Reader, sampleType, unit string) (*profile.Profile, error) {
+	p := new(profile.Profile)
+	stringTable := map[string]uint64{}
+	functionByName := map[string]uint64{}
+
+	putString := func(val string) int64 {
+		if id, ok := stringTable[val]; ok {
+			return int64(id)
+		}
+		id := uint64(len(stringTable)) + 1
+		stringTable[val] = id
+		return int64(id)
+	}
+
+	putFrame := func(name string) uint64 {
+		if id, ok := functionByName[name]; ok {
+			return id
+		}
+		id := uint64(len(p.Function)) + 1
+		p.Function = append(p.Function, &profile.Function{
+			Id:   id,
+			Name: putString(name),
+		})
+		p.Location = append(p.Location, &profile.Location{
+			Id:   id,
+			Line: []*profile.Line{{FunctionId: id}},
+		})
+		functionByName[name] = id
+		return id
+	}
+
+	p.SampleType = []*profile.ValueType{{
+		Type: putString(sampleType),
+		Unit: putString(unit),
+	}}
+	p.PeriodType = &profile.ValueType{
+		Type: p.SampleType[0].Type,
+		Unit: p.SampleType[0].Unit,
+	}
+
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 1<<20)
+	for lineNum := 1; s.Scan(); lineNum++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" {
+			continue
+		}
+
+		sep := strings.LastIndexByte(line, ' ')
+		if sep < 0 {
+			return nil, errors.Errorf("line %d: missing value", lineNum)
+		}
+
+		value, err := strconv.ParseInt(line[sep+1:], 10, 64)
+		if err != nil {
+			return nil, errors.Errorf("line %d: invalid value %q", lineNum, line[sep+1:])
+		}
+
+		frames := strings.Split(strings.TrimSpace(line[:sep]), ";")
+		sample := &profile.Sample{
+			LocationId: make([]uint64, 0, len(frames)),
+			Value:      []int64{value},
+		}
+		// pprof stores leaf first
+		for i := len(frames) - 1; i >= 0; i-- {
+			sample.LocationId = append(sample.LocationId, putFrame(frames[i]))
+		}
+		p.Sample = append(p.Sample, sample)
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+
+	p.StringTable = make([]string, len(stringTable)+1)
+	for val, id := range stringTable {
+		p.StringTable[id] = val
+	}
+
+	return p, nil
+}
@@ -0,0 +1,100 @@
+package ppmerge
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"testing"
+
+	pprofile "github.com/google/pprof/profile"
+	"github.com/stretchr/testify/require"
+)
+
+func sumFolded(t *testing.T, folded string) int64 {
+	var total int64
+	for _, line := range strings.Split(strings.TrimSpace(folded), "\n") {
+		v, err := strconv.ParseInt(line[strings.LastIndexByte(line, ' ')+1:], 10, 64)
+		require.NoError(t, err)
+		total += v
+	}
+	return total
+}
+
+func TestWriteFolded(t *testing.T) {
+	profiles := getProfilesVtProto(t, false, "hprof1", "hprof2", "hprof3", "hprof4")
+	actualProfiles := getProfiles(t, "hprof1", "hprof2", "hprof3", "hprof4")
+
+	mergedProfile := NewProfileMerger().Merge(profiles...)
+	unpacker := NewProfileUnPacker(mergedProfile)
+
+	var expectedTotal int64
+	for _, p := range actualProfiles[:2] {
+		for _, s := range p.Sample {
+			expectedTotal += s.Value[1]
+		}
+	}
+
+	bb := bytes.NewBuffer(nil)
+	require.NoError(t, unpacker.WriteFolded(bb, "alloc_space", 0, 1))
+	require.Equal(t, expectedTotal, sumFolded(t, bb.String()))
+
+	// default sample type is the last one
+	defaultBB := bytes.NewBuffer(nil)
+	require.NoError(t, unpacker.WriteFolded(defaultBB, "", 0))
+	inuseBB := bytes.NewBuffer(nil)
+	require.NoError(t, unpacker.WriteFolded(inuseBB, "inuse_space", 0))
+	require.Equal(t, inuseBB.String(), defaultBB.String())
+
+	require.Error(t, unpacker.WriteFolded(bb, "cpu", 0))
+	require.Error(t, unpacker.WriteFolded(bb, "", 4))
+}
+
+func TestFoldSampleInlineFrames(t *testing.T) {
+	fn := func(name string) *pprofile.Function {
+		return &pprofile.Function{Name: name}
+	}
+
+	s := &pprofile.Sample{
+		Location: []*pprofile.Location{
+			// leaf location, memmove was inlined into copy, copy into write
+			{Line: []pprofile.Line{{Function: fn("memmove")}, {Function: fn("copy")}, {Function: fn("write")}}},
+			{Address: 0x1234},
+			{Line: []pprofile.Line{{Function: fn("main")}}},
+		},
+	}
+
+	require.Equal(t, "main;0x1234;write;copy;memmove", foldSample(s))
+}
+
+func TestGoroutineWriteFolded(t *testing.T) {
+	profiles := getGoroutineProfiles(t, "parca_goroutine_debug_1_1", "parca_goroutine_debug_1_2")
+
+	mergedProfile := NewGoroutineProfileMerger().Merge(profiles...)
+	unpacker := NewGoroutineProfileUnPacker(mergedProfile)
+
+	bb := bytes.NewBuffer(nil)
+	require.NoError(t, unpacker.WriteFolded(bb, 0, 1))
+	require.Equal(t, int64(profiles[0].GetTotal()+profiles[1].GetTotal()), sumFolded(t, bb.String()))
+	require.Contains(t, bb.String(), "\nruntime.main;main.main;")
+}
+
+func TestParseFolded(t *testing.T) {
+	folded := "main;handle;read 10\nmain;handle;write 5\nmain;gc 1\n"
+
+	p, err := ParseFolded(strings.NewReader(folded), "samples", "count")
+	require.NoError(t, err)
+	require.Len(t, p.Sample, 3)
+	require.Len(t, p.Function, 5)
+
+	mergedProfile := NewProfileMerger().Merge(p)
+
+	bb := bytes.NewBuffer(nil)
+	require.NoError(t, NewProfileUnPacker(mergedProfile).WriteFolded(bb, "samples", 0))
+	require.Equal(t, "main;gc 1\nmain;handle;read 10\nmain;handle;write 5\n", bb.String())
+
+	_, err = ParseFolded(strings.NewReader("main;gc\n"), "samples", "count")
+	require.Error(t, err)
+
+	_, err = ParseFolded(strings.NewReader("main;gc x\n"), "samples", "count")
+	require.Error(t, err)
+}
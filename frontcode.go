@@ -0,0 +1,268 @@
+package ppmerge
+
+import (
+	"sort"
+
+	"github.com/pkg/errors"
+	"github.com/threadedstream/ppmerge/profile"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+var malformedStringsErr = errors.New("malformed front coded strings")
+
+// WithFrontCodedStrings makes WriteCompressed and WriteUncompressed write string table front coded,
+// see MergedProfile.FrontCoded. StreamMerger front codes strings in order of their ids instead,
+// as labels referring to them are written before the table.
+func WithFrontCodedStrings() MergerOption {
+	return func(pw *ProfileMerger) {
+		pw.frontCodeStrings = true
+	}
+}
+
+// GoroutineMergerOption configures GoroutineProfileMerger
+type GoroutineMergerOption func(gpm *GoroutineProfileMerger)
+
+// WithFrontCodedGoroutineStrings makes WriteCompressed write string table front coded,
+// see MergedGoroutineProfile.FrontCoded
+func WithFrontCodedGoroutineStrings() GoroutineMergerOption {
+	return func(gpm *GoroutineProfileMerger) {
+		gpm.frontCodeStrings = true
+	}
+}
+
+// FrontCoded returns copy of x with strings sorted, so that the ones sharing prefixes, like import
+// and file paths, sit next to each other, and front coded: every string is stored as length of
+// prefix it shares with the previous one followed by the rest of it. Unpackers decode such
+// profiles transparently, other readers must call Decoded first. Tables not referring to
+// strings are shared with x.
+func (x *MergedProfile) FrontCoded() *MergedProfile {
+	// strings of dictionary keep their ids, and so does the empty string at the start of table
+	base := int64(x.DictionaryStrings)
+	keep := 0
+	if base == 0 {
+		keep = 1
+	}
+	strs, ids := sortStrings(x.StringTable, keep)
+	remap := func(id int64) int64 {
+		if id < base || id-base >= int64(len(ids)) {
+			return id
+		}
+		return base + ids[id-base]
+	}
+
+	y := x.shallowCopy()
+	y.StringTable = nil
+	y.FrontCodedStrings = appendFrontCoded(nil, strs)
+	y.SampleType = remapStringIDs(x.SampleType, remap)
+	y.PeriodTypes = remapStringIDs(x.PeriodTypes, remap)
+
+	y.Functions = make([]*MergeFunction, 0, len(x.Functions))
+	for _, fn := range x.Functions {
+		fn = fn.clone()
+		fn.Name, fn.SystemName, fn.Filename = remap(fn.Name), remap(fn.SystemName), remap(fn.Filename)
+		y.Functions = append(y.Functions, fn)
+	}
+	y.Mappings = make([]*MergeMapping, 0, len(x.Mappings))
+	for _, m := range x.Mappings {
+		m = m.clone()
+		m.Filename, m.BuildId = remap(m.Filename), remap(m.BuildId)
+		y.Mappings = append(y.Mappings, m)
+	}
+	y.Labels = make(map[uint64]*profile.Labels, len(x.Labels))
+	for offset, labels := range x.Labels {
+		y.Labels[offset] = remapLabels(labels, remap)
+	}
+
+	return y
+}
+
+// DecodeStrings restores string table of x front coded by FrontCoded, x with plain string table
+// is left as is. It modifies x, so it must not run concurrently with other readers of x, see Decoded.
+func (x *MergedProfile) DecodeStrings() error {
+	if len(x.FrontCodedStrings) == 0 {
+		return nil
+	}
+
+	strs, err := decodeFrontCoded(x.FrontCodedStrings)
+	if err != nil {
+		return err
+	}
+	x.StringTable, x.FrontCodedStrings = strs, nil
+	return nil
+}
+
+// FrontCoded returns copy of x with strings sorted and front coded like MergedProfile.FrontCoded does.
+// Totals and capture times are shared with x.
+func (x *MergedGoroutineProfile) FrontCoded() *MergedGoroutineProfile {
+	strs, ids := sortStrings(x.StringTable, 1)
+	remap := func(id int64) int64 {
+		return remapStringID(id, ids)
+	}
+
+	y := x.shallowCopy()
+	y.StringTable = nil
+	y.FrontCodedStrings = appendFrontCoded(nil, strs)
+	y.Stacktraces = make([]*profile.Stacktrace, 0, len(x.Stacktraces))
+	y.Sources = remapSourceIDs(x.Sources, remap)
+	y.Comments = remapSourceIDs(x.Comments, remap)
+	y.Labels = make([]*profile.Labels, len(x.Labels))
+	for i, labels := range x.Labels {
+		y.Labels[i] = remapLabels(labels, remap)
+	}
+	for _, st := range x.Stacktraces {
+		remapped := &profile.Stacktrace{
+			Total:  st.Total,
+			PC:     st.PC,
+			Frames: make([]*profile.Frame, 0, len(st.Frames)),
+			Labels: uint64(remap(int64(st.Labels))),
+		}
+		for _, f := range st.Frames {
+			remapped.Frames = append(remapped.Frames, &profile.Frame{
+				Address:      f.Address,
+				FunctionName: uint64(remap(int64(f.FunctionName))),
+				Offset:       f.Offset,
+				Filename:     uint64(remap(int64(f.Filename))),
+				Line:         f.Line,
+			})
+		}
+		y.Stacktraces = append(y.Stacktraces, remapped)
+	}
+
+	return y
+}
+
+func remapSourceIDs(sourceIDs []uint64, remap func(int64) int64) []uint64 {
+	remapped := make([]uint64, len(sourceIDs))
+	for i, id := range sourceIDs {
+		remapped[i] = uint64(remap(int64(id)))
+	}
+	return remapped
+}
+
+// remapLabels returns copy of labels referring to strings remapped by remap
+func remapLabels(labels *profile.Labels, remap func(int64) int64) *profile.Labels {
+	remapped := &profile.Labels{Labels: make([]*profile.Label, 0, len(labels.GetLabels()))}
+	for _, label := range labels.GetLabels() {
+		remapped.Labels = append(remapped.Labels, &profile.Label{
+			Key:     remap(label.Key),
+			Str:     remap(label.Str),
+			Num:     label.Num,
+			NumUnit: remap(label.NumUnit),
+		})
+	}
+	return remapped
+}
+
+// DecodeStrings restores string table of x front coded by FrontCoded like MergedProfile.DecodeStrings does
+func (x *MergedGoroutineProfile) DecodeStrings() error {
+	if len(x.FrontCodedStrings) == 0 {
+		return nil
+	}
+
+	strs, err := decodeFrontCoded(x.FrontCodedStrings)
+	if err != nil {
+		return err
+	}
+	x.StringTable, x.FrontCodedStrings = strs, nil
+	return nil
+}
+
+// numStrings returns number of strings of table, front coded or not
+func numStrings(table []string, frontCoded []byte) int {
+	if len(frontCoded) == 0 {
+		return len(table)
+	}
+	num, _ := protowire.ConsumeVarint(frontCoded)
+	return int(num)
+}
+
+// sortStrings returns strs sorted and new id of every string, the first keep strings keep their places
+func sortStrings(strs []string, keep int) ([]string, []int64) {
+	order := make([]int, len(strs))
+	for i := range order {
+		order[i] = i
+	}
+	if len(order) > keep {
+		rest := order[keep:]
+		sort.SliceStable(rest, func(i, j int) bool {
+			return strs[rest[i]] < strs[rest[j]]
+		})
+	}
+
+	sorted := make([]string, len(strs))
+	ids := make([]int64, len(strs))
+	for newID, oldID := range order {
+		sorted[newID] = strs[oldID]
+		ids[oldID] = int64(newID)
+	}
+	return sorted, ids
+}
+
+// remapStringID returns new id of string id, ids out of table are kept as is
+func remapStringID(id int64, ids []int64) int64 {
+	if id < 0 || id >= int64(len(ids)) {
+		return id
+	}
+	return ids[id]
+}
+
+func remapStringIDs(strIDs []int64, remap func(int64) int64) []int64 {
+	remapped := make([]int64, len(strIDs))
+	for i, id := range strIDs {
+		remapped[i] = remap(id)
+	}
+	return remapped
+}
+
+// appendFrontCoded appends number of strs to data followed by every string of strs as length of
+// prefix it shares with the previous string, length of the rest of it and the rest itself
+func appendFrontCoded(data []byte, strs []string) []byte {
+	data = protowire.AppendVarint(data, uint64(len(strs)))
+
+	prev := ""
+	for _, s := range strs {
+		prefix := 0
+		for prefix < len(prev) && prefix < len(s) && prev[prefix] == s[prefix] {
+			prefix++
+		}
+		data = protowire.AppendVarint(data, uint64(prefix))
+		data = protowire.AppendVarint(data, uint64(len(s)-prefix))
+		data = append(data, s[prefix:]...)
+		prev = s
+	}
+	return data
+}
+
+// decodeFrontCoded decodes strings encoded by appendFrontCoded
+func decodeFrontCoded(data []byte) ([]string, error) {
+	num, n := protowire.ConsumeVarint(data)
+	if n < 0 || num > uint64(len(data)) {
+		return nil, malformedStringsErr
+	}
+	data = data[n:]
+
+	strs := make([]string, 0, num)
+	var buf []byte
+	for i := uint64(0); i < num; i++ {
+		prefix, n := protowire.ConsumeVarint(data)
+		if n < 0 || prefix > uint64(len(buf)) {
+			return nil, malformedStringsErr
+		}
+		data = data[n:]
+
+		size, n := protowire.ConsumeVarint(data)
+		if n < 0 || size > uint64(len(data)-n) {
+			return nil, malformedStringsErr
+		}
+		data = data[n:]
+
+		buf = append(buf[:prefix], data[:size]...)
+		data = data[size:]
+		strs = append(strs, string(buf))
+	}
+	if len(data) > 0 {
+		return nil, malformedStringsErr
+	}
+
+	return strs, nil
+}
@@ -0,0 +1,151 @@
+package ppmerge
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var frontCodedPaths = []string{"hprof1", "hprof2", "hprof3", "hprof4", "labels.prof", "multilabels.prof", "parca_cpu", "parca_heap"}
+
+func TestFrontCodedStrings(t *testing.T) {
+	mergedProfile := NewProfileMerger().Merge(getProfilesVtProto(t, false, frontCodedPaths...)...)
+	numStrings := len(mergedProfile.StringTable)
+
+	frontCoded := mergedProfile.FrontCoded()
+	require.Empty(t, frontCoded.StringTable)
+	require.Len(t, mergedProfile.StringTable, numStrings)
+
+	plain, err := mergedProfile.MarshalDeterministic()
+	require.NoError(t, err)
+	encoded, err := frontCoded.MarshalDeterministic()
+	require.NoError(t, err)
+	plainCompressed, err := gzipSize(plain)
+	require.NoError(t, err)
+	encodedCompressed, err := gzipSize(encoded)
+	require.NoError(t, err)
+	t.Logf("string table: %d strings, %d -> %d bytes", numStrings,
+		(&MergedProfile{StringTable: mergedProfile.StringTable}).SizeVT(),
+		(&MergedProfile{FrontCodedStrings: frontCoded.FrontCodedStrings}).SizeVT())
+	t.Logf("archive: %d -> %d bytes, compressed %d -> %d bytes", len(plain), len(encoded), plainCompressed, encodedCompressed)
+	require.Less(t, len(encoded), len(plain))
+
+	decoded := new(MergedProfile)
+	require.NoError(t, decoded.UnmarshalVT(encoded))
+	stats, err := decoded.Stats()
+	require.NoError(t, err)
+	require.Equal(t, numStrings, stats.Tables[0].Len)
+
+	// unpackers leave archive as is, Decoded returns decoded copy
+	requireSameEntries(t, mergedProfile, decoded)
+	require.NotEmpty(t, decoded.FrontCodedStrings)
+	decoded, err = decoded.Decoded(nil)
+	require.NoError(t, err)
+	require.Len(t, decoded.StringTable, numStrings)
+	require.Empty(t, decoded.FrontCodedStrings)
+
+	// queries decode strings as well
+	frontCoded = mergedProfile.FrontCoded()
+	selector, err := ParseLabelSelector("bytes>=1")
+	require.NoError(t, err)
+	expectedIdxs, err := mergedProfile.FindEntries(selector)
+	require.NoError(t, err)
+	idxs, err := frontCoded.FindEntries(selector)
+	require.NoError(t, err)
+	require.Equal(t, expectedIdxs, idxs)
+}
+
+func TestFrontCodedStringsWriters(t *testing.T) {
+	profiles := getProfilesVtProto(t, false, frontCodedPaths...)
+	expected := NewProfileMerger().Merge(profiles...)
+
+	profileMerger := NewProfileMerger(WithFrontCodedStrings())
+	profileMerger.Merge(profiles...)
+	var compressed bytes.Buffer
+	require.NoError(t, profileMerger.WriteCompressed(&compressed))
+	unpacker := NewProfileUnPacker(nil)
+	for idx := range frontCodedPaths {
+		expectedProfile, err := NewProfileUnPacker(expected).Unpack(uint64(idx))
+		require.NoError(t, err)
+		actual, err := unpacker.UnpackRaw(compressed.Bytes(), uint64(idx))
+		require.NoError(t, err)
+		require.Equal(t, expectedProfile.String(), actual.String())
+	}
+
+	filePaths := make([]string, len(frontCodedPaths))
+	for i, path := range frontCodedPaths {
+		filePaths[i] = filepath.Join("testdata", path)
+	}
+	var streamed bytes.Buffer
+	require.NoError(t, MergeStream(&streamed, FileIterator(filePaths...), WithFrontCodedStrings()))
+	streamedProfile := new(MergedProfile)
+	require.NoError(t, streamedProfile.UnmarshalVT(streamed.Bytes()))
+	require.NotEmpty(t, streamedProfile.FrontCodedStrings)
+	requireSameEntries(t, expected, streamedProfile)
+
+	shardedMerger := NewShardedMerger(WithFrontCodedStrings())
+	shardedMerger.Merge(profiles...)
+	var sharded bytes.Buffer
+	require.NoError(t, shardedMerger.WriteCompressed(&sharded))
+	for idx := range frontCodedPaths {
+		expectedProfile, err := NewProfileUnPacker(NewProfileMerger().Merge(profiles[idx])).Unpack(0)
+		require.NoError(t, err)
+		actual, err := NewShardedProfileUnPacker(nil).UnpackRaw(sharded.Bytes(), uint64(idx))
+		require.NoError(t, err)
+		require.Equal(t, expectedProfile.String(), actual.String())
+	}
+}
+
+func TestFrontCodedGoroutineStrings(t *testing.T) {
+	paths := []string{"parca_goroutine_debug_1_1", "parca_goroutine_debug_1_2", "parca_goroutine_debug_1_3"}
+	mergedProfile := NewGoroutineProfileMerger().Merge(getGoroutineProfiles(t, paths...)...)
+
+	frontCoded := mergedProfile.FrontCoded()
+	plain, err := mergedProfile.MarshalVT()
+	require.NoError(t, err)
+	encoded, err := frontCoded.MarshalVT()
+	require.NoError(t, err)
+	plainCompressed, err := gzipSize(plain)
+	require.NoError(t, err)
+	encodedCompressed, err := gzipSize(encoded)
+	require.NoError(t, err)
+	t.Logf("goroutine archive: %d -> %d bytes, compressed %d -> %d bytes", len(plain), len(encoded), plainCompressed, encodedCompressed)
+	require.Less(t, len(encoded), len(plain))
+
+	goroutineMerger := NewGoroutineProfileMerger(WithFrontCodedGoroutineStrings())
+	goroutineMerger.Merge(getGoroutineProfiles(t, paths...)...)
+	var compressed bytes.Buffer
+	require.NoError(t, goroutineMerger.WriteCompressed(&compressed))
+
+	expectedUnpacker := NewGoroutineProfileUnPacker(mergedProfile)
+	unpacker := NewGoroutineProfileUnPacker(nil)
+	for idx := range paths {
+		expected, err := expectedUnpacker.Unpack(uint64(idx))
+		require.NoError(t, err)
+		actual, err := unpacker.UnpackRaw(compressed.Bytes(), uint64(idx))
+		require.NoError(t, err)
+		require.Equal(t, expected.String(), actual.String())
+	}
+}
+
+func TestDecodeFrontCoded(t *testing.T) {
+	strs := []string{"", "github.com/org/repo", "github.com/org/repo/internal", "github.com/other", "", "/usr/local/go/src"}
+	encoded := appendFrontCoded(nil, strs)
+
+	decoded, err := decodeFrontCoded(encoded)
+	require.NoError(t, err)
+	require.Equal(t, strs, decoded)
+
+	for _, malformed := range [][]byte{nil, encoded[:len(encoded)-1], append(encoded, 0), {1, 5, 0}, {1, 0, 5, 'a'}} {
+		_, err = decodeFrontCoded(malformed)
+		require.Error(t, err)
+	}
+
+	sorted, ids := sortStrings(strs, 1)
+	require.Equal(t, []string{"", "", "/usr/local/go/src", "github.com/org/repo", "github.com/org/repo/internal", "github.com/other"}, sorted)
+	for oldID, newID := range ids {
+		require.Equal(t, strs[oldID], sorted[newID])
+	}
+}
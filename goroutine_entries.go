@@ -0,0 +1,101 @@
+package ppmerge
+
+import (
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/threadedstream/ppmerge/profile"
+)
+
+// GoroutineEntry is a goroutine profile along with metadata of its capture, which debug=1 text lacks
+type GoroutineEntry struct {
+	Profile *profile.GoroutineProfile
+	// Time is the capture time, zero if unknown
+	Time time.Time
+	// Source is the origin of profile, e.g. address of the process it was taken from
+	Source string
+	Labels map[string]string
+}
+
+// MergeEntries merges profiles of entries into merged profile like Merge does, keeping capture
+// time, source and labels of every entry, see GoroutineProfileUnPacker.UnpackEntry and
+// MergedGoroutineProfile.EntriesBetween.
+func (gpm *GoroutineProfileMerger) MergeEntries(entries ...GoroutineEntry) *MergedGoroutineProfile {
+	mp := gpm.mergedProfile
+	mp.TimesNanos = make([]int64, 0, len(entries))
+	mp.Sources = make([]uint64, 0, len(entries))
+	mp.Labels = make([]*profile.Labels, 0, len(entries))
+
+	gps := make([]*profile.GoroutineProfile, 0, len(entries))
+	for _, entry := range entries {
+		var timeNanos int64
+		if !entry.Time.IsZero() {
+			timeNanos = entry.Time.UnixNano()
+		}
+		mp.TimesNanos = append(mp.TimesNanos, timeNanos)
+		mp.Sources = append(mp.Sources, gpm.putString(entry.Source))
+		mp.Labels = append(mp.Labels, gpm.asLabels(entry.Labels))
+		gps = append(gps, entry.Profile)
+	}
+
+	return gpm.mergeProfiles(gps...)
+}
+
+// asLabels interns labels ordered by key
+func (gpm *GoroutineProfileMerger) asLabels(labels map[string]string) *profile.Labels {
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	merged := &profile.Labels{Labels: make([]*profile.Label, 0, len(keys))}
+	for _, key := range keys {
+		merged.Labels = append(merged.Labels, &profile.Label{
+			Key: int64(gpm.putString(key)),
+			Str: int64(gpm.putString(labels[key])),
+		})
+	}
+	return merged
+}
+
+// UnpackEntry recovers goroutine profile idx along with its metadata. Metadata is empty
+// if profile was merged by Merge rather than MergeEntries.
+func (gpu *GoroutineProfileUnPacker) UnpackEntry(idx uint64) (GoroutineEntry, error) {
+	gp, err := gpu.Unpack(idx)
+	if err != nil {
+		return GoroutineEntry{}, err
+	}
+
+	entry := GoroutineEntry{Profile: gp}
+	mp := gpu.decoded
+	if idx < uint64(len(mp.TimesNanos)) && mp.TimesNanos[idx] != 0 {
+		entry.Time = time.Unix(0, mp.TimesNanos[idx])
+	}
+	if idx < uint64(len(mp.Sources)) {
+		entry.Source = mp.getString(mp.Sources[idx])
+	}
+	if idx < uint64(len(mp.Labels)) && len(mp.Labels[idx].GetLabels()) > 0 {
+		entry.Labels = make(map[string]string, len(mp.Labels[idx].Labels))
+		for _, label := range mp.Labels[idx].Labels {
+			if label.Key < 0 || label.Str < 0 {
+				return GoroutineEntry{}, errors.Wrapf(indexOutOfRangeErr, "labels of entry %d", idx)
+			}
+			entry.Labels[mp.getString(uint64(label.Key))] = mp.getString(uint64(label.Str))
+		}
+	}
+	return entry, nil
+}
+
+// EntriesBetween returns indexes of entries captured within [start, end] interval,
+// entries of unknown capture time are skipped
+func (x *MergedGoroutineProfile) EntriesBetween(start, end time.Time) []uint64 {
+	var idxs []uint64
+	for i, timeNanos := range x.TimesNanos {
+		if timeNanos != 0 && timeNanos >= start.UnixNano() && timeNanos <= end.UnixNano() {
+			idxs = append(idxs, uint64(i))
+		}
+	}
+	return idxs
+}
@@ -0,0 +1,64 @@
+package ppmerge
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGoroutineEntries(t *testing.T) {
+	paths := []string{"parca_goroutine_debug_1_1", "parca_goroutine_debug_1_2", "parca_goroutine_debug_1_3"}
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	entries := []GoroutineEntry{
+		{Time: start, Source: "http://10.0.0.1:6060", Labels: map[string]string{"service": "parca", "zone": "a"}},
+		{Time: start.Add(time.Minute), Source: "http://10.0.0.2:6060"},
+		{Source: "http://10.0.0.1:6060", Labels: map[string]string{"service": "parca"}},
+	}
+	for i, gp := range getGoroutineProfiles(t, paths...) {
+		entries[i].Profile = gp
+	}
+
+	goroutineMerger := NewGoroutineProfileMerger()
+	mergedProfile := goroutineMerger.MergeEntries(entries...)
+	require.Equal(t, []uint64{0, 1}, mergedProfile.EntriesBetween(start, start.Add(time.Minute)))
+	require.Equal(t, []uint64{1}, mergedProfile.EntriesBetween(start.Add(time.Second), start.Add(time.Hour)))
+	require.Empty(t, mergedProfile.EntriesBetween(start.Add(-time.Hour), start.Add(-time.Second)))
+
+	expected := NewGoroutineProfileMerger().Merge(getGoroutineProfiles(t, paths...)...)
+	require.Empty(t, expected.TimesNanos)
+	require.Empty(t, expected.EntriesBetween(start, start.Add(time.Minute)))
+
+	for _, opts := range [][]GoroutineMergerOption{nil, {WithFrontCodedGoroutineStrings(), WithDeltaGoroutineTotals()}} {
+		goroutineMerger := NewGoroutineProfileMerger(opts...)
+		goroutineMerger.MergeEntries(entries...)
+		var compressed bytes.Buffer
+		require.NoError(t, goroutineMerger.WriteCompressed(&compressed))
+
+		expectedUnpacker := NewGoroutineProfileUnPacker(expected)
+		unpacker := NewGoroutineProfileUnPacker(nil)
+		for idx := range paths {
+			expectedProfile, err := expectedUnpacker.Unpack(uint64(idx))
+			require.NoError(t, err)
+			_, err = unpacker.UnpackRaw(compressed.Bytes(), uint64(idx))
+			require.NoError(t, err)
+			entry, err := unpacker.UnpackEntry(uint64(idx))
+			require.NoError(t, err)
+			require.Equal(t, expectedProfile.String(), entry.Profile.String())
+			require.True(t, entries[idx].Time.Equal(entry.Time))
+			require.Equal(t, entries[idx].Source, entry.Source)
+			require.Equal(t, entries[idx].Labels, entry.Labels)
+		}
+	}
+
+	// entries merged without metadata have none
+	entry, err := NewGoroutineProfileUnPacker(expected).UnpackEntry(0)
+	require.NoError(t, err)
+	require.True(t, entry.Time.IsZero())
+	require.Empty(t, entry.Source)
+	require.Nil(t, entry.Labels)
+
+	// metadata of previous batch is dropped
+	require.Empty(t, goroutineMerger.Merge(getGoroutineProfiles(t, paths...)...).TimesNanos)
+}
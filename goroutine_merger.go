@@ -4,30 +4,43 @@ import (
 	"bytes"
 	"compress/gzip"
 	"io"
+	"slices"
 
 	"github.com/threadedstream/ppmerge/profile"
 	"google.golang.org/protobuf/proto"
 )
 
+// GoroutineProfileMerger merges several goroutine profiles into a single one.
+//
+// MergedGoroutineProfile returned by Merge is owned by the merger: it stays valid until the next
+// call to Merge, Reset or Release, which reuse it. Clone it with CloneVT to keep it longer.
 type GoroutineProfileMerger struct {
 	mergedProfile *MergedGoroutineProfile
 	stringTable   map[string]uint64
+	stringDedup   dedupCounter
+
+	frontCodeStrings bool
+	deltaTotals      bool
 }
 
-func NewGoroutineProfileMerger() *GoroutineProfileMerger {
-	return &GoroutineProfileMerger{
+func NewGoroutineProfileMerger(opts ...GoroutineMergerOption) *GoroutineProfileMerger {
+	gpm := &GoroutineProfileMerger{
 		mergedProfile: MergedGoroutineProfileFromVTPool(),
 		stringTable: map[string]uint64{
 			"": 0,
 		},
 	}
+	for _, opt := range opts {
+		opt(gpm)
+	}
+	return gpm
 }
 
 func (gpm *GoroutineProfileMerger) WriteCompressed(w io.Writer) error {
 	// Write writes the profile as a gzip-compressed marshaled protobuf.
 	zw := gzip.NewWriter(w)
 	defer zw.Close()
-	serialized, err := gpm.mergedProfile.MarshalVT()
+	serialized, err := gpm.written().MarshalVT()
 	if err != nil {
 		return err
 	}
@@ -36,11 +49,69 @@ func (gpm *GoroutineProfileMerger) WriteCompressed(w io.Writer) error {
 	return err
 }
 
+// written returns merged profile the way it's written: with delta encoded totals and front coded
+// strings if options say so
+func (gpm *GoroutineProfileMerger) written() *MergedGoroutineProfile {
+	mergedProfile := gpm.mergedProfile
+	if gpm.deltaTotals {
+		mergedProfile = mergedProfile.DeltaEncoded()
+	}
+	if gpm.frontCodeStrings {
+		mergedProfile = mergedProfile.FrontCoded()
+	}
+	return mergedProfile
+}
+
+// Reset clears interned strings and merged profile, so that merger can be reused for
+// an unrelated batch of profiles. It may also be called after Release.
+func (gpm *GoroutineProfileMerger) Reset() {
+	if gpm.mergedProfile == nil {
+		gpm.mergedProfile = MergedGoroutineProfileFromVTPool()
+	} else {
+		gpm.mergedProfile.ResetVT()
+	}
+	gpm.resetStringTable()
+}
+
+// Release returns merged profile to the vtproto pool. Neither merger nor merged profile
+// must be used afterwards until Reset is called.
+func (gpm *GoroutineProfileMerger) Release() {
+	gpm.mergedProfile.ReturnToVTPool()
+	gpm.mergedProfile = nil
+	gpm.resetStringTable()
+}
+
+func (gpm *GoroutineProfileMerger) resetStringTable() {
+	clear(gpm.stringTable)
+	gpm.stringTable[""] = 0
+	gpm.stringDedup = dedupCounter{}
+}
+
+// Merge merges gps into merged profile. Strings interned by previous calls are kept,
+// call Reset to start from scratch.
 func (gpm *GoroutineProfileMerger) Merge(gps ...*profile.GoroutineProfile) *MergedGoroutineProfile {
+	// debug=1 text carries no metadata, see MergeEntries
+	gpm.mergedProfile.TimesNanos = nil
+	gpm.mergedProfile.Sources = nil
+	gpm.mergedProfile.Labels = nil
+	return gpm.mergeProfiles(gps...)
+}
+
+func (gpm *GoroutineProfileMerger) mergeProfiles(gps ...*profile.GoroutineProfile) *MergedGoroutineProfile {
 	gpm.mergedProfile.Totals = make([]uint64, 0, len(gps))
 	gpm.mergedProfile.NumStacktraces = make([]uint64, 0, len(gps))
+	gpm.mergedProfile.NumComments = make([]uint64, 0, len(gps))
+	gpm.mergedProfile.Comments = nil
+	gpm.mergedProfile.TrimmedNewlines = make([]uint64, 0, len(gps))
 
 	gpm.merge(gps...)
+	// text written by runtime has neither comments nor trimmed newlines, then nothing is stored
+	if len(gpm.mergedProfile.Comments) == 0 {
+		gpm.mergedProfile.NumComments = nil
+	}
+	if !slices.ContainsFunc(gpm.mergedProfile.TrimmedNewlines, func(n uint64) bool { return n != 0 }) {
+		gpm.mergedProfile.TrimmedNewlines = nil
+	}
 
 	gpm.finalizeStringTable()
 	return gpm.mergedProfile
@@ -50,6 +121,11 @@ func (gpm *GoroutineProfileMerger) merge(gps ...*profile.GoroutineProfile) {
 	var resultStacktraces []*profile.Stacktrace
 	for _, gp := range gps {
 		gpm.mergedProfile.Totals = append(gpm.mergedProfile.Totals, gp.Total)
+		gpm.mergedProfile.NumComments = append(gpm.mergedProfile.NumComments, uint64(len(gp.Comments)))
+		for _, comment := range gp.Comments {
+			gpm.mergedProfile.Comments = append(gpm.mergedProfile.Comments, gpm.putString(comment))
+		}
+		gpm.mergedProfile.TrimmedNewlines = append(gpm.mergedProfile.TrimmedNewlines, gp.TrimmedNewlines)
 		stacktraces := gp.GetStacktraces()
 
 		gpm.mergedProfile.NumStacktraces = append(gpm.mergedProfile.NumStacktraces, uint64(len(stacktraces)))
@@ -57,6 +133,9 @@ func (gpm *GoroutineProfileMerger) merge(gps ...*profile.GoroutineProfile) {
 		for _, st := range stacktraces {
 			resultStacktrace := new(profile.Stacktrace)
 			resultStacktrace.Total = st.Total
+			if st.Labels != 0 {
+				resultStacktrace.Labels = gpm.putString(gp.StringTable[st.Labels])
+			}
 
 			resultStacktrace.PC = make([]uint64, len(st.PC))
 			copy(resultStacktrace.PC, st.PC)
@@ -95,17 +174,27 @@ func (gpm *GoroutineProfileMerger) finalizeStringTable() {
 }
 
 func (gpm *GoroutineProfileMerger) putString(val string) uint64 {
-	if id, ok := gpm.stringTable[val]; ok {
+	id, ok := gpm.stringTable[val]
+	gpm.stringDedup.count(ok)
+	if ok {
 		return id
 	}
-	id := uint64(len(gpm.stringTable))
+	id = uint64(len(gpm.stringTable))
 	gpm.stringTable[val] = id
 	return id
 }
 
+// GoroutineProfileUnPacker recovers any of the goroutine profiles stored inside mergedProfile.
+//
+// Merged profile passed to NewGoroutineProfileUnPacker stays owned by the caller, while the one
+// decoded by UnpackRaw is owned by unpacker and is returned to the pool by Release. Merged profile
+// is decoded like ProfileUnPacker does, it's never modified.
 type GoroutineProfileUnPacker struct {
 	mergedProfile *MergedGoroutineProfile
-	stringTable   map[string]uint64
+	ownsProfile   bool
+	// mergedProfile decoded by MergedGoroutineProfile.Decoded
+	decoded     *MergedGoroutineProfile
+	stringTable map[string]uint64
 }
 
 func NewGoroutineProfileUnPacker(mergedProfile *MergedGoroutineProfile) *GoroutineProfileUnPacker {
@@ -132,8 +221,10 @@ func (gpu *GoroutineProfileUnPacker) UnpackRaw(compressedRawProfile []byte, idx
 
 	if gpu.mergedProfile == nil {
 		gpu.mergedProfile = MergedGoroutineProfileFromVTPool()
+		gpu.ownsProfile = true
 	}
 
+	gpu.decoded = nil
 	if err = proto.Unmarshal(rawProfile, gpu.mergedProfile); err != nil {
 		return nil, err
 	}
@@ -141,37 +232,97 @@ func (gpu *GoroutineProfileUnPacker) UnpackRaw(compressedRawProfile []byte, idx
 	return gpu.Unpack(idx)
 }
 
+// Reset clears state kept between calls, merged profile decoded by UnpackRaw is reset too.
+func (gpu *GoroutineProfileUnPacker) Reset() {
+	gpu.resetStringTable()
+	gpu.decoded = nil
+	if gpu.ownsProfile {
+		gpu.mergedProfile.ResetVT()
+	}
+}
+
+// Release returns merged profile decoded by UnpackRaw to the vtproto pool and drops
+// reference to the one passed to NewGoroutineProfileUnPacker. Profiles returned by Unpack stay valid.
+func (gpu *GoroutineProfileUnPacker) Release() {
+	gpu.resetStringTable()
+	gpu.decoded = nil
+	if gpu.ownsProfile {
+		gpu.mergedProfile.ReturnToVTPool()
+	}
+	gpu.mergedProfile = nil
+	gpu.ownsProfile = false
+}
+
+func (gpu *GoroutineProfileUnPacker) resetStringTable() {
+	clear(gpu.stringTable)
+	gpu.stringTable[""] = 0
+}
+
+// Unpack recovers goroutine profile idx. Returned profile is taken from the vtproto pool
+// and is owned by the caller, who may return it with ReturnToVTPool.
 func (gpu *GoroutineProfileUnPacker) Unpack(idx uint64) (*profile.GoroutineProfile, error) {
-	if idx >= uint64(len(gpu.mergedProfile.NumStacktraces)) {
+	// string table of every unpacked profile holds only its own strings
+	gpu.resetStringTable()
+	if err := gpu.decode(); err != nil {
+		return nil, err
+	}
+
+	if idx >= uint64(len(gpu.decoded.NumStacktraces)) {
 		return nil, indexOutOfRangeErr
 	}
 	gp := profile.GoroutineProfileFromVTPool()
 
-	gp.Total = gpu.mergedProfile.Totals[idx]
+	gp.Total = gpu.decoded.Totals[idx]
 
-	numStacktraces := gpu.mergedProfile.NumStacktraces[idx]
+	numStacktraces := gpu.decoded.NumStacktraces[idx]
 
 	var offset uint64
 	for i := uint64(0); i < idx; i++ {
-		offset += gpu.mergedProfile.NumStacktraces[i]
+		offset += gpu.decoded.NumStacktraces[i]
 	}
 
 	limit := offset + numStacktraces
 
 	gp.Stacktraces = make([]*profile.Stacktrace, 0, numStacktraces)
 	for offset < limit {
-		gp.Stacktraces = append(gp.Stacktraces, gpu.remapStacktrace(gpu.mergedProfile.Stacktraces[offset]))
+		gp.Stacktraces = append(gp.Stacktraces, gpu.remapStacktrace(gpu.decoded.Stacktraces[offset]))
 		offset++
 	}
 
+	gpu.unpackComments(gp, idx)
 	gpu.finalizeStringTable(gp)
 
 	return gp, nil
 }
 
+// unpackComments restores leading comments and trimmed newlines of goroutine profile idx,
+// archives written before they were kept have none
+func (gpu *GoroutineProfileUnPacker) unpackComments(gp *profile.GoroutineProfile, idx uint64) {
+	mp := gpu.decoded
+	if idx < uint64(len(mp.TrimmedNewlines)) {
+		gp.TrimmedNewlines = mp.TrimmedNewlines[idx]
+	}
+	if idx >= uint64(len(mp.NumComments)) {
+		return
+	}
+
+	var offset uint64
+	for i := uint64(0); i < idx; i++ {
+		offset += mp.NumComments[i]
+	}
+	limit := min(offset+mp.NumComments[idx], uint64(len(mp.Comments)))
+	for ; offset < limit; offset++ {
+		gp.Comments = append(gp.Comments, mp.getString(mp.Comments[offset]))
+	}
+}
+
 func (gpu *GoroutineProfileUnPacker) remapStacktrace(st *profile.Stacktrace) *profile.Stacktrace {
 	resultStacktrace := new(profile.Stacktrace)
 	resultStacktrace.Total = st.Total
+	if st.Labels != 0 {
+		// labels precede frames in debug=1 text, so they are interned first like Parse does
+		resultStacktrace.Labels = gpu.putString(gpu.decoded.StringTable[st.Labels])
+	}
 
 	resultStacktrace.PC = make([]uint64, len(st.PC))
 	copy(resultStacktrace.PC, st.PC)
@@ -192,9 +343,9 @@ func (gpu *GoroutineProfileUnPacker) remapStacktrace(st *profile.Stacktrace) *pr
 func (gpu *GoroutineProfileUnPacker) remapFrame(frame *profile.Frame) *profile.Frame {
 	return &profile.Frame{
 		Address:      frame.Address,
-		FunctionName: gpu.putString(gpu.mergedProfile.StringTable[frame.FunctionName]),
+		FunctionName: gpu.putString(gpu.decoded.StringTable[frame.FunctionName]),
 		Offset:       frame.Offset,
-		Filename:     gpu.putString(gpu.mergedProfile.StringTable[frame.Filename]),
+		Filename:     gpu.putString(gpu.decoded.StringTable[frame.Filename]),
 		Line:         frame.Line,
 	}
 }
@@ -214,3 +365,10 @@ func (gpu *GoroutineProfileUnPacker) putString(val string) uint64 {
 	gpu.stringTable[val] = id
 	return id
 }
+
+func (x *MergedGoroutineProfile) getString(id uint64) string {
+	if id >= uint64(len(x.StringTable)) {
+		return ""
+	}
+	return x.StringTable[id]
+}
@@ -0,0 +1,205 @@
+package ppmerge
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const archivePathPrefix = "/archive/"
+
+// ArchiveHandler serves profiles stored in MergedProfile archives as gzipped pprof
+// profiles, so that go tool pprof and the pprof web UI can read them directly.
+//
+// Archives are files written by ProfileMerger.WriteCompressed and located in dir.
+// The following URLs are supported:
+//
+//	/archive/{name}/{idx}      - entry idx of archive name
+//	/archive/{name}?time={t}   - entry of archive name captured at time t
+//
+// t is either RFC 3339 timestamp or unix time in seconds. Entry whose
+// [time, time+duration) interval contains t is served, otherwise the latest entry
+// captured before t.
+//
+// Both URLs accept labels={selector} parameter, then only samples matched by the selector
+// are served. See ParseLabelSelector for the syntax.
+type ArchiveHandler struct {
+	dir  string
+	opts []UnPackerOption
+}
+
+// NewArchiveHandler returns ArchiveHandler serving archives from dir. opts are passed to
+// unpackers, e.g. WithDictionaryResolver makes archives written with WithDictionary servable.
+func NewArchiveHandler(dir string, opts ...UnPackerOption) *ArchiveHandler {
+	return &ArchiveHandler{
+		dir:  dir,
+		opts: opts,
+	}
+}
+
+func (ah *ArchiveHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name, idxStr, err := ah.parsePath(r.URL.Path)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	mergedProfile, err := ah.readArchive(name)
+	if err != nil {
+		if os.IsNotExist(errors.Cause(err)) {
+			http.Error(w, "archive not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var idx uint64
+	switch {
+	case idxStr != "":
+		idx, err = strconv.ParseUint(idxStr, 10, 64)
+		if err != nil {
+			http.Error(w, "invalid profile index", http.StatusBadRequest)
+			return
+		}
+	case r.URL.Query().Get("time") != "":
+		t, err := parseArchiveTime(r.URL.Query().Get("time"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var ok bool
+		if idx, ok = findEntryByTime(mergedProfile, t); !ok {
+			http.Error(w, "no profile captured at given time", http.StatusNotFound)
+			return
+		}
+	default:
+		http.Error(w, "either profile index or time must be specified", http.StatusBadRequest)
+		return
+	}
+
+	if idx >= uint64(len(mergedProfile.NumSamples)) {
+		http.Error(w, indexOutOfRangeErr.Error(), http.StatusNotFound)
+		return
+	}
+
+	selector, err := ParseLabelSelector(r.URL.Query().Get("labels"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	p, err := NewProfileUnPacker(mergedProfile, ah.opts...).UnpackFiltered(idx, selector)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	bb := bytes.NewBuffer(nil)
+	if err = p.Write(bb); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fmt.Sprintf("%s.%d.pb.gz", name, idx)))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Length", strconv.Itoa(bb.Len()))
+	if r.Method == http.MethodHead {
+		return
+	}
+	_, _ = w.Write(bb.Bytes())
+}
+
+// parsePath splits /archive/{name}[/{idx}] into name and idx
+func (ah *ArchiveHandler) parsePath(urlPath string) (string, string, error) {
+	rest, ok := strings.CutPrefix(urlPath, archivePathPrefix)
+	if !ok {
+		return "", "", errors.New("unknown path")
+	}
+
+	rest = strings.TrimSuffix(rest, "/")
+	name, idx, _ := strings.Cut(rest, "/")
+	if name == "" || name == "." || name == ".." || strings.Contains(idx, "/") {
+		return "", "", errors.New("unknown path")
+	}
+
+	return name, idx, nil
+}
+
+func (ah *ArchiveHandler) readArchive(name string) (*MergedProfile, error) {
+	file, err := os.Open(filepath.Join(ah.dir, name))
+	if err != nil {
+		return nil, errors.Wrap(err, "open archive")
+	}
+	defer file.Close()
+
+	gzReader, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, errors.Wrap(err, "read archive")
+	}
+
+	rawProfile, err := io.ReadAll(gzReader)
+	if err != nil {
+		return nil, errors.Wrap(err, "read archive")
+	}
+
+	mergedProfile := new(MergedProfile)
+	if err = mergedProfile.UnmarshalVT(rawProfile); err != nil {
+		return nil, errors.Wrap(err, "unmarshal archive")
+	}
+
+	return mergedProfile, nil
+}
+
+func parseArchiveTime(val string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339Nano, val); err == nil {
+		return t, nil
+	}
+
+	secs, err := strconv.ParseFloat(val, 64)
+	if err != nil {
+		return time.Time{}, errors.Errorf("invalid time %q: expected RFC 3339 timestamp or unix seconds", val)
+	}
+
+	return time.Unix(0, int64(secs*float64(time.Second))), nil
+}
+
+// findEntryByTime returns index of the entry captured at t
+func findEntryByTime(mergedProfile *MergedProfile, t time.Time) (uint64, bool) {
+	nanos := t.UnixNano()
+
+	var (
+		found     bool
+		bestIdx   uint64
+		bestNanos int64
+	)
+	for i, timeNanos := range mergedProfile.TimesNanos {
+		if timeNanos > nanos {
+			continue
+		}
+		if i < len(mergedProfile.DurationsNanos) && nanos < timeNanos+mergedProfile.DurationsNanos[i] {
+			return uint64(i), true
+		}
+		if !found || timeNanos >= bestNanos {
+			found, bestIdx, bestNanos = true, uint64(i), timeNanos
+		}
+	}
+
+	return bestIdx, found
+}
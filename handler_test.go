@@ -0,0 +1,138 @@
+package ppmerge
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	pprofile "github.com/google/pprof/profile"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArchiveHandler(t *testing.T) {
+	dir := t.TempDir()
+	profiles := getProfilesVtProto(t, false, "hprof1", "hprof2", "hprof3", "hprof4")
+
+	profileMerger := NewProfileMerger()
+	profileMerger.Merge(profiles...)
+
+	file, err := os.Create(filepath.Join(dir, "heap"))
+	require.NoError(t, err)
+	require.NoError(t, profileMerger.WriteCompressed(file))
+	require.NoError(t, file.Close())
+
+	srv := httptest.NewServer(NewArchiveHandler(dir))
+	defer srv.Close()
+
+	fetch := func(t *testing.T, path string) (*http.Response, *pprofile.Profile) {
+		resp, err := http.Get(srv.URL + path)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return resp, nil
+		}
+		p, err := pprofile.Parse(resp.Body)
+		require.NoError(t, err)
+		return resp, p
+	}
+
+	t.Run("by index", func(t *testing.T) {
+		for i, expected := range profiles {
+			resp, p := fetch(t, "/archive/heap/"+strconv.Itoa(i))
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+			require.Len(t, p.Sample, len(expected.Sample))
+			require.Equal(t, expected.TimeNanos, p.TimeNanos)
+		}
+	})
+
+	t.Run("by time", func(t *testing.T) {
+		// hprof2 covers [TimeNanos, TimeNanos+DurationNanos)
+		ts := time.Unix(0, profiles[1].TimeNanos+int64(5*time.Second)).Format(time.RFC3339Nano)
+		resp, p := fetch(t, "/archive/heap?time="+ts)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, profiles[1].TimeNanos, p.TimeNanos)
+
+		// hprof3 has no duration, so the latest profile captured before is chosen
+		secs := strconv.FormatInt((profiles[2].TimeNanos+int64(10*time.Second))/int64(time.Second), 10)
+		resp, p = fetch(t, "/archive/heap?time="+secs)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, profiles[2].TimeNanos, p.TimeNanos)
+
+		resp, _ = fetch(t, "/archive/heap?time=1")
+		require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+
+	t.Run("by labels", func(t *testing.T) {
+		resp, p := fetch(t, "/archive/heap/0?labels=bytes>=65536,bytes<139264")
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.NotEmpty(t, p.Sample)
+		for _, s := range p.Sample {
+			require.GreaterOrEqual(t, s.NumLabel["bytes"][0], int64(65536))
+			require.Less(t, s.NumLabel["bytes"][0], int64(139264))
+		}
+		require.Less(t, len(p.Sample), len(profiles[0].Sample))
+
+		resp, _ = fetch(t, "/archive/heap/0?labels=bytes>x")
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		resp, _ := fetch(t, "/archive/heap/4")
+		require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+		resp, _ = fetch(t, "/archive/missing/0")
+		require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+		resp, _ = fetch(t, "/archive/heap/abc")
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+		resp, _ = fetch(t, "/archive/heap")
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+		resp, _ = fetch(t, "/archive/heap?time=yesterday")
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+}
+
+func TestArchiveHandlerDictionary(t *testing.T) {
+	dir := t.TempDir()
+	dict, err := NewSymbolDictionary(NewProfileMerger().Merge(getProfilesVtProto(t, false, "hprof1", "hprof2")...))
+	require.NoError(t, err)
+
+	profiles := getProfilesVtProto(t, false, "hprof3", "hprof4")
+	expected := NewProfileMerger().Merge(profiles...)
+	profileMerger := NewProfileMerger(WithDictionary(dict), WithFrontCodedStrings())
+	profileMerger.Merge(profiles...)
+	file, err := os.Create(filepath.Join(dir, "heap"))
+	require.NoError(t, err)
+	require.NoError(t, profileMerger.WriteCompressed(file))
+	require.NoError(t, file.Close())
+
+	fetch := func(t *testing.T, handler http.Handler, path string) (int, *pprofile.Profile) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusOK {
+			return rec.Code, nil
+		}
+		p, err := pprofile.Parse(rec.Body)
+		require.NoError(t, err)
+		return rec.Code, p
+	}
+
+	handler := NewArchiveHandler(dir, WithDictionaryResolver(dictionaryResolver(dict)))
+	for idx := range profiles {
+		code, p := fetch(t, handler, "/archive/heap/"+strconv.Itoa(idx))
+		require.Equal(t, http.StatusOK, code)
+		expectedProfile, err := NewProfileUnPacker(expected).Unpack(uint64(idx))
+		require.NoError(t, err)
+		require.Equal(t, expectedProfile.String(), p.String())
+	}
+
+	// dictionary can't be resolved without resolver
+	code, _ := fetch(t, NewArchiveHandler(dir), "/archive/heap/0")
+	require.Equal(t, http.StatusInternalServerError, code)
+}
@@ -0,0 +1,370 @@
+package ppmerge
+
+import (
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	pprofile "github.com/google/pprof/profile"
+	"github.com/pkg/errors"
+	"github.com/threadedstream/ppmerge/profile"
+)
+
+// MatchType is the kind of comparison done by LabelMatcher
+type MatchType int
+
+const (
+	MatchEqual MatchType = iota
+	MatchNotEqual
+	MatchRegexp
+	MatchNotRegexp
+	MatchLess
+	MatchLessOrEqual
+	MatchGreater
+	MatchGreaterOrEqual
+)
+
+// matchOperators are selector operators of match types, two-character ones go first so that
+// they're preferred while parsing
+var matchOperators = []struct {
+	op string
+	t  MatchType
+}{
+	{"!=", MatchNotEqual},
+	{"=~", MatchRegexp},
+	{"!~", MatchNotRegexp},
+	{"<=", MatchLessOrEqual},
+	{">=", MatchGreaterOrEqual},
+	{"=", MatchEqual},
+	{"<", MatchLess},
+	{">", MatchGreater},
+}
+
+func (t MatchType) String() string {
+	for _, mo := range matchOperators {
+		if mo.t == t {
+			return mo.op
+		}
+	}
+	return "MatchType(" + strconv.Itoa(int(t)) + ")"
+}
+
+// numeric reports whether t compares numeric label values
+func (t MatchType) numeric() bool {
+	return t >= MatchLess && t <= MatchGreaterOrEqual
+}
+
+// LabelMatcher matches values of label Key.
+//
+// Equality and regexp matchers compare string values of Key as well as numeric ones formatted
+// in decimal. = and =~ match samples having at least one matching value, while != and !~
+// match samples having no such value, including samples without Key. Regexps are anchored.
+// <, <=, > and >= match samples having at least one numeric value of Key within the bound,
+// numeric matchers of the same key in LabelSelector must be satisfied by the same value.
+type LabelMatcher struct {
+	Type  MatchType
+	Key   string
+	Value string
+
+	re  *regexp.Regexp
+	num int64
+}
+
+// NewLabelMatcher returns matcher comparing values of key with value
+func NewLabelMatcher(t MatchType, key, value string) (*LabelMatcher, error) {
+	m := &LabelMatcher{
+		Type:  t,
+		Key:   key,
+		Value: value,
+	}
+
+	var err error
+	switch {
+	case t == MatchRegexp || t == MatchNotRegexp:
+		if m.re, err = regexp.Compile("^(?:" + value + ")$"); err != nil {
+			return nil, errors.Wrapf(err, "compile regexp of label %q", key)
+		}
+	case t.numeric():
+		if m.num, err = strconv.ParseInt(value, 10, 64); err != nil {
+			return nil, errors.Errorf("label %q must be compared with integer, got %q", key, value)
+		}
+	case t != MatchEqual && t != MatchNotEqual:
+		return nil, errors.Errorf("unknown match type %d", int(t))
+	}
+
+	return m, nil
+}
+
+func (m *LabelMatcher) String() string {
+	return m.Key + m.Type.String() + strconv.Quote(m.Value)
+}
+
+// matches reports whether labels of merged sample are matched by m
+func (m *LabelMatcher) matches(labels *profile.Labels, stringTable []string) bool {
+	found := false
+	for _, label := range labels.GetLabels() {
+		if stringTable[label.GetKey()] == m.Key && m.matchesValue(label, stringTable) {
+			found = true
+			break
+		}
+	}
+
+	if m.Type == MatchNotEqual || m.Type == MatchNotRegexp {
+		return !found
+	}
+	return found
+}
+
+// matchesValue reports whether value of label is the one m looks for, negative matchers
+// look for values they reject
+func (m *LabelMatcher) matchesValue(label *profile.Label, stringTable []string) bool {
+	isStr := label.GetStr() > 0
+	switch m.Type {
+	case MatchEqual, MatchNotEqual:
+		return labelValue(label, stringTable) == m.Value
+	case MatchRegexp, MatchNotRegexp:
+		return m.re.MatchString(labelValue(label, stringTable))
+	case MatchLess:
+		return !isStr && label.GetNum() < m.num
+	case MatchLessOrEqual:
+		return !isStr && label.GetNum() <= m.num
+	case MatchGreater:
+		return !isStr && label.GetNum() > m.num
+	case MatchGreaterOrEqual:
+		return !isStr && label.GetNum() >= m.num
+	}
+	return false
+}
+
+// labelValue returns value of merged label, numeric values are formatted in decimal
+func labelValue(label *profile.Label, stringTable []string) string {
+	if strIdx := label.GetStr(); strIdx > 0 {
+		return stringTable[strIdx]
+	}
+	return strconv.FormatInt(label.GetNum(), 10)
+}
+
+// LabelSelector matches samples matched by all of its matchers, empty selector matches every sample
+type LabelSelector []*LabelMatcher
+
+// ParseLabelSelector parses comma separated list of matchers such as
+//
+//	tenant=acme,handler=~"/api/.*",bytes>=1024,bytes<4096
+//
+// Values may be quoted as Go strings, which is required for values containing commas.
+// Numeric ranges are expressed by several matchers of the same key, a sample matches the range
+// if one of its values of the key falls into it.
+func ParseLabelSelector(s string) (LabelSelector, error) {
+	var selector LabelSelector
+	for s = strings.TrimSpace(s); s != ""; {
+		i := strings.IndexAny(s, "=!<>")
+		if i <= 0 {
+			return nil, errors.Errorf("invalid label matcher %q", s)
+		}
+		key := strings.TrimSpace(s[:i])
+		s = s[i:]
+
+		t := MatchType(-1)
+		for _, mo := range matchOperators {
+			if strings.HasPrefix(s, mo.op) {
+				t = mo.t
+				s = strings.TrimSpace(s[len(mo.op):])
+				break
+			}
+		}
+		if t < 0 {
+			return nil, errors.Errorf("invalid operator of label %q", key)
+		}
+
+		var value string
+		if strings.HasPrefix(s, `"`) {
+			quoted, err := strconv.QuotedPrefix(s)
+			if err != nil {
+				return nil, errors.Errorf("invalid quoted value of label %q", key)
+			}
+			value, _ = strconv.Unquote(quoted)
+			rest := strings.TrimSpace(s[len(quoted):])
+			if rest != "" && rest[0] != ',' {
+				return nil, errors.Errorf("unexpected %q after value of label %q", rest, key)
+			}
+			s = strings.TrimPrefix(rest, ",")
+		} else {
+			value, s, _ = strings.Cut(s, ",")
+			value = strings.TrimSpace(value)
+		}
+		s = strings.TrimSpace(s)
+
+		m, err := NewLabelMatcher(t, key, value)
+		if err != nil {
+			return nil, err
+		}
+		selector = append(selector, m)
+	}
+
+	return selector, nil
+}
+
+func (ls LabelSelector) String() string {
+	matchers := make([]string, len(ls))
+	for i, m := range ls {
+		matchers[i] = m.String()
+	}
+	return strings.Join(matchers, ",")
+}
+
+// matches reports whether labels of merged sample are matched by all matchers of ls.
+// Numeric matchers of the same key must be satisfied by a single value of it.
+func (ls LabelSelector) matches(labels *profile.Labels, stringTable []string) bool {
+	for i, m := range ls {
+		if !m.Type.numeric() {
+			if !m.matches(labels, stringTable) {
+				return false
+			}
+			continue
+		}
+
+		if slices.ContainsFunc(ls[:i], func(prev *LabelMatcher) bool {
+			return prev.Type.numeric() && prev.Key == m.Key
+		}) {
+			// bounds of key were checked along with the first of them
+			continue
+		}
+		if !ls.matchesBounds(m.Key, labels, stringTable) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchesBounds reports whether some value of key satisfies every numeric matcher of ls of that key
+func (ls LabelSelector) matchesBounds(key string, labels *profile.Labels, stringTable []string) bool {
+	for _, label := range labels.GetLabels() {
+		if stringTable[label.GetKey()] != key {
+			continue
+		}
+
+		inBounds := true
+		for _, m := range ls {
+			if m.Type.numeric() && m.Key == key && !m.matchesValue(label, stringTable) {
+				inBounds = false
+				break
+			}
+		}
+		if inBounds {
+			return true
+		}
+	}
+	return false
+}
+
+// FindEntries returns indexes of entries having at least one sample matched by selector.
+// Only labels of merged profile are inspected, entries aren't unpacked.
+func (x *MergedProfile) FindEntries(selector LabelSelector) ([]uint64, error) {
+	x, err := x.Decoded(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		idxs   []uint64
+		offset uint64
+	)
+	for idx, numSamples := range x.NumSamples {
+		for i := offset; i < offset+numSamples; i++ {
+			if selector.matches(x.Labels[i], x.StringTable) {
+				idxs = append(idxs, uint64(idx))
+				break
+			}
+		}
+		offset += numSamples
+	}
+	return idxs, nil
+}
+
+// AggregateByLabel sums values of sampleType over samples of entries idxs grouped by values
+// of label key, every entry is aggregated if idxs is empty. Sample having several values of key
+// is counted once per distinct value, samples without key are grouped under empty string.
+// The last sample type of every entry is used if sampleType is empty.
+func (x *MergedProfile) AggregateByLabel(key, sampleType string, idxs ...uint64) (map[string]int64, error) {
+	x, err := x.Decoded(nil)
+	if err != nil {
+		return nil, err
+	}
+	numEntries := uint64(len(x.NumSamples))
+	if len(idxs) == 0 {
+		idxs = make([]uint64, numEntries)
+		for i := range idxs {
+			idxs[i] = uint64(i)
+		}
+	}
+
+	// prefix sums of samples and sample types, so that every entry is located at once
+	sampleOffsets := make([]uint64, numEntries+1)
+	sampleTypeOffsets := make([]uint64, numEntries+1)
+	for i := uint64(0); i < numEntries; i++ {
+		sampleOffsets[i+1] = sampleOffsets[i] + x.NumSamples[i]
+		if i < uint64(len(x.NumSampleTypes)) {
+			sampleTypeOffsets[i+1] = sampleTypeOffsets[i] + x.NumSampleTypes[i]
+		}
+	}
+
+	totals := make(map[string]int64)
+	var values []string
+	for _, idx := range idxs {
+		if idx >= numEntries || idx >= uint64(len(x.NumSampleTypes)) {
+			return nil, errors.Wrapf(indexOutOfRangeErr, "entry %d", idx)
+		}
+
+		valueIdx := x.sampleTypeIndex(sampleTypeOffsets[idx], x.NumSampleTypes[idx], sampleType)
+		if valueIdx < 0 {
+			return nil, errors.Errorf("entry %d has no sample type %q", idx, sampleType)
+		}
+
+		for offset := sampleOffsets[idx]; offset < sampleOffsets[idx+1]; offset++ {
+			sample := x.Samples[offset]
+			if valueIdx >= len(sample.Value) {
+				continue
+			}
+
+			values = values[:0]
+			for _, label := range x.Labels[offset].GetLabels() {
+				if x.StringTable[label.GetKey()] != key {
+					continue
+				}
+				if value := labelValue(label, x.StringTable); !slices.Contains(values, value) {
+					values = append(values, value)
+				}
+			}
+			if len(values) == 0 {
+				values = append(values, "")
+			}
+
+			for _, value := range values {
+				totals[value] += sample.Value[valueIdx]
+			}
+		}
+	}
+
+	return totals, nil
+}
+
+// sampleTypeIndex returns index of sampleType among numSampleTypes sample types starting
+// at pair offset, the last sample type is used if it's empty
+func (x *MergedProfile) sampleTypeIndex(offset, numSampleTypes uint64, sampleType string) int {
+	if sampleType == "" {
+		return int(numSampleTypes) - 1
+	}
+	for i := uint64(0); i < numSampleTypes; i++ {
+		if x.StringTable[x.SampleType[(offset+i)*2]] == sampleType {
+			return int(i)
+		}
+	}
+	return -1
+}
+
+// UnpackFiltered recovers profile idx keeping only samples matched by selector. Functions,
+// locations and mappings referenced by dropped samples only are left out as well.
+func (pu *ProfileUnPacker) UnpackFiltered(idx uint64, selector LabelSelector) (*pprofile.Profile, error) {
+	return pu.unpack(idx, selector)
+}
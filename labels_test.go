@@ -0,0 +1,173 @@
+package ppmerge
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/threadedstream/ppmerge/profile"
+)
+
+func TestParseLabelSelector(t *testing.T) {
+	selector, err := ParseLabelSelector(` tenant = acme, handler=~"/api/(a|b),c" ,bytes>=1024,bytes<4096,kind!=,name!~x.*`)
+	require.NoError(t, err)
+	require.Len(t, selector, 6)
+
+	expected := []struct {
+		t          MatchType
+		key, value string
+	}{
+		{MatchEqual, "tenant", "acme"},
+		{MatchRegexp, "handler", "/api/(a|b),c"},
+		{MatchGreaterOrEqual, "bytes", "1024"},
+		{MatchLess, "bytes", "4096"},
+		{MatchNotEqual, "kind", ""},
+		{MatchNotRegexp, "name", "x.*"},
+	}
+	for i, m := range selector {
+		require.Equal(t, expected[i].t, m.Type)
+		require.Equal(t, expected[i].key, m.Key)
+		require.Equal(t, expected[i].value, m.Value)
+	}
+
+	// String is parsable back to the same selector
+	reparsed, err := ParseLabelSelector(selector.String())
+	require.NoError(t, err)
+	require.Equal(t, selector.String(), reparsed.String())
+
+	selector, err = ParseLabelSelector("")
+	require.NoError(t, err)
+	require.Empty(t, selector)
+
+	for _, invalid := range []string{"tenant", "=acme", "bytes>x", `name=~"("`, `name="unterminated`, `name="a"b`, "name=~("} {
+		_, err = ParseLabelSelector(invalid)
+		require.Error(t, err, invalid)
+	}
+
+	_, err = NewLabelMatcher(MatchType(42), "key", "value")
+	require.Error(t, err)
+}
+
+func TestFindEntries(t *testing.T) {
+	mergedProfile := NewProfileMerger().Merge(getProfilesVtProto(t, false, "labels.prof", "multilabels.prof", "hprof1")...)
+
+	for selector, expected := range map[string][]uint64{
+		"":                        {0, 1, 2},
+		"label=value":             {0},
+		"label=value,tag=a":       nil,
+		"tag=a":                   {1},
+		"tag!=a":                  {0, 1, 2},
+		"tag=~[ab]":               {1},
+		"tag!~.*":                 {0, 1, 2},
+		"span=x,tag=b":            {1},
+		"bytes=1024":              {1},
+		"bytes>=1024":             {1, 2},
+		"bytes>=100000":           {2},
+		"latency>6,latency<=7":    {1},
+		"latency>7":               nil,
+		"bytes>1024,bytes<2048":   nil,
+		"bytes>1024,bytes<=2048":  {1},
+		"another-label=~second.*": {0},
+	} {
+		parsed, err := ParseLabelSelector(selector)
+		require.NoError(t, err)
+		idxs, err := mergedProfile.FindEntries(parsed)
+		require.NoError(t, err)
+		require.Equal(t, expected, idxs, selector)
+	}
+
+	// archive referring to symbol dictionary can't be searched until dictionary is attached
+	dict, err := NewSymbolDictionary(mergedProfile)
+	require.NoError(t, err)
+	_, err = mergedProfile.withoutDictionary(dict).FindEntries(nil)
+	require.ErrorIs(t, err, noDictionaryResolverErr)
+}
+
+func TestLabelSelectorMultiValued(t *testing.T) {
+	stringTable := []string{"", "bytes", "tenant", "acme"}
+	labels := &profile.Labels{Labels: []*profile.Label{
+		{Key: 1, Num: 10},
+		{Key: 2, Str: 3},
+		{Key: 1, Num: 100000},
+	}}
+
+	for selector, expected := range map[string]bool{
+		"bytes>=1024,bytes<4096":             false,
+		"bytes<4096,tenant=acme,bytes>=1024": false,
+		"bytes>=1024":                        true,
+		"bytes<4096":                         true,
+		"bytes>=5,bytes<=10":                 true,
+		"bytes>10,bytes<100000":              false,
+		"bytes>=1024,bytes<=100000":          true,
+		"bytes>=1024,bytes!=100000":          false,
+	} {
+		parsed, err := ParseLabelSelector(selector)
+		require.NoError(t, err)
+		require.Equal(t, expected, parsed.matches(labels, stringTable), selector)
+	}
+}
+
+func TestUnpackFiltered(t *testing.T) {
+	profiles := getProfilesVtProto(t, false, "labels.prof", "multilabels.prof")
+	mergedProfile := NewProfileMerger().Merge(profiles...)
+	unpacker := NewProfileUnPacker(mergedProfile)
+
+	selector, err := ParseLabelSelector("tag=~a|c")
+	require.NoError(t, err)
+	p, err := unpacker.UnpackFiltered(1, selector)
+	require.NoError(t, err)
+	require.NoError(t, p.CheckValid())
+	require.Len(t, p.Sample, 2)
+	require.Equal(t, []int64{3, 30000000}, p.Sample[0].Value)
+	require.Equal(t, []string{"a", "b", "a"}, p.Sample[0].Label["tag"])
+	require.Equal(t, []int64{1024, 2048}, p.Sample[0].NumLabel["bytes"])
+	require.Equal(t, []int64{1, 10000000}, p.Sample[1].Value)
+
+	// locations, functions and mappings of dropped samples are left out
+	selector, err = ParseLabelSelector("count<3")
+	require.NoError(t, err)
+	p, err = unpacker.UnpackFiltered(1, selector)
+	require.NoError(t, err)
+	require.NoError(t, p.CheckValid())
+	require.Len(t, p.Sample, 1)
+	require.Equal(t, []int64{2, 20000000}, p.Sample[0].Value)
+	require.Len(t, p.Location, 1)
+	require.Len(t, p.Function, 1)
+
+	// empty selector keeps every sample
+	p, err = unpacker.UnpackFiltered(0, nil)
+	require.NoError(t, err)
+	expected, err := unpacker.Unpack(0)
+	require.NoError(t, err)
+	require.Equal(t, expected.String(), p.String())
+
+	_, err = unpacker.UnpackFiltered(2, nil)
+	require.Error(t, err)
+}
+
+func TestAggregateByLabel(t *testing.T) {
+	mergedProfile := NewProfileMerger().Merge(getProfilesVtProto(t, false, "labels.prof", "multilabels.prof", "hprof1")...)
+
+	totals, err := mergedProfile.AggregateByLabel("tag", "samples", 1)
+	require.NoError(t, err)
+	require.Equal(t, map[string]int64{"a": 3, "b": 3, "c": 1, "": 2}, totals)
+
+	// the last sample type is used by default
+	totals, err = mergedProfile.AggregateByLabel("tag", "", 1)
+	require.NoError(t, err)
+	require.Equal(t, map[string]int64{"a": 30000000, "b": 30000000, "c": 10000000, "": 20000000}, totals)
+
+	totals, err = mergedProfile.AggregateByLabel("label", "samples", 0, 1)
+	require.NoError(t, err)
+	require.Equal(t, map[string]int64{"value": 2, "": 7}, totals)
+
+	totals, err = mergedProfile.AggregateByLabel("bytes", "inuse_objects", 2)
+	require.NoError(t, err)
+	require.Equal(t, map[string]int64{"139264": -4, "65536": -8, "663552": -1}, totals)
+
+	// hprof1 has no samples sample type
+	_, err = mergedProfile.AggregateByLabel("tag", "samples")
+	require.ErrorContains(t, err, "entry 2")
+
+	_, err = mergedProfile.AggregateByLabel("tag", "samples", 3)
+	require.Error(t, err)
+}
@@ -0,0 +1,182 @@
+package ppmerge
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/threadedstream/ppmerge/profile"
+)
+
+// GoroutineLeak is a stack number of goroutines of which grows across entries
+type GoroutineLeak struct {
+	// Frames are the top frames of stack, the innermost first, as "function file:line"
+	Frames []string
+	// Totals are numbers of goroutines having the stack in every analyzed entry
+	Totals []uint64
+	// Growth is the number of goroutines added from the first analyzed entry to the last one
+	Growth uint64
+	// Rate is the slope of linear fit of Totals, i.e. goroutines added per entry
+	Rate float64
+	// Monotonic tells whether number of goroutines never decreased
+	Monotonic bool
+}
+
+// Leaks matches stacks of entries idxs by their frames and returns the ones number of goroutines
+// of which grows: either monotonically, or past threshold in the last entry, if threshold isn't
+// zero. Leaks are ranked by growth rate, at most topFrames frames of every stack are reported,
+// or all of them if topFrames isn't positive. Every entry is analyzed if idxs is empty, entries
+// are analyzed in order of idxs.
+func (x *MergedGoroutineProfile) Leaks(threshold uint64, topFrames int, idxs ...uint64) ([]GoroutineLeak, error) {
+	x, err := x.Decoded()
+	if err != nil {
+		return nil, err
+	}
+
+	numEntries := uint64(len(x.NumStacktraces))
+	if len(idxs) == 0 {
+		idxs = make([]uint64, numEntries)
+		for i := range idxs {
+			idxs[i] = uint64(i)
+		}
+	}
+
+	offsets := make([]uint64, numEntries+1)
+	for i := uint64(0); i < numEntries; i++ {
+		offsets[i+1] = offsets[i] + x.NumStacktraces[i]
+	}
+	if offsets[numEntries] > uint64(len(x.Stacktraces)) {
+		return nil, indexOutOfRangeErr
+	}
+
+	if len(idxs) < 2 {
+		// growth needs at least two entries
+		return nil, nil
+	}
+
+	// totals of every stack by its key, stacks are kept in order of the first occurrence
+	var (
+		keys   []string
+		stacks = make(map[string]*profile.Stacktrace)
+		totals = make(map[string][]uint64)
+	)
+	for i, idx := range idxs {
+		if idx >= numEntries {
+			return nil, errors.Wrapf(indexOutOfRangeErr, "entry %d", idx)
+		}
+		for _, st := range x.Stacktraces[offsets[idx]:offsets[idx+1]] {
+			key := getLeakKey(st)
+			if _, ok := stacks[key]; !ok {
+				keys = append(keys, key)
+				stacks[key] = st
+				totals[key] = make([]uint64, len(idxs))
+			}
+			// the same stack is listed once per distinct labels
+			totals[key][i] += st.Total
+		}
+	}
+
+	var leaks []GoroutineLeak
+	for _, key := range keys {
+		stackTotals := totals[key]
+		first, last := stackTotals[0], stackTotals[len(stackTotals)-1]
+		if last <= first {
+			continue
+		}
+
+		monotonic := true
+		for i := 1; i < len(stackTotals); i++ {
+			if stackTotals[i] < stackTotals[i-1] {
+				monotonic = false
+				break
+			}
+		}
+		if !monotonic && (threshold == 0 || last < threshold) {
+			continue
+		}
+
+		leaks = append(leaks, GoroutineLeak{
+			Frames:    x.leakFrames(stacks[key], topFrames),
+			Totals:    stackTotals,
+			Growth:    last - first,
+			Rate:      slope(stackTotals),
+			Monotonic: monotonic,
+		})
+	}
+
+	sort.SliceStable(leaks, func(i, j int) bool {
+		return leaks[i].Rate > leaks[j].Rate
+	})
+	return leaks, nil
+}
+
+// leakFrames returns at most topFrames frames of st, the innermost first
+func (x *MergedGoroutineProfile) leakFrames(st *profile.Stacktrace, topFrames int) []string {
+	var frames []string
+	if len(st.Frames) == 0 {
+		// unsymbolized stack
+		pcs := st.PC
+		if topFrames > 0 {
+			pcs = pcs[:min(len(pcs), topFrames)]
+		}
+		for _, pc := range pcs {
+			frames = append(frames, fmt.Sprintf("%#x", pc))
+		}
+		return frames
+	}
+
+	stackFrames := st.Frames
+	if topFrames > 0 {
+		stackFrames = stackFrames[:min(len(stackFrames), topFrames)]
+	}
+	for _, f := range stackFrames {
+		name := x.getString(f.FunctionName)
+		if name == "" {
+			name = fmt.Sprintf("%#x", f.Address)
+		}
+		frames = append(frames, fmt.Sprintf("%s %s:%d", name, x.getString(f.Filename), f.Line))
+	}
+	return frames
+}
+
+// getLeakKey returns key of st, stacks having the same interned frames have equal keys
+func getLeakKey(st *profile.Stacktrace) string {
+	var sb strings.Builder
+	if len(st.Frames) == 0 {
+		for _, pc := range st.PC {
+			sb.WriteString(strconv.FormatUint(pc, 16))
+			sb.WriteByte('|')
+		}
+		return sb.String()
+	}
+
+	for _, f := range st.Frames {
+		sb.WriteByte(';')
+		sb.WriteString(strconv.FormatUint(f.FunctionName, 16))
+		sb.WriteByte(',')
+		sb.WriteString(strconv.FormatUint(f.Filename, 16))
+		sb.WriteByte(',')
+		sb.WriteString(strconv.FormatUint(f.Line, 16))
+	}
+	return sb.String()
+}
+
+// slope returns slope of linear least squares fit of ys over their indexes
+func slope(ys []uint64) float64 {
+	n := float64(len(ys))
+	var sumX, sumY, sumXY, sumXX float64
+	for i, y := range ys {
+		x := float64(i)
+		sumX += x
+		sumY += float64(y)
+		sumXY += x * float64(y)
+		sumXX += x * x
+	}
+	denominator := n*sumXX - sumX*sumX
+	if denominator == 0 {
+		return 0
+	}
+	return (n*sumXY - sumX*sumY) / denominator
+}
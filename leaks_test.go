@@ -0,0 +1,87 @@
+package ppmerge
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGoroutineLeaks(t *testing.T) {
+	paths := []string{"parca_goroutine_debug_1_1", "parca_goroutine_debug_1_1", "parca_goroutine_debug_1_1", "parca_goroutine_debug_1_1"}
+	gps := getGoroutineProfiles(t, paths...)
+	// the first stack leaks steadily, the second one grows with a dip, the third one shrinks
+	for i, gp := range gps {
+		gp.Stacktraces[0].Total += []uint64{0, 6, 12, 26}[i]
+		gp.Stacktraces[1].Total += []uint64{0, 22, 17, 27}[i]
+		gp.Stacktraces[2].Total -= uint64(i)
+	}
+	mergedProfile := NewGoroutineProfileMerger().Merge(gps...)
+
+	leaks, err := mergedProfile.Leaks(0, 2)
+	require.NoError(t, err)
+	require.Len(t, leaks, 1)
+	require.Equal(t, []string{"github.com/parca-dev/parca/pkg/scrape.(*scrapeLoop).run /Users/gildarov/toys/parca/pkg/scrape/scrape.go:590"}, leaks[0].Frames)
+	require.Equal(t, uint64(26), leaks[0].Growth)
+	require.True(t, leaks[0].Monotonic)
+	require.InDelta(t, 8.4, leaks[0].Rate, 1e-9)
+
+	// the second stack is listed under several labels, so its totals are summed up
+	leaks, err = mergedProfile.Leaks(30, 1)
+	require.NoError(t, err)
+	require.Len(t, leaks, 2)
+	require.Equal(t, uint64(26), leaks[0].Growth)
+	require.Equal(t, uint64(27), leaks[1].Growth)
+	require.False(t, leaks[1].Monotonic)
+	require.Len(t, leaks[1].Frames, 1)
+	require.Contains(t, leaks[1].Frames[0], "grpcsync.(*CallbackSerializer).run")
+
+	leaks, err = mergedProfile.Leaks(leaks[1].Totals[3]+1, 1)
+	require.NoError(t, err)
+	require.Len(t, leaks, 1)
+
+	// entries are analyzed in order of idxs, so the leak turns into shrinking
+	leaks, err = mergedProfile.Leaks(0, 1, 3, 2, 1, 0)
+	require.NoError(t, err)
+	for _, leak := range leaks {
+		require.NotContains(t, leak.Frames[0], "scrapeLoop")
+	}
+
+	leaks, err = mergedProfile.Leaks(0, 1, 0)
+	require.NoError(t, err)
+	require.Empty(t, leaks)
+
+	_, err = mergedProfile.Leaks(0, 1, 0, 4)
+	require.Error(t, err)
+}
+
+func TestGoroutineLeaksArchive(t *testing.T) {
+	paths := []string{"parca_goroutine_debug_1_1", "parca_goroutine_debug_1_2", "parca_goroutine_debug_1_3"}
+	expected, err := NewGoroutineProfileMerger().Merge(getGoroutineProfiles(t, paths...)...).Leaks(1, 3)
+	require.NoError(t, err)
+	require.NotEmpty(t, expected)
+	for i, leak := range expected {
+		require.Positive(t, leak.Growth)
+		require.LessOrEqual(t, len(leak.Frames), 3)
+		if i > 0 {
+			require.GreaterOrEqual(t, expected[i-1].Rate, leak.Rate)
+		}
+	}
+
+	goroutineMerger := NewGoroutineProfileMerger(WithDeltaGoroutineTotals(), WithFrontCodedGoroutineStrings())
+	goroutineMerger.Merge(getGoroutineProfiles(t, paths...)...)
+	var compressed bytes.Buffer
+	require.NoError(t, goroutineMerger.WriteCompressed(&compressed))
+	zr, err := gzip.NewReader(&compressed)
+	require.NoError(t, err)
+	data, err := io.ReadAll(zr)
+	require.NoError(t, err)
+
+	archive := new(MergedGoroutineProfile)
+	require.NoError(t, archive.UnmarshalVT(data))
+	actual, err := archive.Leaks(1, 3)
+	require.NoError(t, err)
+	require.Equal(t, expected, actual)
+}
@@ -7,6 +7,7 @@ import (
 	"math"
 	"strconv"
 	"strings"
+	"time"
 
 	pprofile "github.com/google/pprof/profile"
 	"github.com/pkg/errors"
@@ -27,6 +28,7 @@ type functionKey struct {
 type mappingKey struct {
 	start, limit, offset uint64
 	buildIDOrFile        int64
+	normalized           bool
 }
 
 type locationKey struct {
@@ -35,23 +37,40 @@ type locationKey struct {
 	isFolded           bool
 }
 
-// ProfileUnPacker recovers any of the profiles stored inside mergedProfile
+// ProfileUnPacker recovers any of the profiles stored inside mergedProfile.
+//
+// Merged profile passed to NewProfileUnPacker stays owned by the caller, while the one
+// decoded by UnpackRaw is owned by unpacker and is returned to the pool by Release. Encoded
+// merged profile is decoded once into a copy kept by unpacker, merged profile itself is never
+// modified, so that several unpackers may read it concurrently.
 type ProfileUnPacker struct {
 	mergedProfile *MergedProfile
+	ownsProfile   bool
+	// mergedProfile decoded by MergedProfile.Decoded
+	decoded *MergedProfile
 
 	functionByID map[uint64]*pprofile.Function
 	mappingByID  map[uint64]*pprofile.Mapping
 	locationByID map[uint64]*pprofile.Location
+
+	// starts of mappings normalized in unpacked entry
+	mappingStarts map[uint64]uint64
+
+	resolver DictionaryResolver
 }
 
 // NewProfileUnPacker returns ProfileUnPacker instance
-func NewProfileUnPacker(mergedProfile *MergedProfile) *ProfileUnPacker {
-	return &ProfileUnPacker{
+func NewProfileUnPacker(mergedProfile *MergedProfile, opts ...UnPackerOption) *ProfileUnPacker {
+	pu := &ProfileUnPacker{
 		mergedProfile: mergedProfile,
 		functionByID:  make(map[uint64]*pprofile.Function),
 		mappingByID:   make(map[uint64]*pprofile.Mapping),
 		locationByID:  make(map[uint64]*pprofile.Location),
 	}
+	for _, opt := range opts {
+		opt(pu)
+	}
+	return pu
 }
 
 func (pu *ProfileUnPacker) UnpackRaw(compressedRawProfile []byte, idx uint64) (*pprofile.Profile, error) {
@@ -68,9 +87,11 @@ func (pu *ProfileUnPacker) UnpackRaw(compressedRawProfile []byte, idx uint64) (*
 	}
 
 	if pu.mergedProfile == nil {
-		pu.mergedProfile = new(MergedProfile)
+		pu.mergedProfile = MergedProfileFromVTPool()
+		pu.ownsProfile = true
 	}
 
+	pu.decoded = nil
 	if err = proto.Unmarshal(rawProfile, pu.mergedProfile); err != nil {
 		return nil, err
 	}
@@ -78,12 +99,55 @@ func (pu *ProfileUnPacker) UnpackRaw(compressedRawProfile []byte, idx uint64) (*
 	return pu.Unpack(idx)
 }
 
+// Reset clears state kept between calls, merged profile decoded by UnpackRaw is reset too.
+func (pu *ProfileUnPacker) Reset() {
+	pu.resetCaches()
+	pu.decoded = nil
+	if pu.ownsProfile {
+		pu.mergedProfile.ResetVT()
+	}
+}
+
+// Release returns merged profile decoded by UnpackRaw to the vtproto pool and drops
+// reference to the one passed to NewProfileUnPacker. Profiles returned by Unpack stay valid.
+func (pu *ProfileUnPacker) Release() {
+	pu.resetCaches()
+	pu.decoded = nil
+	if pu.ownsProfile {
+		pu.mergedProfile.ReturnToVTPool()
+	}
+	pu.mergedProfile = nil
+	pu.ownsProfile = false
+}
+
+// resetCaches forgets functions, mappings and locations of previously unpacked profile
+func (pu *ProfileUnPacker) resetCaches() {
+	clear(pu.functionByID)
+	clear(pu.mappingByID)
+	clear(pu.locationByID)
+	clear(pu.mappingStarts)
+}
+
+// Unpack recovers profile idx. Returned profile is owned by the caller.
 func (pu *ProfileUnPacker) Unpack(idx uint64) (*pprofile.Profile, error) {
+	return pu.unpack(idx, nil)
+}
+
+// unpack recovers profile idx keeping samples matched by selector
+func (pu *ProfileUnPacker) unpack(idx uint64, selector LabelSelector) (*pprofile.Profile, error) {
+	pu.resetCaches()
+	if err := pu.decode(); err != nil {
+		return nil, err
+	}
+	if err := pu.loadMappingStarts(idx); err != nil {
+		return nil, errors.Wrap(err, "unpack mapping starts")
+	}
+
 	var p pprofile.Profile
 	if err := pu.unpackSampleTypes(&p, idx); err != nil {
 		return nil, errors.Wrap(err, "unpack sample types")
 	}
-	if err := pu.unpackSamples(&p, idx); err != nil {
+	if err := pu.unpackSamples(&p, idx, selector); err != nil {
 		return nil, errors.Wrap(err, "unpack samples")
 	}
 	if err := pu.unpackPeriodType(&p, idx); err != nil {
@@ -101,39 +165,40 @@ func (pu *ProfileUnPacker) Unpack(idx uint64) (*pprofile.Profile, error) {
 	return &p, nil
 }
 
-func (pu *ProfileUnPacker) unpackSamples(p *pprofile.Profile, idx uint64) error {
-	if idx > uint64(len(pu.mergedProfile.NumSamples)) {
+func (pu *ProfileUnPacker) unpackSamples(p *pprofile.Profile, idx uint64, selector LabelSelector) error {
+	if idx >= uint64(len(pu.decoded.NumSamples)) {
 		return indexOutOfRangeErr
 	}
 
-	numSamples := pu.mergedProfile.NumSamples[idx]
+	numSamples := pu.decoded.NumSamples[idx]
 
 	var offset uint64
 	for i := uint64(0); i < idx; i++ {
-		offset += pu.mergedProfile.NumSamples[i]
+		offset += pu.decoded.NumSamples[i]
 	}
 
 	limit := offset + numSamples
 
 	p.Sample = make([]*pprofile.Sample, 0, numSamples)
-	for offset < limit {
-		p.Sample = append(p.Sample, pu.unpackSample(p, offset))
-		offset++
+	for ; offset < limit; offset++ {
+		if selector.matches(pu.decoded.Labels[offset], pu.decoded.StringTable) {
+			p.Sample = append(p.Sample, pu.unpackSample(p, offset))
+		}
 	}
 
 	return nil
 }
 
 func (pu *ProfileUnPacker) unpackSampleTypes(p *pprofile.Profile, idx uint64) error {
-	if idx > uint64(len(pu.mergedProfile.NumSampleTypes)) {
+	if idx >= uint64(len(pu.decoded.NumSampleTypes)) {
 		return indexOutOfRangeErr
 	}
 
-	numSampleTypes := pu.mergedProfile.NumSampleTypes[idx]
+	numSampleTypes := pu.decoded.NumSampleTypes[idx]
 
 	var offset uint64
 	for i := uint64(0); i < idx; i++ {
-		offset += pu.mergedProfile.NumSampleTypes[i] * 2
+		offset += pu.decoded.NumSampleTypes[i] * 2
 	}
 
 	limit := offset + (numSampleTypes * 2)
@@ -149,64 +214,64 @@ func (pu *ProfileUnPacker) unpackSampleTypes(p *pprofile.Profile, idx uint64) er
 
 func (pu *ProfileUnPacker) unpackSampleType(offset uint64) *pprofile.ValueType {
 	var vt pprofile.ValueType
-	vt.Type = pu.getString(int(pu.mergedProfile.SampleType[offset]))
+	vt.Type = pu.getString(int(pu.decoded.SampleType[offset]))
 	offset++
-	vt.Unit = pu.getString(int(pu.mergedProfile.SampleType[offset]))
+	vt.Unit = pu.getString(int(pu.decoded.SampleType[offset]))
 	offset++
 	return &vt
 }
 
 func (pu *ProfileUnPacker) unpackSample(p *pprofile.Profile, offset uint64) *pprofile.Sample {
 	var s pprofile.Sample
-	sample := pu.mergedProfile.Samples[offset]
+	sample := pu.decoded.Samples[offset]
 	s.Location = make([]*pprofile.Location, 0, len(sample.LocationId))
 	for _, loc := range sample.LocationId {
 		s.Location = append(s.Location, pu.unpackLocation(p, uint64(loc)))
 	}
 	s.Value = sample.Value
-	if labels, ok := pu.mergedProfile.Labels[offset]; ok {
-		profile.ConvertLabels(&s, labels, pu.mergedProfile.StringTable)
+	if labels, ok := pu.decoded.Labels[offset]; ok {
+		profile.ConvertLabels(&s, labels, pu.decoded.StringTable)
 	}
 
 	return &s
 }
 
 func (pu *ProfileUnPacker) unpackPeriodType(p *pprofile.Profile, idx uint64) error {
-	if idx*2 >= uint64(len(pu.mergedProfile.PeriodTypes)) || (idx*2)+1 > uint64(len(pu.mergedProfile.PeriodTypes))-1 {
+	if idx*2 >= uint64(len(pu.decoded.PeriodTypes)) || (idx*2)+1 > uint64(len(pu.decoded.PeriodTypes))-1 {
 		return indexOutOfRangeErr
 	}
 
 	p.PeriodType = new(pprofile.ValueType)
-	p.PeriodType.Type = pu.getString(int(pu.mergedProfile.PeriodTypes[idx*2]))
-	p.PeriodType.Unit = pu.getString(int(pu.mergedProfile.PeriodTypes[idx*2+1]))
+	p.PeriodType.Type = pu.getString(int(pu.decoded.PeriodTypes[idx*2]))
+	p.PeriodType.Unit = pu.getString(int(pu.decoded.PeriodTypes[idx*2+1]))
 
 	return nil
 }
 
 func (pu *ProfileUnPacker) unpackPeriod(p *pprofile.Profile, idx uint64) error {
-	if idx >= uint64(len(pu.mergedProfile.Periods)) {
+	if idx >= uint64(len(pu.decoded.Periods)) {
 		return indexOutOfRangeErr
 	}
 
-	p.Period = pu.mergedProfile.Periods[idx]
+	p.Period = pu.decoded.Periods[idx]
 	return nil
 }
 
 func (pu *ProfileUnPacker) unpackDurationNanos(p *pprofile.Profile, idx uint64) error {
-	if idx >= uint64(len(pu.mergedProfile.DurationsNanos)) {
+	if idx >= uint64(len(pu.decoded.DurationsNanos)) {
 		return indexOutOfRangeErr
 	}
 
-	p.DurationNanos = pu.mergedProfile.DurationsNanos[idx]
+	p.DurationNanos = pu.decoded.DurationsNanos[idx]
 	return nil
 }
 
 func (pu *ProfileUnPacker) unpackTimeNanos(p *pprofile.Profile, idx uint64) error {
-	if idx >= uint64(len(pu.mergedProfile.TimesNanos)) {
+	if idx >= uint64(len(pu.decoded.TimesNanos)) {
 		return indexOutOfRangeErr
 	}
 
-	p.TimeNanos = pu.mergedProfile.TimesNanos[idx]
+	p.TimeNanos = pu.decoded.TimesNanos[idx]
 	return nil
 }
 
@@ -215,15 +280,15 @@ func (pu *ProfileUnPacker) unpackLocation(p *pprofile.Profile, id uint64) *pprof
 		return loc
 	}
 
-	if id < 1 || id > uint64(len(pu.mergedProfile.Locations)) {
+	if id < 1 || id > uint64(len(pu.decoded.Locations)) {
 		return nil
 	}
 
-	mergedLocation := pu.mergedProfile.Locations[id-1]
+	mergedLocation := pu.decoded.Locations[id-1]
 	loc := &pprofile.Location{
 		ID:      uint64(len(p.Location) + 1),
 		Mapping: pu.unpackMapping(p, mergedLocation.MappingId),
-		Address: mergedLocation.Address,
+		Address: mergedLocation.Address + pu.mappingStarts[mergedLocation.MappingId],
 		Line:    make([]pprofile.Line, len(mergedLocation.Line), len(mergedLocation.Line)),
 	}
 
@@ -245,10 +310,10 @@ func (pu *ProfileUnPacker) unpackLine(p *pprofile.Profile, line *MergeLine) ppro
 }
 
 func (pu *ProfileUnPacker) getString(id int) string {
-	if id < 0 || id > len(pu.mergedProfile.StringTable) {
+	if id < 0 || id >= len(pu.decoded.StringTable) {
 		return ""
 	}
-	return pu.mergedProfile.StringTable[id]
+	return pu.decoded.StringTable[id]
 }
 
 func (pu *ProfileUnPacker) unpackFunction(p *pprofile.Profile, id uint64) *pprofile.Function {
@@ -256,11 +321,11 @@ func (pu *ProfileUnPacker) unpackFunction(p *pprofile.Profile, id uint64) *pprof
 		return fn
 	}
 
-	if id < 1 || id > uint64(len(pu.mergedProfile.Functions)) {
+	if id < 1 || id > uint64(len(pu.decoded.Functions)) {
 		return nil
 	}
 
-	mergedFunction := pu.mergedProfile.Functions[id-1]
+	mergedFunction := pu.decoded.Functions[id-1]
 
 	fn := &pprofile.Function{
 		ID:         uint64(len(p.Function) + 1),
@@ -279,15 +344,16 @@ func (pu *ProfileUnPacker) unpackMapping(p *pprofile.Profile, id uint64) *pprofi
 		return m
 	}
 
-	if id < 1 || id > uint64(len(pu.mergedProfile.Mappings)) {
+	if id < 1 || id > uint64(len(pu.decoded.Mappings)) {
 		return nil
 	}
 
-	mergedMapping := pu.mergedProfile.Mappings[id-1]
+	mergedMapping := pu.decoded.Mappings[id-1]
+	start := pu.mappingStarts[id]
 	profileMapping := &pprofile.Mapping{
 		ID:              uint64(len(p.Mapping) + 1),
-		Start:           mergedMapping.MemoryStart,
-		Limit:           mergedMapping.MemoryLimit,
+		Start:           mergedMapping.MemoryStart + start,
+		Limit:           mergedMapping.MemoryLimit + start,
 		Offset:          mergedMapping.FileOffset,
 		File:            pu.getString(int(mergedMapping.Filename)),
 		BuildID:         pu.getString(int(mergedMapping.BuildId)),
@@ -302,7 +368,12 @@ func (pu *ProfileUnPacker) unpackMapping(p *pprofile.Profile, id uint64) *pprofi
 	return profileMapping
 }
 
-// ProfileMerger merges several profiles into a single one
+// ProfileMerger merges several profiles into a single one.
+//
+// MergedProfile returned by Merge is owned by the merger: it stays valid until the next call to
+// Merge, Reset or Release, which reuse it. Clone it with CloneVT to keep it longer. Samples of
+// merged profile share values with input profiles, so inputs must not be returned to the pool
+// while merged profile is in use.
 type ProfileMerger struct {
 	mergedProfile *MergedProfile
 	stringTable   map[string]int
@@ -310,23 +381,44 @@ type ProfileMerger struct {
 	functionTable map[functionKey]uint64
 	mappingTable  map[mappingKey]uint64
 	locationTable map[locationKey]uint64
+
+	dedup mergeDedup
+
+	normalizeAddresses bool
+	// starts of mappings normalized in the current entry
+	entryStarts map[uint64]uint64
+
+	aggregateSamples bool
+	// samples of the current entry by their key
+	entrySamples map[string]aggregatedSample
+
+	frontCodeStrings bool
+
+	dictionary *SymbolDictionary
+
+	deltaValues bool
 }
 
-func NewProfileMerger() *ProfileMerger {
-	return &ProfileMerger{
+func NewProfileMerger(opts ...MergerOption) *ProfileMerger {
+	pw := &ProfileMerger{
 		mergedProfile: MergedProfileFromVTPool(),
 		stringTable:   make(map[string]int),
 		functionTable: make(map[functionKey]uint64),
 		mappingTable:  make(map[mappingKey]uint64),
 		locationTable: make(map[locationKey]uint64),
 	}
+	for _, opt := range opts {
+		opt(pw)
+	}
+	pw.seedDictionary()
+	return pw
 }
 
 func (pw *ProfileMerger) WriteCompressed(w io.Writer) error {
 	// Write writes the pprofile as a gzip-compressed marshaled protobuf.
 	zw := gzip.NewWriter(w)
 	defer zw.Close()
-	serialized, err := pw.mergedProfile.MarshalVT()
+	serialized, err := pw.marshal()
 	if err != nil {
 		return err
 	}
@@ -336,7 +428,7 @@ func (pw *ProfileMerger) WriteCompressed(w io.Writer) error {
 }
 
 func (pw *ProfileMerger) WriteUncompressed(w io.Writer) error {
-	serialized, err := pw.mergedProfile.MarshalVT()
+	serialized, err := pw.marshal()
 	if err != nil {
 		return err
 	}
@@ -344,7 +436,55 @@ func (pw *ProfileMerger) WriteUncompressed(w io.Writer) error {
 	return err
 }
 
+// marshal marshals merged profile the way it's written
+func (pw *ProfileMerger) marshal() ([]byte, error) {
+	return pw.written().MarshalDeterministic()
+}
+
+// Reset clears interned tables and merged profile, so that merger can be reused for
+// an unrelated batch of profiles. It may also be called after Release.
+func (pw *ProfileMerger) Reset() {
+	if pw.mergedProfile == nil {
+		pw.mergedProfile = MergedProfileFromVTPool()
+	} else {
+		pw.mergedProfile.ResetVT()
+	}
+	clear(pw.stringTable)
+	clear(pw.functionTable)
+	clear(pw.mappingTable)
+	clear(pw.locationTable)
+	clear(pw.entryStarts)
+	clear(pw.entrySamples)
+	pw.dedup = mergeDedup{}
+	pw.seedDictionary()
+}
+
+// Release returns merged profile to the vtproto pool. Neither merger nor merged profile
+// must be used afterwards until Reset is called.
+func (pw *ProfileMerger) Release() {
+	pw.mergedProfile.ReturnToVTPool()
+	pw.mergedProfile = nil
+	clear(pw.stringTable)
+	clear(pw.functionTable)
+	clear(pw.mappingTable)
+	clear(pw.locationTable)
+	clear(pw.entryStarts)
+	clear(pw.entrySamples)
+	pw.dedup = mergeDedup{}
+}
+
+// Merge merges ps into merged profile. Tables interned by previous calls are kept,
+// call Reset to start from scratch.
 func (pw *ProfileMerger) Merge(ps ...*profile.Profile) *MergedProfile {
+	pw.mergeCounts(ps...)
+	pw.mergeSamples(ps...)
+	pw.mergeMetadata(ps...)
+	pw.buildStringTable()
+
+	return pw.mergedProfile
+}
+
+func (pw *ProfileMerger) mergeCounts(ps ...*profile.Profile) {
 	pw.mergedProfile.NumFunctions = make([]uint64, 0, len(ps))
 	pw.mergedProfile.NumLocations = make([]uint64, 0, len(ps))
 	pw.mergedProfile.NumSampleTypes = make([]uint64, 0, len(ps))
@@ -357,23 +497,25 @@ func (pw *ProfileMerger) Merge(ps ...*profile.Profile) *MergedProfile {
 		pw.mergedProfile.NumLocations = append(pw.mergedProfile.NumLocations, uint64(len(p.Location)))
 		pw.mergedProfile.NumSampleTypes = append(pw.mergedProfile.NumSampleTypes, uint64(len(p.SampleType)))
 		pw.mergedProfile.NumMappings = append(pw.mergedProfile.NumMappings, uint64(len(p.Mapping)))
-		pw.mergedProfile.NumSamples = append(pw.mergedProfile.NumSamples, uint64(len(p.Sample)))
 	}
+}
 
-	pw.mergeSamples(ps...)
+// mergeMetadata merges everything but samples, it must run after samples are merged
+// to keep string ids in the same order
+func (pw *ProfileMerger) mergeMetadata(ps ...*profile.Profile) {
 	pw.mergeSampleTypes(ps...)
 	pw.mergeTimeNanos(ps...)
 	pw.mergeDurationNanos(ps...)
 	pw.mergePeriods(ps...)
 	pw.mergePeriodTypes(ps...)
+}
 
+func (pw *ProfileMerger) buildStringTable() {
 	pw.mergedProfile.StringTable = make([]string, len(pw.stringTable)+1)
 	pw.mergedProfile.StringTable[0] = ""
 	for st, id := range pw.stringTable {
 		pw.mergedProfile.StringTable[id] = st
 	}
-
-	return pw.mergedProfile
 }
 
 func (pw *ProfileMerger) mergeSamples(ps ...*profile.Profile) {
@@ -385,16 +527,27 @@ func (pw *ProfileMerger) mergeSamples(ps ...*profile.Profile) {
 	pw.mergedProfile.Samples = make([]*MergeSample, 0, size)
 
 	for _, p := range ps {
+		numSamples := len(pw.mergedProfile.Samples)
 		for _, s := range p.Sample {
-			pw.mergedProfile.Samples = append(pw.mergedProfile.Samples, pw.asMergedSample(s, p))
+			sample := pw.asMergedSample(s, p)
+			var labels *profile.Labels
 			if len(s.Label) > 0 {
-				pw.mergeLabels(s.Label, p)
+				labels = pw.asMergedLabels(s.Label, p)
 			}
+			pw.putSample(sample, labels)
 		}
+		pw.mergedProfile.NumSamples = append(pw.mergedProfile.NumSamples, uint64(len(pw.mergedProfile.Samples)-numSamples))
+		pw.finishEntry()
 	}
 }
 
-func (pw *ProfileMerger) mergeLabels(labels []*profile.Label, p *profile.Profile) {
+// finishEntry records state of the entry merged last and prepares merger for the next one
+func (pw *ProfileMerger) finishEntry() {
+	pw.flushMappingStarts()
+	clear(pw.entrySamples)
+}
+
+func (pw *ProfileMerger) asMergedLabels(labels []*profile.Label, p *profile.Profile) *profile.Labels {
 	lbls := &profile.Labels{
 		Labels: make([]*profile.Label, 0, len(labels)),
 	}
@@ -407,13 +560,15 @@ func (pw *ProfileMerger) mergeLabels(labels []*profile.Label, p *profile.Profile
 			lbl.Str = int64(pw.putString(uint64(label.Str), p))
 		} else if label.Num != 0 || label.NumUnit != 0 {
 			lbl.Num = label.Num
-			lbl.NumUnit = int64(pw.putString(uint64(label.NumUnit), p))
+			// keep missing unit missing, so that keys without units aren't given empty ones on unpack
+			if label.NumUnit > 0 {
+				lbl.NumUnit = int64(pw.putString(uint64(label.NumUnit), p))
+			}
 		}
 		lbls.Labels = append(lbls.Labels, lbl)
 	}
 
-	idx := uint64(len(pw.mergedProfile.Samples)) - 1
-	pw.mergedProfile.Labels[idx] = lbls
+	return lbls
 }
 
 func (pw *ProfileMerger) mergePeriodTypes(ps ...*profile.Profile) {
@@ -486,8 +641,16 @@ func (pw *ProfileMerger) putMapping(src *profile.Mapping, p *profile.Profile) ui
 		HasLineNumbers:  src.HasInlineFrames,
 	}
 
+	if pw.normalizeAddresses {
+		if mappingID, ok := pw.putNormalizedMapping(mapping, p, src); ok {
+			return mappingID
+		}
+	}
+
 	key := pw.getMappingKey(mapping)
-	if mappingID, ok := pw.mappingTable[key]; ok {
+	mappingID, ok := pw.mappingTable[key]
+	pw.dedup.mappings.count(ok)
+	if ok {
 		return mappingID
 	}
 
@@ -534,7 +697,13 @@ func (pw *ProfileMerger) asMergedProfileLine(line *profile.Line, p *profile.Prof
 }
 
 func (pw *ProfileMerger) putString(id uint64, p *profile.Profile) int {
-	strVal := p.StringTable[id]
+	size := len(pw.stringTable)
+	strID := pw.internString(p.StringTable[id])
+	pw.dedup.strings.count(len(pw.stringTable) == size)
+	return strID
+}
+
+func (pw *ProfileMerger) internString(strVal string) int {
 	if localId, ok := pw.stringTable[strVal]; ok {
 		return localId
 	}
@@ -607,7 +776,8 @@ func (pw *ProfileMerger) putLocation(src *profile.Location, p *profile.Profile)
 
 	if src.MappingId != 0 {
 		loc.MappingId = pw.putMapping(p.Mapping[src.MappingId-1], p)
-		loc.Address = src.Address
+		// addresses of normalized mappings are relative to their start in the current entry
+		loc.Address = src.Address - pw.entryStarts[loc.MappingId]
 	}
 
 	loc.IsFolded = src.IsFolded
@@ -618,7 +788,9 @@ func (pw *ProfileMerger) putLocation(src *profile.Location, p *profile.Profile)
 	}
 
 	key := pw.getLocationKey(loc)
-	if locID, ok := pw.locationTable[key]; ok {
+	locID, ok := pw.locationTable[key]
+	pw.dedup.locations.count(ok)
+	if ok {
 		return locID
 	}
 
@@ -641,7 +813,9 @@ func (pw *ProfileMerger) putFunction(src *profile.Function, p *profile.Profile)
 	}
 
 	key := pw.getFunctionKey(f)
-	if functionID, ok := pw.functionTable[key]; ok {
+	functionID, ok := pw.functionTable[key]
+	pw.dedup.functions.count(ok)
+	if ok {
 		return functionID
 	}
 
@@ -650,3 +824,14 @@ func (pw *ProfileMerger) putFunction(src *profile.Function, p *profile.Profile)
 	pw.mergedProfile.Functions = append(pw.mergedProfile.Functions, f)
 	return f.Id
 }
+
+// EntriesBetween returns indexes of entries captured within [start, end] interval
+func (x *MergedProfile) EntriesBetween(start, end time.Time) []uint64 {
+	var idxs []uint64
+	for i, timeNanos := range x.TimesNanos {
+		if timeNanos >= start.UnixNano() && timeNanos <= end.UnixNano() {
+			idxs = append(idxs, uint64(i))
+		}
+	}
+	return idxs
+}
@@ -0,0 +1,201 @@
+package ppmerge
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+
+	"github.com/threadedstream/ppmerge/profile"
+)
+
+// MergeParallel merges ps the same way Merge does, but interns samples of every profile
+// concurrently on up to workers goroutines. Each profile is interned into its own local tables,
+// which are then reconciled with the global ones in order of ps, so that the result is identical
+// to the one of Merge. GOMAXPROCS goroutines are used if workers is not positive.
+func (pw *ProfileMerger) MergeParallel(workers int, ps ...*profile.Profile) *MergedProfile {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if workers > len(ps) {
+		workers = len(ps)
+	}
+
+	pw.mergeCounts(ps...)
+
+	size := 0
+	for _, p := range ps {
+		size += len(p.Sample)
+	}
+	pw.mergedProfile.Samples = make([]*MergeSample, 0, size)
+
+	locals := make([]*ProfileMerger, len(ps))
+	done := make([]chan struct{}, len(ps))
+	for i := range done {
+		done[i] = make(chan struct{})
+	}
+
+	var (
+		next int64 = -1
+		wg   sync.WaitGroup
+	)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				i := int(atomic.AddInt64(&next, 1))
+				if i >= len(ps) {
+					return
+				}
+				locals[i] = pw.newLocalMerger()
+				locals[i].mergeSamples(ps[i])
+				close(done[i])
+			}
+		}()
+	}
+
+	// reconcile in order while the rest of profiles are still being interned
+	for i := range ps {
+		<-done[i]
+		pw.reconcile(locals[i])
+		locals[i] = nil
+	}
+	wg.Wait()
+
+	pw.mergeMetadata(ps...)
+	pw.buildStringTable()
+
+	return pw.mergedProfile
+}
+
+// newLocalMerger returns merger holding tables of a single profile, options of pw apply to it
+func (pw *ProfileMerger) newLocalMerger() *ProfileMerger {
+	return &ProfileMerger{
+		mergedProfile: &MergedProfile{
+			Labels: make(map[uint64]*profile.Labels),
+		},
+		stringTable:        make(map[string]int),
+		functionTable:      make(map[functionKey]uint64),
+		mappingTable:       make(map[mappingKey]uint64),
+		locationTable:      make(map[locationKey]uint64),
+		normalizeAddresses: pw.normalizeAddresses,
+		aggregateSamples:   pw.aggregateSamples,
+	}
+}
+
+// reconcile moves samples interned by local merger into pw. Local ids are assigned in order of
+// the first occurrence, so interning them in ascending order reproduces ids of sequential merge.
+// Tables of local are reused and must not be used afterwards.
+func (pw *ProfileMerger) reconcile(local *ProfileMerger) {
+	numStrings := len(pw.stringTable)
+	numMappings := len(pw.mergedProfile.Mappings)
+	numFunctions := len(pw.mergedProfile.Functions)
+	numLocations := len(pw.mergedProfile.Locations)
+	strs := make([]string, len(local.stringTable)+1)
+	for s, id := range local.stringTable {
+		strs[id] = s
+	}
+	stringIDs := make([]int64, len(strs))
+	for id := 1; id < len(strs); id++ {
+		stringIDs[id] = int64(pw.internString(strs[id]))
+	}
+
+	lmp := local.mergedProfile
+
+	// local profile is a single entry, so its mapping starts tell which mappings are normalized
+	localStarts := make(map[uint64]uint64, len(lmp.MappingStarts)/2)
+	for i := 0; i+1 < len(lmp.MappingStarts); i += 2 {
+		localStarts[lmp.MappingStarts[i]] = lmp.MappingStarts[i+1]
+	}
+
+	mappingIDs := make([]uint64, len(lmp.Mappings)+1)
+	for i, m := range lmp.Mappings {
+		m.Filename = stringIDs[m.Filename]
+		m.BuildId = stringIDs[m.BuildId]
+
+		key := pw.getMappingKey(m)
+		_, key.normalized = localStarts[uint64(i+1)]
+		mappingID, ok := pw.mappingTable[key]
+		if !ok {
+			mappingID = uint64(len(pw.mergedProfile.Mappings) + 1)
+			m.Id = mappingID
+			pw.mappingTable[key] = mappingID
+			pw.mergedProfile.Mappings = append(pw.mergedProfile.Mappings, m)
+		}
+		mappingIDs[i+1] = mappingID
+	}
+
+	if pw.normalizeAddresses {
+		starts := make(map[uint64]uint64, len(localStarts))
+		for mappingID, start := range localStarts {
+			starts[mappingIDs[mappingID]] = start
+		}
+		pw.mergedProfile.NumMappingStarts = append(pw.mergedProfile.NumMappingStarts, uint64(len(starts)))
+		pw.mergedProfile.MappingStarts = appendMappingStarts(pw.mergedProfile.MappingStarts, starts)
+	}
+
+	functionIDs := make([]uint64, len(lmp.Functions)+1)
+	for i, f := range lmp.Functions {
+		f.Name = stringIDs[f.Name]
+		f.SystemName = stringIDs[f.SystemName]
+		f.Filename = stringIDs[f.Filename]
+
+		key := pw.getFunctionKey(f)
+		functionID, ok := pw.functionTable[key]
+		if !ok {
+			functionID = uint64(len(pw.mergedProfile.Functions) + 1)
+			f.Id = functionID
+			pw.functionTable[key] = functionID
+			pw.mergedProfile.Functions = append(pw.mergedProfile.Functions, f)
+		}
+		functionIDs[i+1] = functionID
+	}
+
+	locationIDs := make([]uint64, len(lmp.Locations)+1)
+	for i, loc := range lmp.Locations {
+		if loc.MappingId > 0 && loc.MappingId < uint64(len(mappingIDs)) {
+			loc.MappingId = mappingIDs[loc.MappingId]
+		}
+		for _, line := range loc.Line {
+			if line.FunctionId > 0 && line.FunctionId < uint64(len(functionIDs)) {
+				line.FunctionId = functionIDs[line.FunctionId]
+			}
+		}
+
+		key := pw.getLocationKey(loc)
+		locationID, ok := pw.locationTable[key]
+		if !ok {
+			locationID = uint64(len(pw.mergedProfile.Locations) + 1)
+			loc.Id = locationID
+			pw.locationTable[key] = locationID
+			pw.mergedProfile.Locations = append(pw.mergedProfile.Locations, loc)
+		}
+		locationIDs[i+1] = locationID
+	}
+
+	offset := uint64(len(pw.mergedProfile.Samples))
+	for _, s := range lmp.Samples {
+		for i, locID := range s.LocationId {
+			if locID > 0 && locID < int64(len(locationIDs)) {
+				s.LocationId[i] = int64(locationIDs[locID])
+			}
+		}
+		pw.mergedProfile.Samples = append(pw.mergedProfile.Samples, s)
+	}
+	pw.mergedProfile.NumSamples = append(pw.mergedProfile.NumSamples, lmp.NumSamples...)
+
+	for idx, labels := range lmp.Labels {
+		for _, label := range labels.Labels {
+			label.Key = stringIDs[label.Key]
+			label.Str = stringIDs[label.Str]
+			label.NumUnit = stringIDs[label.NumUnit]
+		}
+		pw.mergedProfile.Labels[offset+idx] = labels
+	}
+
+	// values new to local tables are hits if they were interned by previous profiles
+	pw.dedup.strings.add(local.dedup.strings, len(strs)-1-(len(pw.stringTable)-numStrings))
+	pw.dedup.mappings.add(local.dedup.mappings, len(lmp.Mappings)-(len(pw.mergedProfile.Mappings)-numMappings))
+	pw.dedup.functions.add(local.dedup.functions, len(lmp.Functions)-(len(pw.mergedProfile.Functions)-numFunctions))
+	pw.dedup.locations.add(local.dedup.locations, len(lmp.Locations)-(len(pw.mergedProfile.Locations)-numLocations))
+}
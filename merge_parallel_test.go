@@ -0,0 +1,56 @@
+package ppmerge
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestMergeParallel(t *testing.T) {
+	for _, paths := range [][]string{
+		{"hprof1"},
+		{"hprof1", "hprof2", "hprof3", "hprof4"},
+		{"parca_cpu", "hprof1", "parca_heap", "labels.prof", "hprof2"},
+	} {
+		expected := NewProfileMerger().Merge(getProfilesVtProto(t, false, paths...)...)
+
+		for _, workers := range []int{0, 1, 2, 8} {
+			actual := NewProfileMerger().MergeParallel(workers, getProfilesVtProto(t, false, paths...)...)
+			require.True(t, proto.Equal(expected, actual), "paths %v, workers %d", paths, workers)
+		}
+	}
+}
+
+func TestMergeParallelOptions(t *testing.T) {
+	paths := []string{"hprof1", "parca_cpu", "hprof2", "labels.prof", "hprof3", "hprof4"}
+	dict, err := NewSymbolDictionary(NewProfileMerger().Merge(getProfilesVtProto(t, false, "hprof1")...))
+	require.NoError(t, err)
+
+	for name, opts := range map[string][]MergerOption{
+		"normalized addresses": {WithNormalizedAddresses()},
+		"sample aggregation":   {WithSampleAggregation()},
+		"delta values":         {WithDeltaValues()},
+		"dictionary":           {WithDictionary(dict)},
+		"all":                  {WithNormalizedAddresses(), WithSampleAggregation(), WithDeltaValues(), WithDictionary(dict), WithFrontCodedStrings()},
+	} {
+		t.Run(name, func(t *testing.T) {
+			expectedMerger := NewProfileMerger(opts...)
+			expected := expectedMerger.Merge(getProfilesVtProto(t, false, paths...)...)
+
+			for _, workers := range []int{1, 2, 8} {
+				actualMerger := NewProfileMerger(opts...)
+				actual := actualMerger.MergeParallel(workers, getProfilesVtProto(t, false, paths...)...)
+				require.True(t, proto.Equal(expected, actual), "workers %d", workers)
+				// archives are written the same way
+				require.True(t, proto.Equal(expectedMerger.written(), actualMerger.written()), "workers %d", workers)
+			}
+		})
+	}
+}
+
+func TestMergeParallelEmpty(t *testing.T) {
+	mergedProfile := NewProfileMerger().MergeParallel(4)
+	require.Empty(t, mergedProfile.Samples)
+	require.Equal(t, []string{""}, mergedProfile.StringTable)
+}
@@ -10,6 +10,7 @@ import (
 	pprofile "github.com/google/pprof/profile"
 	"github.com/stretchr/testify/require"
 	"github.com/threadedstream/ppmerge/profile"
+	"google.golang.org/protobuf/proto"
 )
 
 func TestLabeledProfilesMerge(t *testing.T) {
@@ -37,6 +38,38 @@ func TestLabeledProfilesMerge(t *testing.T) {
 	}
 }
 
+func TestMultiValuedLabelsMerge(t *testing.T) {
+	paths := []string{"labels.prof", "multilabels.prof", "hprof1", "multilabels.prof"}
+	actualProfiles := getProfiles(t, paths...)
+
+	for name, mergedProfile := range map[string]*MergedProfile{
+		"sequential": NewProfileMerger().Merge(getProfilesVtProto(t, false, paths...)...),
+		"parallel":   NewProfileMerger().MergeParallel(2, getProfilesVtProto(t, false, paths...)...),
+	} {
+		t.Run(name, func(t *testing.T) {
+			unpacker := NewProfileUnPacker(mergedProfile)
+			for idx, actualProfile := range actualProfiles {
+				recoveredProfile, err := unpacker.Unpack(uint64(idx))
+				require.NoError(t, err)
+				require.Len(t, recoveredProfile.Sample, len(actualProfile.Sample))
+
+				for i, sample := range actualProfile.Sample {
+					require.Equal(t, sample.Label, recoveredProfile.Sample[i].Label)
+					require.Equal(t, sample.NumLabel, recoveredProfile.Sample[i].NumLabel)
+					require.Equal(t, sample.NumUnit, recoveredProfile.Sample[i].NumUnit)
+				}
+			}
+		})
+	}
+
+	recoveredProfile, err := NewProfileUnPacker(NewProfileMerger().Merge(getProfilesVtProto(t, false, "multilabels.prof")...)).Unpack(0)
+	require.NoError(t, err)
+	require.Equal(t, []string{"a", "b", "a"}, recoveredProfile.Sample[0].Label["tag"])
+	require.Equal(t, []string{"bytes", "bytes"}, recoveredProfile.Sample[0].NumUnit["bytes"])
+	require.NotContains(t, recoveredProfile.Sample[0].NumUnit, "count")
+	require.Equal(t, []string{"milliseconds", "", "seconds"}, recoveredProfile.Sample[1].NumUnit["latency"])
+}
+
 func TestHeapMerge(t *testing.T) {
 	profiles := getProfilesVtProto(t, false, "hprof1", "hprof2", "hprof3", "hprof4")
 	profileMerger := NewProfileMerger()
@@ -199,6 +232,15 @@ func TestMergeUnpack(t *testing.T) {
 		require.NotNil(t, p)
 	})
 
+	t.Run("unpack out of range entry", func(t *testing.T) {
+		profiles := getProfilesVtProto(t, false, "hprof1", "hprof2")
+		unpacker := NewProfileUnPacker(NewProfileMerger().Merge(profiles...))
+
+		_, err := unpacker.Unpack(2)
+		require.ErrorIs(t, err, indexOutOfRangeErr)
+		require.Empty(t, unpacker.getString(len(unpacker.mergedProfile.StringTable)))
+	})
+
 	t.Run("merge unpack debug goroutine profiles", func(t *testing.T) {
 		profiles := getGoroutineProfiles(t, "parca_goroutine_debug_1_1", "parca_goroutine_debug_1_2", "parca_goroutine_debug_1_3")
 
@@ -262,6 +304,124 @@ func TestMergeUnpack(t *testing.T) {
 		require.Equal(t, profiles[2].GetTotal(), p.GetTotal())
 		require.Equal(t, profiles[2].GetStacktraces(), p.GetStacktraces())
 	})
+
+	t.Run("merge unpack exact debug goroutine text", func(t *testing.T) {
+		paths := []string{"parca_goroutine_debug_1_1", "parca_goroutine_debug_1_2", "parca_goroutine_debug_1_3"}
+		profiles := getGoroutineProfiles(t, paths...)
+
+		profileMerger := NewGoroutineProfileMerger(WithFrontCodedGoroutineStrings(), WithDeltaGoroutineTotals())
+		profileMerger.Merge(profiles...)
+		bb := bytes.NewBuffer(nil)
+		require.NoError(t, profileMerger.WriteCompressed(bb))
+
+		// labels, comments and trimmed newlines are kept by archive
+		unpacker := NewGoroutineProfileUnPacker(nil)
+		for idx, path := range paths {
+			data, err := os.ReadFile("./testdata/" + path)
+			require.NoError(t, err)
+			p, err := unpacker.UnpackRaw(bb.Bytes(), uint64(idx))
+			require.NoError(t, err)
+			require.Equal(t, string(data), p.MarshalDebugExact())
+			require.Contains(t, p.MarshalDebugExact(), `# labels: {"parca_component":"scraper"}`)
+		}
+
+		commented := getGoroutineProfiles(t, paths...)
+		commented[1].Comments = []string{"# captured by ppmerge", ""}
+		commented[1].TrimmedNewlines = 0
+		profileMerger = NewGoroutineProfileMerger(WithFrontCodedGoroutineStrings())
+		profileMerger.Merge(commented...)
+		bb.Reset()
+		require.NoError(t, profileMerger.WriteCompressed(bb))
+		for idx, gp := range commented {
+			p, err := unpacker.UnpackRaw(bb.Bytes(), uint64(idx))
+			require.NoError(t, err)
+			require.Equal(t, gp.MarshalDebugExact(), p.MarshalDebugExact())
+		}
+	})
+}
+
+func TestMergerReset(t *testing.T) {
+	t.Run("profile merger", func(t *testing.T) {
+		expected := NewProfileMerger().Merge(getProfilesVtProto(t, false, "hprof3", "hprof4")...)
+
+		profileMerger := NewProfileMerger()
+		profileMerger.Merge(getProfilesVtProto(t, false, "parca_cpu", "labels.prof")...)
+		profileMerger.Reset()
+		require.True(t, proto.Equal(expected, profileMerger.Merge(getProfilesVtProto(t, false, "hprof3", "hprof4")...)))
+
+		profileMerger.Release()
+		profileMerger.Reset()
+		require.True(t, proto.Equal(expected, profileMerger.Merge(getProfilesVtProto(t, false, "hprof3", "hprof4")...)))
+	})
+
+	t.Run("goroutine profile merger", func(t *testing.T) {
+		expected := NewGoroutineProfileMerger().Merge(getGoroutineProfiles(t, "parca_goroutine_debug_1_2")...)
+
+		profileMerger := NewGoroutineProfileMerger()
+		profileMerger.Merge(getGoroutineProfiles(t, "parca_goroutine_debug_1_1", "parca_goroutine_debug_1_3")...)
+		profileMerger.Reset()
+		require.True(t, proto.Equal(expected, profileMerger.Merge(getGoroutineProfiles(t, "parca_goroutine_debug_1_2")...)))
+
+		profileMerger.Release()
+		profileMerger.Reset()
+		require.True(t, proto.Equal(expected, profileMerger.Merge(getGoroutineProfiles(t, "parca_goroutine_debug_1_2")...)))
+	})
+
+	t.Run("byte profile merger", func(t *testing.T) {
+		profiles := getDebugProfiles(t, "hprof1", "hprof2")
+
+		profileMerger := NewByteProfileMerger()
+		profileMerger.Merge(profiles...)
+		profileMerger.Release()
+		profileMerger.Reset()
+		require.Equal(t, profiles[1:], profileMerger.Merge(profiles[1:]...).Profiles)
+	})
+}
+
+func TestUnpackerReuse(t *testing.T) {
+	t.Run("profile unpacker", func(t *testing.T) {
+		profiles := getProfilesVtProto(t, false, "hprof1", "hprof2", "parca_cpu")
+		profileMerger := NewProfileMerger()
+		profileMerger.Merge(profiles...)
+
+		bb := bytes.NewBuffer(nil)
+		require.NoError(t, profileMerger.WriteCompressed(bb))
+
+		unpacker := NewProfileUnPacker(nil)
+		for _, idx := range []uint64{2, 0, 1, 0} {
+			expected, err := NewProfileUnPacker(profileMerger.mergedProfile).Unpack(idx)
+			require.NoError(t, err)
+
+			actual, err := unpacker.UnpackRaw(bb.Bytes(), idx)
+			require.NoError(t, err)
+			require.NoError(t, actual.CheckValid())
+			require.Equal(t, expected.String(), actual.String())
+		}
+
+		unpacker.Reset()
+		require.Empty(t, unpacker.mergedProfile.Samples)
+
+		unpacker.Release()
+		_, err := unpacker.UnpackRaw(bb.Bytes(), 1)
+		require.NoError(t, err)
+	})
+
+	t.Run("goroutine profile unpacker", func(t *testing.T) {
+		profiles := getGoroutineProfiles(t, "parca_goroutine_debug_1_1", "parca_goroutine_debug_1_2", "parca_goroutine_debug_1_3")
+		mergedProfile := NewGoroutineProfileMerger().Merge(profiles...)
+
+		unpacker := NewGoroutineProfileUnPacker(mergedProfile)
+		for _, idx := range []uint64{2, 0, 1} {
+			p, err := unpacker.Unpack(idx)
+			require.NoError(t, err)
+			require.Equal(t, profiles[idx].GetStacktraces(), p.GetStacktraces())
+			require.Equal(t, profiles[idx].GetStringTable(), p.GetStringTable())
+			p.ReturnToVTPool()
+		}
+
+		unpacker.Release()
+		require.Nil(t, unpacker.mergedProfile)
+	})
 }
 
 func BenchmarkVtProtobufParsing(b *testing.B) {
@@ -298,6 +458,34 @@ func BenchmarkProfileMerger(b *testing.B) {
 	}
 }
 
+func BenchmarkProfileMergerParallel(b *testing.B) {
+	profiles := getProfilesVtProto(b, false, "hprof1", "hprof2", "hprof3", "hprof4")
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		profileMerger := NewProfileMerger()
+		profileMerger.MergeParallel(0, profiles...)
+	}
+}
+
+func BenchmarkProfileMergerLargeBatch(b *testing.B) {
+	var profiles []*profile.Profile
+	for i := 0; i < 50; i++ {
+		profiles = append(profiles, getProfilesVtProto(b, false, "hprof1", "hprof2", "hprof3", "hprof4", "parca_cpu", "parca_heap")...)
+	}
+
+	b.Run("sequential", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			NewProfileMerger().Merge(profiles...)
+		}
+	})
+	b.Run("parallel", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			NewProfileMerger().MergeParallel(0, profiles...)
+		}
+	})
+}
+
 func BenchmarkProfileUnPacker(b *testing.B) {
 	profiles := getProfilesVtProto(b, false, "hprof1", "hprof2", "hprof3", "hprof4")
 
@@ -31,6 +31,21 @@ type MergedGoroutineProfile struct {
 	Stacktraces    []*profile.Stacktrace `protobuf:"bytes,2,rep,name=stacktraces,proto3" json:"stacktraces,omitempty"`
 	StringTable    []string              `protobuf:"bytes,3,rep,name=string_table,json=stringTable,proto3" json:"string_table,omitempty"`
 	NumStacktraces []uint64              `protobuf:"varint,4,rep,packed,name=num_stacktraces,json=numStacktraces,proto3" json:"num_stacktraces,omitempty"`
+	// Front coded strings, set instead of string_table by FrontCoded
+	FrontCodedStrings []byte `protobuf:"bytes,5,opt,name=front_coded_strings,json=frontCodedStrings,proto3" json:"front_coded_strings,omitempty"`
+	// Totals of entries and stacktraces are zigzag encoded differences with the previous entry,
+	// set by DeltaEncoded
+	DeltaTotals bool `protobuf:"varint,6,opt,name=delta_totals,json=deltaTotals,proto3" json:"delta_totals,omitempty"`
+	// Capture time, index of source in string table and labels of every entry, set only if
+	// metadata was given to merger
+	TimesNanos []int64           `protobuf:"varint,7,rep,packed,name=times_nanos,json=timesNanos,proto3" json:"times_nanos,omitempty"`
+	Sources    []uint64          `protobuf:"varint,8,rep,packed,name=sources,proto3" json:"sources,omitempty"`
+	Labels     []*profile.Labels `protobuf:"bytes,9,rep,name=labels,proto3" json:"labels,omitempty"`
+	// Number of leading comments of every entry and their indexes in string table, and number of
+	// newlines every entry lacks at its end, see GoroutineProfile
+	NumComments     []uint64 `protobuf:"varint,10,rep,packed,name=num_comments,json=numComments,proto3" json:"num_comments,omitempty"`
+	Comments        []uint64 `protobuf:"varint,11,rep,packed,name=comments,proto3" json:"comments,omitempty"`
+	TrimmedNewlines []uint64 `protobuf:"varint,12,rep,packed,name=trimmed_newlines,json=trimmedNewlines,proto3" json:"trimmed_newlines,omitempty"`
 }
 
 func (x *MergedGoroutineProfile) Reset() {
@@ -93,6 +108,62 @@ func (x *MergedGoroutineProfile) GetNumStacktraces() []uint64 {
 	return nil
 }
 
+func (x *MergedGoroutineProfile) GetFrontCodedStrings() []byte {
+	if x != nil {
+		return x.FrontCodedStrings
+	}
+	return nil
+}
+
+func (x *MergedGoroutineProfile) GetDeltaTotals() bool {
+	if x != nil {
+		return x.DeltaTotals
+	}
+	return false
+}
+
+func (x *MergedGoroutineProfile) GetTimesNanos() []int64 {
+	if x != nil {
+		return x.TimesNanos
+	}
+	return nil
+}
+
+func (x *MergedGoroutineProfile) GetSources() []uint64 {
+	if x != nil {
+		return x.Sources
+	}
+	return nil
+}
+
+func (x *MergedGoroutineProfile) GetLabels() []*profile.Labels {
+	if x != nil {
+		return x.Labels
+	}
+	return nil
+}
+
+func (x *MergedGoroutineProfile) GetNumComments() []uint64 {
+	if x != nil {
+		return x.NumComments
+	}
+	return nil
+}
+
+func (x *MergedGoroutineProfile) GetComments() []uint64 {
+	if x != nil {
+		return x.Comments
+	}
+	return nil
+}
+
+func (x *MergedGoroutineProfile) GetTrimmedNewlines() []uint64 {
+	if x != nil {
+		return x.TrimmedNewlines
+	}
+	return nil
+}
+
 // MergedByteProfile may represent merged profiles downloaded with debug option
 type MergedByteProfile struct {
 	state         protoimpl.MessageState
@@ -163,6 +234,20 @@ type MergedProfile struct {
 	NumMappings    []uint64                   `protobuf:"varint,14,rep,packed,name=num_mappings,json=numMappings,proto3" json:"num_mappings,omitempty"`
 	NumSamples     []uint64                   `protobuf:"varint,15,rep,packed,name=num_samples,json=numSamples,proto3" json:"num_samples,omitempty"`
 	Labels         map[uint64]*profile.Labels `protobuf:"bytes,16,rep,name=labels,proto3" json:"labels,omitempty" protobuf_key:"varint,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	// Pairs of normalized mapping id and its memory start of every entry. Normalized mappings
+	// start at zero and their locations hold addresses relative to the mapping start.
+	MappingStarts    []uint64 `protobuf:"varint,17,rep,packed,name=mapping_starts,json=mappingStarts,proto3" json:"mapping_starts,omitempty"`
+	NumMappingStarts []uint64 `protobuf:"varint,18,rep,packed,name=num_mapping_starts,json=numMappingStarts,proto3" json:"num_mapping_starts,omitempty"`
+	// Front coded strings, set instead of string_table by FrontCoded
+	FrontCodedStrings []byte `protobuf:"bytes,19,opt,name=front_coded_strings,json=frontCodedStrings,proto3" json:"front_coded_strings,omitempty"`
+	// Key of SymbolDictionary holding the first strings, functions and mappings of tables, and
+	// number of its strings. Ids of the rest of tables stored here continue the ones of dictionary.
+	// Key ends with hash of dictionary contents, which is checked when dictionary is attached.
+	Dictionary        string `protobuf:"bytes,20,opt,name=dictionary,proto3" json:"dictionary,omitempty"`
+	DictionaryStrings uint64 `protobuf:"varint,21,opt,name=dictionary_strings,json=dictionaryStrings,proto3" json:"dictionary_strings,omitempty"`
+	// Values of samples are zigzag encoded differences with the identical sample of the previous
+	// entry of the same sample types, set by DeltaEncoded
+	DeltaValues bool `protobuf:"varint,22,opt,name=delta_values,json=deltaValues,proto3" json:"delta_values,omitempty"`
 }
 
 func (x *MergedProfile) Reset() {
@@ -309,6 +394,197 @@ func (x *MergedProfile) GetLabels() map[uint64]*profile.Labels {
 	return nil
 }
 
+func (x *MergedProfile) GetMappingStarts() []uint64 {
+	if x != nil {
+		return x.MappingStarts
+	}
+	return nil
+}
+
+func (x *MergedProfile) GetNumMappingStarts() []uint64 {
+	if x != nil {
+		return x.NumMappingStarts
+	}
+	return nil
+}
+
+func (x *MergedProfile) GetFrontCodedStrings() []byte {
+	if x != nil {
+		return x.FrontCodedStrings
+	}
+	return nil
+}
+
+func (x *MergedProfile) GetDictionary() string {
+	if x != nil {
+		return x.Dictionary
+	}
+	return ""
+}
+
+func (x *MergedProfile) GetDictionaryStrings() uint64 {
+	if x != nil {
+		return x.DictionaryStrings
+	}
+	return 0
+}
+
+func (x *MergedProfile) GetDeltaValues() bool {
+	if x != nil {
+		return x.DeltaValues
+	}
+	return false
+}
+
+// SymbolDictionary holds strings, functions and mappings shared by archives of the same binary
+type SymbolDictionary struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Hash of dictionary contents, "sha256:<hex>", prefixed with build ID of the main binary and
+	// a slash if there is one
+	Key         string           `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	StringTable []string         `protobuf:"bytes,2,rep,name=string_table,json=stringTable,proto3" json:"string_table,omitempty"`
+	Functions   []*MergeFunction `protobuf:"bytes,3,rep,name=functions,proto3" json:"functions,omitempty"`
+	Mappings    []*MergeMapping  `protobuf:"bytes,4,rep,name=mappings,proto3" json:"mappings,omitempty"`
+}
+
+func (x *SymbolDictionary) Reset() {
+	*x = SymbolDictionary{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_api_merged_profile_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *SymbolDictionary) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SymbolDictionary) ProtoMessage() {}
+
+func (x *SymbolDictionary) ProtoReflect() protoreflect.Message {
+	mi := &file_api_merged_profile_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SymbolDictionary.ProtoReflect.Descriptor instead.
+func (*SymbolDictionary) Descriptor() ([]byte, []int) {
+	return file_api_merged_profile_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *SymbolDictionary) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *SymbolDictionary) GetStringTable() []string {
+	if x != nil {
+		return x.StringTable
+	}
+	return nil
+}
+
+func (x *SymbolDictionary) GetFunctions() []*MergeFunction {
+	if x != nil {
+		return x.Functions
+	}
+	return nil
+}
+
+func (x *SymbolDictionary) GetMappings() []*MergeMapping {
+	if x != nil {
+		return x.Mappings
+	}
+	return nil
+}
+
+// ShardedProfile holds profiles partitioned by sample type signature, every shard
+// has its own tables
+type ShardedProfile struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Shards []*MergedProfile `protobuf:"bytes,1,rep,name=shards,proto3" json:"shards,omitempty"`
+	// Signature of every shard, e.g. "samples/count,cpu/nanoseconds;cpu/nanoseconds"
+	Signatures []string `protobuf:"bytes,2,rep,name=signatures,proto3" json:"signatures,omitempty"`
+	// Shard and index within the shard of every input profile in order of merge
+	EntryShards  []uint64 `protobuf:"varint,3,rep,packed,name=entry_shards,json=entryShards,proto3" json:"entry_shards,omitempty"`
+	EntryIndexes []uint64 `protobuf:"varint,4,rep,packed,name=entry_indexes,json=entryIndexes,proto3" json:"entry_indexes,omitempty"`
+}
+
+func (x *ShardedProfile) Reset() {
+	*x = ShardedProfile{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_api_merged_profile_proto_msgTypes[4]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ShardedProfile) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ShardedProfile) ProtoMessage() {}
+
+func (x *ShardedProfile) ProtoReflect() protoreflect.Message {
+	mi := &file_api_merged_profile_proto_msgTypes[4]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ShardedProfile.ProtoReflect.Descriptor instead.
+func (*ShardedProfile) Descriptor() ([]byte, []int) {
+	return file_api_merged_profile_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ShardedProfile) GetShards() []*MergedProfile {
+	if x != nil {
+		return x.Shards
+	}
+	return nil
+}
+
+func (x *ShardedProfile) GetSignatures() []string {
+	if x != nil {
+		return x.Signatures
+	}
+	return nil
+}
+
+func (x *ShardedProfile) GetEntryShards() []uint64 {
+	if x != nil {
+		return x.EntryShards
+	}
+	return nil
+}
+
+func (x *ShardedProfile) GetEntryIndexes() []uint64 {
+	if x != nil {
+		return x.EntryIndexes
+	}
+	return nil
+}
+
 // ValueType describes the semantics and measurement units of a value.
 type MergeValueType struct {
 	state         protoimpl.MessageState
@@ -322,7 +598,7 @@ type MergeValueType struct {
 func (x *MergeValueType) Reset() {
 	*x = MergeValueType{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_api_merged_profile_proto_msgTypes[3]
+		mi := &file_api_merged_profile_proto_msgTypes[5]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -335,7 +611,7 @@ func (x *MergeValueType) String() string {
 func (*MergeValueType) ProtoMessage() {}
 
 func (x *MergeValueType) ProtoReflect() protoreflect.Message {
-	mi := &file_api_merged_profile_proto_msgTypes[3]
+	mi := &file_api_merged_profile_proto_msgTypes[5]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -348,7 +624,7 @@ func (x *MergeValueType) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use MergeValueType.ProtoReflect.Descriptor instead.
 func (*MergeValueType) Descriptor() ([]byte, []int) {
-	return file_api_merged_profile_proto_rawDescGZIP(), []int{3}
+	return file_api_merged_profile_proto_rawDescGZIP(), []int{5}
 }
 
 func (x *MergeValueType) GetType() int64 {
@@ -377,7 +653,7 @@ type MergeSample struct {
 func (x *MergeSample) Reset() {
 	*x = MergeSample{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_api_merged_profile_proto_msgTypes[4]
+		mi := &file_api_merged_profile_proto_msgTypes[6]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -390,7 +666,7 @@ func (x *MergeSample) String() string {
 func (*MergeSample) ProtoMessage() {}
 
 func (x *MergeSample) ProtoReflect() protoreflect.Message {
-	mi := &file_api_merged_profile_proto_msgTypes[4]
+	mi := &file_api_merged_profile_proto_msgTypes[6]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -403,7 +679,7 @@ func (x *MergeSample) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use MergeSample.ProtoReflect.Descriptor instead.
 func (*MergeSample) Descriptor() ([]byte, []int) {
-	return file_api_merged_profile_proto_rawDescGZIP(), []int{4}
+	return file_api_merged_profile_proto_rawDescGZIP(), []int{6}
 }
 
 func (x *MergeSample) GetLocationId() []int64 {
@@ -431,7 +707,7 @@ type LocationID struct {
 func (x *LocationID) Reset() {
 	*x = LocationID{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_api_merged_profile_proto_msgTypes[5]
+		mi := &file_api_merged_profile_proto_msgTypes[7]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -444,7 +720,7 @@ func (x *LocationID) String() string {
 func (*LocationID) ProtoMessage() {}
 
 func (x *LocationID) ProtoReflect() protoreflect.Message {
-	mi := &file_api_merged_profile_proto_msgTypes[5]
+	mi := &file_api_merged_profile_proto_msgTypes[7]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -457,7 +733,7 @@ func (x *LocationID) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use LocationID.ProtoReflect.Descriptor instead.
 func (*LocationID) Descriptor() ([]byte, []int) {
-	return file_api_merged_profile_proto_rawDescGZIP(), []int{5}
+	return file_api_merged_profile_proto_rawDescGZIP(), []int{7}
 }
 
 func (x *LocationID) GetId() []int64 {
@@ -478,7 +754,7 @@ type FunctionCompact struct {
 func (x *FunctionCompact) Reset() {
 	*x = FunctionCompact{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_api_merged_profile_proto_msgTypes[6]
+		mi := &file_api_merged_profile_proto_msgTypes[8]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -491,7 +767,7 @@ func (x *FunctionCompact) String() string {
 func (*FunctionCompact) ProtoMessage() {}
 
 func (x *FunctionCompact) ProtoReflect() protoreflect.Message {
-	mi := &file_api_merged_profile_proto_msgTypes[6]
+	mi := &file_api_merged_profile_proto_msgTypes[8]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -504,7 +780,7 @@ func (x *FunctionCompact) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use FunctionCompact.ProtoReflect.Descriptor instead.
 func (*FunctionCompact) Descriptor() ([]byte, []int) {
-	return file_api_merged_profile_proto_rawDescGZIP(), []int{6}
+	return file_api_merged_profile_proto_rawDescGZIP(), []int{8}
 }
 
 func (x *FunctionCompact) GetData() []int64 {
@@ -529,7 +805,7 @@ type FunctionOrFunctionRef struct {
 func (x *FunctionOrFunctionRef) Reset() {
 	*x = FunctionOrFunctionRef{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_api_merged_profile_proto_msgTypes[7]
+		mi := &file_api_merged_profile_proto_msgTypes[9]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -542,7 +818,7 @@ func (x *FunctionOrFunctionRef) String() string {
 func (*FunctionOrFunctionRef) ProtoMessage() {}
 
 func (x *FunctionOrFunctionRef) ProtoReflect() protoreflect.Message {
-	mi := &file_api_merged_profile_proto_msgTypes[7]
+	mi := &file_api_merged_profile_proto_msgTypes[9]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -555,7 +831,7 @@ func (x *FunctionOrFunctionRef) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use FunctionOrFunctionRef.ProtoReflect.Descriptor instead.
 func (*FunctionOrFunctionRef) Descriptor() ([]byte, []int) {
-	return file_api_merged_profile_proto_rawDescGZIP(), []int{7}
+	return file_api_merged_profile_proto_rawDescGZIP(), []int{9}
 }
 
 func (m *FunctionOrFunctionRef) GetFunctionOrRef() isFunctionOrFunctionRef_FunctionOrRef {
@@ -607,7 +883,7 @@ type FunctionRef struct {
 func (x *FunctionRef) Reset() {
 	*x = FunctionRef{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_api_merged_profile_proto_msgTypes[8]
+		mi := &file_api_merged_profile_proto_msgTypes[10]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -620,7 +896,7 @@ func (x *FunctionRef) String() string {
 func (*FunctionRef) ProtoMessage() {}
 
 func (x *FunctionRef) ProtoReflect() protoreflect.Message {
-	mi := &file_api_merged_profile_proto_msgTypes[8]
+	mi := &file_api_merged_profile_proto_msgTypes[10]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -633,7 +909,7 @@ func (x *FunctionRef) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use FunctionRef.ProtoReflect.Descriptor instead.
 func (*FunctionRef) Descriptor() ([]byte, []int) {
-	return file_api_merged_profile_proto_rawDescGZIP(), []int{8}
+	return file_api_merged_profile_proto_rawDescGZIP(), []int{10}
 }
 
 func (x *FunctionRef) GetId() uint64 {
@@ -671,7 +947,7 @@ type MergeFunction struct {
 func (x *MergeFunction) Reset() {
 	*x = MergeFunction{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_api_merged_profile_proto_msgTypes[9]
+		mi := &file_api_merged_profile_proto_msgTypes[11]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -684,7 +960,7 @@ func (x *MergeFunction) String() string {
 func (*MergeFunction) ProtoMessage() {}
 
 func (x *MergeFunction) ProtoReflect() protoreflect.Message {
-	mi := &file_api_merged_profile_proto_msgTypes[9]
+	mi := &file_api_merged_profile_proto_msgTypes[11]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -697,7 +973,7 @@ func (x *MergeFunction) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use MergeFunction.ProtoReflect.Descriptor instead.
 func (*MergeFunction) Descriptor() ([]byte, []int) {
-	return file_api_merged_profile_proto_rawDescGZIP(), []int{9}
+	return file_api_merged_profile_proto_rawDescGZIP(), []int{11}
 }
 
 func (x *MergeFunction) GetId() uint64 {
@@ -773,7 +1049,7 @@ type MergeLocation struct {
 func (x *MergeLocation) Reset() {
 	*x = MergeLocation{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_api_merged_profile_proto_msgTypes[10]
+		mi := &file_api_merged_profile_proto_msgTypes[12]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -786,7 +1062,7 @@ func (x *MergeLocation) String() string {
 func (*MergeLocation) ProtoMessage() {}
 
 func (x *MergeLocation) ProtoReflect() protoreflect.Message {
-	mi := &file_api_merged_profile_proto_msgTypes[10]
+	mi := &file_api_merged_profile_proto_msgTypes[12]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -799,7 +1075,7 @@ func (x *MergeLocation) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use MergeLocation.ProtoReflect.Descriptor instead.
 func (*MergeLocation) Descriptor() ([]byte, []int) {
-	return file_api_merged_profile_proto_rawDescGZIP(), []int{10}
+	return file_api_merged_profile_proto_rawDescGZIP(), []int{12}
 }
 
 func (x *MergeLocation) GetId() uint64 {
@@ -851,7 +1127,7 @@ type MergeLine struct {
 func (x *MergeLine) Reset() {
 	*x = MergeLine{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_api_merged_profile_proto_msgTypes[11]
+		mi := &file_api_merged_profile_proto_msgTypes[13]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -864,7 +1140,7 @@ func (x *MergeLine) String() string {
 func (*MergeLine) ProtoMessage() {}
 
 func (x *MergeLine) ProtoReflect() protoreflect.Message {
-	mi := &file_api_merged_profile_proto_msgTypes[11]
+	mi := &file_api_merged_profile_proto_msgTypes[13]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -877,7 +1153,7 @@ func (x *MergeLine) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use MergeLine.ProtoReflect.Descriptor instead.
 func (*MergeLine) Descriptor() ([]byte, []int) {
-	return file_api_merged_profile_proto_rawDescGZIP(), []int{11}
+	return file_api_merged_profile_proto_rawDescGZIP(), []int{13}
 }
 
 func (x *MergeLine) GetFunctionId() uint64 {
@@ -925,7 +1201,7 @@ type MergeMapping struct {
 func (x *MergeMapping) Reset() {
 	*x = MergeMapping{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_api_merged_profile_proto_msgTypes[12]
+		mi := &file_api_merged_profile_proto_msgTypes[14]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -938,7 +1214,7 @@ func (x *MergeMapping) String() string {
 func (*MergeMapping) ProtoMessage() {}
 
 func (x *MergeMapping) ProtoReflect() protoreflect.Message {
-	mi := &file_api_merged_profile_proto_msgTypes[12]
+	mi := &file_api_merged_profile_proto_msgTypes[14]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -951,7 +1227,7 @@ func (x *MergeMapping) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use MergeMapping.ProtoReflect.Descriptor instead.
 func (*MergeMapping) Descriptor() ([]byte, []int) {
-	return file_api_merged_profile_proto_rawDescGZIP(), []int{12}
+	return file_api_merged_profile_proto_rawDescGZIP(), []int{14}
 }
 
 func (x *MergeMapping) GetId() uint64 {
@@ -1030,7 +1306,7 @@ var file_api_merged_profile_proto_rawDesc = []byte{
 	0x0a, 0x18, 0x61, 0x70, 0x69, 0x2f, 0x6d, 0x65, 0x72, 0x67, 0x65, 0x64, 0x5f, 0x70, 0x72, 0x6f,
 	0x66, 0x69, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x07, 0x70, 0x70, 0x6d, 0x65,
 	0x72, 0x67, 0x65, 0x1a, 0x11, 0x61, 0x70, 0x69, 0x2f, 0x70, 0x72, 0x6f, 0x66, 0x69, 0x6c, 0x65,
-	0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0xb3, 0x01, 0x0a, 0x16, 0x4d, 0x65, 0x72, 0x67, 0x65,
+	0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0xd4, 0x03, 0x0a, 0x16, 0x4d, 0x65, 0x72, 0x67, 0x65,
 	0x64, 0x47, 0x6f, 0x72, 0x6f, 0x75, 0x74, 0x69, 0x6e, 0x65, 0x50, 0x72, 0x6f, 0x66, 0x69, 0x6c,
 	0x65, 0x12, 0x16, 0x0a, 0x06, 0x74, 0x6f, 0x74, 0x61, 0x6c, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28,
 	0x04, 0x52, 0x06, 0x74, 0x6f, 0x74, 0x61, 0x6c, 0x73, 0x12, 0x35, 0x0a, 0x0b, 0x73, 0x74, 0x61,
@@ -1041,130 +1317,186 @@ var file_api_merged_profile_proto_rawDesc = []byte{
 	0x18, 0x03, 0x20, 0x03, 0x28, 0x09, 0x52, 0x0b, 0x73, 0x74, 0x72, 0x69, 0x6e, 0x67, 0x54, 0x61,
 	0x62, 0x6c, 0x65, 0x12, 0x27, 0x0a, 0x0f, 0x6e, 0x75, 0x6d, 0x5f, 0x73, 0x74, 0x61, 0x63, 0x6b,
 	0x74, 0x72, 0x61, 0x63, 0x65, 0x73, 0x18, 0x04, 0x20, 0x03, 0x28, 0x04, 0x52, 0x0e, 0x6e, 0x75,
-	0x6d, 0x53, 0x74, 0x61, 0x63, 0x6b, 0x74, 0x72, 0x61, 0x63, 0x65, 0x73, 0x22, 0x2f, 0x0a, 0x11,
-	0x4d, 0x65, 0x72, 0x67, 0x65, 0x64, 0x42, 0x79, 0x74, 0x65, 0x50, 0x72, 0x6f, 0x66, 0x69, 0x6c,
-	0x65, 0x12, 0x1a, 0x0a, 0x08, 0x70, 0x72, 0x6f, 0x66, 0x69, 0x6c, 0x65, 0x73, 0x18, 0x01, 0x20,
-	0x03, 0x28, 0x0c, 0x52, 0x08, 0x70, 0x72, 0x6f, 0x66, 0x69, 0x6c, 0x65, 0x73, 0x22, 0xe9, 0x05,
-	0x0a, 0x0d, 0x4d, 0x65, 0x72, 0x67, 0x65, 0x64, 0x50, 0x72, 0x6f, 0x66, 0x69, 0x6c, 0x65, 0x12,
-	0x1f, 0x0a, 0x0b, 0x73, 0x61, 0x6d, 0x70, 0x6c, 0x65, 0x5f, 0x74, 0x79, 0x70, 0x65, 0x18, 0x01,
-	0x20, 0x03, 0x28, 0x03, 0x52, 0x0a, 0x73, 0x61, 0x6d, 0x70, 0x6c, 0x65, 0x54, 0x79, 0x70, 0x65,
-	0x12, 0x2e, 0x0a, 0x07, 0x73, 0x61, 0x6d, 0x70, 0x6c, 0x65, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28,
-	0x0b, 0x32, 0x14, 0x2e, 0x70, 0x70, 0x6d, 0x65, 0x72, 0x67, 0x65, 0x2e, 0x4d, 0x65, 0x72, 0x67,
-	0x65, 0x53, 0x61, 0x6d, 0x70, 0x6c, 0x65, 0x52, 0x07, 0x73, 0x61, 0x6d, 0x70, 0x6c, 0x65, 0x73,
-	0x12, 0x34, 0x0a, 0x09, 0x66, 0x75, 0x6e, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x18, 0x03, 0x20,
-	0x03, 0x28, 0x0b, 0x32, 0x16, 0x2e, 0x70, 0x70, 0x6d, 0x65, 0x72, 0x67, 0x65, 0x2e, 0x4d, 0x65,
-	0x72, 0x67, 0x65, 0x46, 0x75, 0x6e, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x09, 0x66, 0x75, 0x6e,
-	0x63, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x12, 0x34, 0x0a, 0x09, 0x6c, 0x6f, 0x63, 0x61, 0x74, 0x69,
-	0x6f, 0x6e, 0x73, 0x18, 0x04, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x16, 0x2e, 0x70, 0x70, 0x6d, 0x65,
-	0x72, 0x67, 0x65, 0x2e, 0x4d, 0x65, 0x72, 0x67, 0x65, 0x4c, 0x6f, 0x63, 0x61, 0x74, 0x69, 0x6f,
-	0x6e, 0x52, 0x09, 0x6c, 0x6f, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x12, 0x31, 0x0a, 0x08,
-	0x6d, 0x61, 0x70, 0x70, 0x69, 0x6e, 0x67, 0x73, 0x18, 0x05, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x15,
-	0x2e, 0x70, 0x70, 0x6d, 0x65, 0x72, 0x67, 0x65, 0x2e, 0x4d, 0x65, 0x72, 0x67, 0x65, 0x4d, 0x61,
-	0x70, 0x70, 0x69, 0x6e, 0x67, 0x52, 0x08, 0x6d, 0x61, 0x70, 0x70, 0x69, 0x6e, 0x67, 0x73, 0x12,
-	0x21, 0x0a, 0x0c, 0x70, 0x65, 0x72, 0x69, 0x6f, 0x64, 0x5f, 0x74, 0x79, 0x70, 0x65, 0x73, 0x18,
-	0x06, 0x20, 0x03, 0x28, 0x03, 0x52, 0x0b, 0x70, 0x65, 0x72, 0x69, 0x6f, 0x64, 0x54, 0x79, 0x70,
-	0x65, 0x73, 0x12, 0x18, 0x0a, 0x07, 0x70, 0x65, 0x72, 0x69, 0x6f, 0x64, 0x73, 0x18, 0x07, 0x20,
-	0x03, 0x28, 0x03, 0x52, 0x07, 0x70, 0x65, 0x72, 0x69, 0x6f, 0x64, 0x73, 0x12, 0x1f, 0x0a, 0x0b,
-	0x74, 0x69, 0x6d, 0x65, 0x73, 0x5f, 0x6e, 0x61, 0x6e, 0x6f, 0x73, 0x18, 0x08, 0x20, 0x03, 0x28,
-	0x03, 0x52, 0x0a, 0x74, 0x69, 0x6d, 0x65, 0x73, 0x4e, 0x61, 0x6e, 0x6f, 0x73, 0x12, 0x27, 0x0a,
-	0x0f, 0x64, 0x75, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x5f, 0x6e, 0x61, 0x6e, 0x6f, 0x73,
-	0x18, 0x09, 0x20, 0x03, 0x28, 0x03, 0x52, 0x0e, 0x64, 0x75, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e,
-	0x73, 0x4e, 0x61, 0x6e, 0x6f, 0x73, 0x12, 0x21, 0x0a, 0x0c, 0x73, 0x74, 0x72, 0x69, 0x6e, 0x67,
-	0x5f, 0x74, 0x61, 0x62, 0x6c, 0x65, 0x18, 0x0a, 0x20, 0x03, 0x28, 0x09, 0x52, 0x0b, 0x73, 0x74,
-	0x72, 0x69, 0x6e, 0x67, 0x54, 0x61, 0x62, 0x6c, 0x65, 0x12, 0x23, 0x0a, 0x0d, 0x6e, 0x75, 0x6d,
-	0x5f, 0x66, 0x75, 0x6e, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x18, 0x0b, 0x20, 0x03, 0x28, 0x04,
-	0x52, 0x0c, 0x6e, 0x75, 0x6d, 0x46, 0x75, 0x6e, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x12, 0x23,
-	0x0a, 0x0d, 0x6e, 0x75, 0x6d, 0x5f, 0x6c, 0x6f, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x18,
-	0x0c, 0x20, 0x03, 0x28, 0x04, 0x52, 0x0c, 0x6e, 0x75, 0x6d, 0x4c, 0x6f, 0x63, 0x61, 0x74, 0x69,
-	0x6f, 0x6e, 0x73, 0x12, 0x28, 0x0a, 0x10, 0x6e, 0x75, 0x6d, 0x5f, 0x73, 0x61, 0x6d, 0x70, 0x6c,
-	0x65, 0x5f, 0x74, 0x79, 0x70, 0x65, 0x73, 0x18, 0x0d, 0x20, 0x03, 0x28, 0x04, 0x52, 0x0e, 0x6e,
-	0x75, 0x6d, 0x53, 0x61, 0x6d, 0x70, 0x6c, 0x65, 0x54, 0x79, 0x70, 0x65, 0x73, 0x12, 0x21, 0x0a,
-	0x0c, 0x6e, 0x75, 0x6d, 0x5f, 0x6d, 0x61, 0x70, 0x70, 0x69, 0x6e, 0x67, 0x73, 0x18, 0x0e, 0x20,
-	0x03, 0x28, 0x04, 0x52, 0x0b, 0x6e, 0x75, 0x6d, 0x4d, 0x61, 0x70, 0x70, 0x69, 0x6e, 0x67, 0x73,
-	0x12, 0x1f, 0x0a, 0x0b, 0x6e, 0x75, 0x6d, 0x5f, 0x73, 0x61, 0x6d, 0x70, 0x6c, 0x65, 0x73, 0x18,
-	0x0f, 0x20, 0x03, 0x28, 0x04, 0x52, 0x0a, 0x6e, 0x75, 0x6d, 0x53, 0x61, 0x6d, 0x70, 0x6c, 0x65,
-	0x73, 0x12, 0x3a, 0x0a, 0x06, 0x6c, 0x61, 0x62, 0x65, 0x6c, 0x73, 0x18, 0x10, 0x20, 0x03, 0x28,
-	0x0b, 0x32, 0x22, 0x2e, 0x70, 0x70, 0x6d, 0x65, 0x72, 0x67, 0x65, 0x2e, 0x4d, 0x65, 0x72, 0x67,
-	0x65, 0x64, 0x50, 0x72, 0x6f, 0x66, 0x69, 0x6c, 0x65, 0x2e, 0x4c, 0x61, 0x62, 0x65, 0x6c, 0x73,
-	0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x06, 0x6c, 0x61, 0x62, 0x65, 0x6c, 0x73, 0x1a, 0x4a, 0x0a,
-	0x0b, 0x4c, 0x61, 0x62, 0x65, 0x6c, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03,
-	0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x25,
-	0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0f, 0x2e,
-	0x70, 0x70, 0x6d, 0x65, 0x72, 0x67, 0x65, 0x2e, 0x4c, 0x61, 0x62, 0x65, 0x6c, 0x73, 0x52, 0x05,
-	0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x22, 0x38, 0x0a, 0x0e, 0x4d, 0x65, 0x72,
-	0x67, 0x65, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x54, 0x79, 0x70, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x74,
-	0x79, 0x70, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x03, 0x52, 0x04, 0x74, 0x79, 0x70, 0x65, 0x12,
-	0x12, 0x0a, 0x04, 0x75, 0x6e, 0x69, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x52, 0x04, 0x75,
-	0x6e, 0x69, 0x74, 0x22, 0x44, 0x0a, 0x0b, 0x4d, 0x65, 0x72, 0x67, 0x65, 0x53, 0x61, 0x6d, 0x70,
-	0x6c, 0x65, 0x12, 0x1f, 0x0a, 0x0b, 0x6c, 0x6f, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x5f, 0x69,
-	0x64, 0x18, 0x01, 0x20, 0x03, 0x28, 0x03, 0x52, 0x0a, 0x6c, 0x6f, 0x63, 0x61, 0x74, 0x69, 0x6f,
-	0x6e, 0x49, 0x64, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x03,
-	0x28, 0x03, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x22, 0x1c, 0x0a, 0x0a, 0x4c, 0x6f, 0x63,
-	0x61, 0x74, 0x69, 0x6f, 0x6e, 0x49, 0x44, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20,
-	0x03, 0x28, 0x03, 0x52, 0x02, 0x69, 0x64, 0x22, 0x25, 0x0a, 0x0f, 0x46, 0x75, 0x6e, 0x63, 0x74,
-	0x69, 0x6f, 0x6e, 0x43, 0x6f, 0x6d, 0x70, 0x61, 0x63, 0x74, 0x12, 0x12, 0x0a, 0x04, 0x64, 0x61,
-	0x74, 0x61, 0x18, 0x01, 0x20, 0x03, 0x28, 0x03, 0x52, 0x04, 0x64, 0x61, 0x74, 0x61, 0x22, 0x88,
-	0x01, 0x0a, 0x15, 0x46, 0x75, 0x6e, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x4f, 0x72, 0x46, 0x75, 0x6e,
-	0x63, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x65, 0x66, 0x12, 0x34, 0x0a, 0x08, 0x66, 0x75, 0x6e, 0x63,
-	0x74, 0x69, 0x6f, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x16, 0x2e, 0x70, 0x70, 0x6d,
-	0x65, 0x72, 0x67, 0x65, 0x2e, 0x4d, 0x65, 0x72, 0x67, 0x65, 0x46, 0x75, 0x6e, 0x63, 0x74, 0x69,
-	0x6f, 0x6e, 0x48, 0x00, 0x52, 0x08, 0x66, 0x75, 0x6e, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x28,
-	0x0a, 0x03, 0x72, 0x65, 0x66, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x14, 0x2e, 0x70, 0x70,
-	0x6d, 0x65, 0x72, 0x67, 0x65, 0x2e, 0x46, 0x75, 0x6e, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x65,
-	0x66, 0x48, 0x00, 0x52, 0x03, 0x72, 0x65, 0x66, 0x42, 0x0f, 0x0a, 0x0d, 0x66, 0x75, 0x6e, 0x63,
-	0x74, 0x69, 0x6f, 0x6e, 0x4f, 0x72, 0x52, 0x65, 0x66, 0x22, 0x45, 0x0a, 0x0b, 0x46, 0x75, 0x6e,
-	0x63, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x65, 0x66, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01,
-	0x20, 0x01, 0x28, 0x04, 0x52, 0x02, 0x69, 0x64, 0x12, 0x26, 0x0a, 0x0f, 0x66, 0x75, 0x6e, 0x63,
-	0x74, 0x69, 0x6f, 0x6e, 0x5f, 0x72, 0x65, 0x66, 0x5f, 0x69, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28,
-	0x04, 0x52, 0x0d, 0x66, 0x75, 0x6e, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x65, 0x66, 0x49, 0x64,
-	0x22, 0x8f, 0x01, 0x0a, 0x0d, 0x4d, 0x65, 0x72, 0x67, 0x65, 0x46, 0x75, 0x6e, 0x63, 0x74, 0x69,
-	0x6f, 0x6e, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x02,
-	0x69, 0x64, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x03,
-	0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x1f, 0x0a, 0x0b, 0x73, 0x79, 0x73, 0x74, 0x65, 0x6d,
-	0x5f, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0a, 0x73, 0x79, 0x73,
-	0x74, 0x65, 0x6d, 0x4e, 0x61, 0x6d, 0x65, 0x12, 0x1a, 0x0a, 0x08, 0x66, 0x69, 0x6c, 0x65, 0x6e,
-	0x61, 0x6d, 0x65, 0x18, 0x05, 0x20, 0x01, 0x28, 0x03, 0x52, 0x08, 0x66, 0x69, 0x6c, 0x65, 0x6e,
-	0x61, 0x6d, 0x65, 0x12, 0x1d, 0x0a, 0x0a, 0x73, 0x74, 0x61, 0x72, 0x74, 0x5f, 0x6c, 0x69, 0x6e,
-	0x65, 0x18, 0x06, 0x20, 0x01, 0x28, 0x03, 0x52, 0x09, 0x73, 0x74, 0x61, 0x72, 0x74, 0x4c, 0x69,
-	0x6e, 0x65, 0x22, 0x9d, 0x01, 0x0a, 0x0d, 0x4d, 0x65, 0x72, 0x67, 0x65, 0x4c, 0x6f, 0x63, 0x61,
-	0x74, 0x69, 0x6f, 0x6e, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04,
-	0x52, 0x02, 0x69, 0x64, 0x12, 0x1d, 0x0a, 0x0a, 0x6d, 0x61, 0x70, 0x70, 0x69, 0x6e, 0x67, 0x5f,
-	0x69, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x04, 0x52, 0x09, 0x6d, 0x61, 0x70, 0x70, 0x69, 0x6e,
-	0x67, 0x49, 0x64, 0x12, 0x18, 0x0a, 0x07, 0x61, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x18, 0x03,
-	0x20, 0x01, 0x28, 0x04, 0x52, 0x07, 0x61, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x12, 0x26, 0x0a,
-	0x04, 0x6c, 0x69, 0x6e, 0x65, 0x18, 0x04, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x12, 0x2e, 0x70, 0x70,
-	0x6d, 0x65, 0x72, 0x67, 0x65, 0x2e, 0x4d, 0x65, 0x72, 0x67, 0x65, 0x4c, 0x69, 0x6e, 0x65, 0x52,
-	0x04, 0x6c, 0x69, 0x6e, 0x65, 0x12, 0x1b, 0x0a, 0x09, 0x69, 0x73, 0x5f, 0x66, 0x6f, 0x6c, 0x64,
-	0x65, 0x64, 0x18, 0x05, 0x20, 0x01, 0x28, 0x08, 0x52, 0x08, 0x69, 0x73, 0x46, 0x6f, 0x6c, 0x64,
-	0x65, 0x64, 0x22, 0x40, 0x0a, 0x09, 0x4d, 0x65, 0x72, 0x67, 0x65, 0x4c, 0x69, 0x6e, 0x65, 0x12,
-	0x1f, 0x0a, 0x0b, 0x66, 0x75, 0x6e, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x5f, 0x69, 0x64, 0x18, 0x01,
-	0x20, 0x01, 0x28, 0x04, 0x52, 0x0a, 0x66, 0x75, 0x6e, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x49, 0x64,
-	0x12, 0x12, 0x0a, 0x04, 0x6c, 0x69, 0x6e, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x52, 0x04,
-	0x6c, 0x69, 0x6e, 0x65, 0x22, 0xdc, 0x02, 0x0a, 0x0c, 0x4d, 0x65, 0x72, 0x67, 0x65, 0x4d, 0x61,
-	0x70, 0x70, 0x69, 0x6e, 0x67, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28,
-	0x04, 0x52, 0x02, 0x69, 0x64, 0x12, 0x21, 0x0a, 0x0c, 0x6d, 0x65, 0x6d, 0x6f, 0x72, 0x79, 0x5f,
-	0x73, 0x74, 0x61, 0x72, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x04, 0x52, 0x0b, 0x6d, 0x65, 0x6d,
-	0x6f, 0x72, 0x79, 0x53, 0x74, 0x61, 0x72, 0x74, 0x12, 0x21, 0x0a, 0x0c, 0x6d, 0x65, 0x6d, 0x6f,
-	0x72, 0x79, 0x5f, 0x6c, 0x69, 0x6d, 0x69, 0x74, 0x18, 0x03, 0x20, 0x01, 0x28, 0x04, 0x52, 0x0b,
-	0x6d, 0x65, 0x6d, 0x6f, 0x72, 0x79, 0x4c, 0x69, 0x6d, 0x69, 0x74, 0x12, 0x1f, 0x0a, 0x0b, 0x66,
-	0x69, 0x6c, 0x65, 0x5f, 0x6f, 0x66, 0x66, 0x73, 0x65, 0x74, 0x18, 0x04, 0x20, 0x01, 0x28, 0x04,
-	0x52, 0x0a, 0x66, 0x69, 0x6c, 0x65, 0x4f, 0x66, 0x66, 0x73, 0x65, 0x74, 0x12, 0x1a, 0x0a, 0x08,
-	0x66, 0x69, 0x6c, 0x65, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x05, 0x20, 0x01, 0x28, 0x03, 0x52, 0x08,
-	0x66, 0x69, 0x6c, 0x65, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x19, 0x0a, 0x08, 0x62, 0x75, 0x69, 0x6c,
-	0x64, 0x5f, 0x69, 0x64, 0x18, 0x06, 0x20, 0x01, 0x28, 0x03, 0x52, 0x07, 0x62, 0x75, 0x69, 0x6c,
-	0x64, 0x49, 0x64, 0x12, 0x23, 0x0a, 0x0d, 0x68, 0x61, 0x73, 0x5f, 0x66, 0x75, 0x6e, 0x63, 0x74,
-	0x69, 0x6f, 0x6e, 0x73, 0x18, 0x07, 0x20, 0x01, 0x28, 0x08, 0x52, 0x0c, 0x68, 0x61, 0x73, 0x46,
-	0x75, 0x6e, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x12, 0x23, 0x0a, 0x0d, 0x68, 0x61, 0x73, 0x5f,
-	0x66, 0x69, 0x6c, 0x65, 0x6e, 0x61, 0x6d, 0x65, 0x73, 0x18, 0x08, 0x20, 0x01, 0x28, 0x08, 0x52,
-	0x0c, 0x68, 0x61, 0x73, 0x46, 0x69, 0x6c, 0x65, 0x6e, 0x61, 0x6d, 0x65, 0x73, 0x12, 0x28, 0x0a,
-	0x10, 0x68, 0x61, 0x73, 0x5f, 0x6c, 0x69, 0x6e, 0x65, 0x5f, 0x6e, 0x75, 0x6d, 0x62, 0x65, 0x72,
-	0x73, 0x18, 0x09, 0x20, 0x01, 0x28, 0x08, 0x52, 0x0e, 0x68, 0x61, 0x73, 0x4c, 0x69, 0x6e, 0x65,
-	0x4e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x73, 0x12, 0x2a, 0x0a, 0x11, 0x68, 0x61, 0x73, 0x5f, 0x69,
-	0x6e, 0x6c, 0x69, 0x6e, 0x65, 0x5f, 0x66, 0x72, 0x61, 0x6d, 0x65, 0x73, 0x18, 0x0a, 0x20, 0x01,
-	0x28, 0x08, 0x52, 0x0f, 0x68, 0x61, 0x73, 0x49, 0x6e, 0x6c, 0x69, 0x6e, 0x65, 0x46, 0x72, 0x61,
-	0x6d, 0x65, 0x73, 0x42, 0x23, 0x5a, 0x21, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f,
-	0x6d, 0x2f, 0x74, 0x68, 0x72, 0x65, 0x61, 0x64, 0x65, 0x64, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d,
-	0x2f, 0x70, 0x70, 0x6d, 0x65, 0x72, 0x67, 0x65, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+	0x6d, 0x53, 0x74, 0x61, 0x63, 0x6b, 0x74, 0x72, 0x61, 0x63, 0x65, 0x73, 0x12, 0x2e, 0x0a, 0x13,
+	0x66, 0x72, 0x6f, 0x6e, 0x74, 0x5f, 0x63, 0x6f, 0x64, 0x65, 0x64, 0x5f, 0x73, 0x74, 0x72, 0x69,
+	0x6e, 0x67, 0x73, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x11, 0x66, 0x72, 0x6f, 0x6e, 0x74,
+	0x43, 0x6f, 0x64, 0x65, 0x64, 0x53, 0x74, 0x72, 0x69, 0x6e, 0x67, 0x73, 0x12, 0x21, 0x0a, 0x0c,
+	0x64, 0x65, 0x6c, 0x74, 0x61, 0x5f, 0x74, 0x6f, 0x74, 0x61, 0x6c, 0x73, 0x18, 0x06, 0x20, 0x01,
+	0x28, 0x08, 0x52, 0x0b, 0x64, 0x65, 0x6c, 0x74, 0x61, 0x54, 0x6f, 0x74, 0x61, 0x6c, 0x73, 0x12,
+	0x1f, 0x0a, 0x0b, 0x74, 0x69, 0x6d, 0x65, 0x73, 0x5f, 0x6e, 0x61, 0x6e, 0x6f, 0x73, 0x18, 0x07,
+	0x20, 0x03, 0x28, 0x03, 0x52, 0x0a, 0x74, 0x69, 0x6d, 0x65, 0x73, 0x4e, 0x61, 0x6e, 0x6f, 0x73,
+	0x12, 0x18, 0x0a, 0x07, 0x73, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x73, 0x18, 0x08, 0x20, 0x03, 0x28,
+	0x04, 0x52, 0x07, 0x73, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x73, 0x12, 0x27, 0x0a, 0x06, 0x6c, 0x61,
+	0x62, 0x65, 0x6c, 0x73, 0x18, 0x09, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x0f, 0x2e, 0x70, 0x70, 0x6d,
+	0x65, 0x72, 0x67, 0x65, 0x2e, 0x4c, 0x61, 0x62, 0x65, 0x6c, 0x73, 0x52, 0x06, 0x6c, 0x61, 0x62,
+	0x65, 0x6c, 0x73, 0x12, 0x21, 0x0a, 0x0c, 0x6e, 0x75, 0x6d, 0x5f, 0x63, 0x6f, 0x6d, 0x6d, 0x65,
+	0x6e, 0x74, 0x73, 0x18, 0x0a, 0x20, 0x03, 0x28, 0x04, 0x52, 0x0b, 0x6e, 0x75, 0x6d, 0x43, 0x6f,
+	0x6d, 0x6d, 0x65, 0x6e, 0x74, 0x73, 0x12, 0x1a, 0x0a, 0x08, 0x63, 0x6f, 0x6d, 0x6d, 0x65, 0x6e,
+	0x74, 0x73, 0x18, 0x0b, 0x20, 0x03, 0x28, 0x04, 0x52, 0x08, 0x63, 0x6f, 0x6d, 0x6d, 0x65, 0x6e,
+	0x74, 0x73, 0x12, 0x29, 0x0a, 0x10, 0x74, 0x72, 0x69, 0x6d, 0x6d, 0x65, 0x64, 0x5f, 0x6e, 0x65,
+	0x77, 0x6c, 0x69, 0x6e, 0x65, 0x73, 0x18, 0x0c, 0x20, 0x03, 0x28, 0x04, 0x52, 0x0f, 0x74, 0x72,
+	0x69, 0x6d, 0x6d, 0x65, 0x64, 0x4e, 0x65, 0x77, 0x6c, 0x69, 0x6e, 0x65, 0x73, 0x22, 0x2f, 0x0a,
+	0x11, 0x4d, 0x65, 0x72, 0x67, 0x65, 0x64, 0x42, 0x79, 0x74, 0x65, 0x50, 0x72, 0x6f, 0x66, 0x69,
+	0x6c, 0x65, 0x12, 0x1a, 0x0a, 0x08, 0x70, 0x72, 0x6f, 0x66, 0x69, 0x6c, 0x65, 0x73, 0x18, 0x01,
+	0x20, 0x03, 0x28, 0x0c, 0x52, 0x08, 0x70, 0x72, 0x6f, 0x66, 0x69, 0x6c, 0x65, 0x73, 0x22, 0xe0,
+	0x07, 0x0a, 0x0d, 0x4d, 0x65, 0x72, 0x67, 0x65, 0x64, 0x50, 0x72, 0x6f, 0x66, 0x69, 0x6c, 0x65,
+	0x12, 0x1f, 0x0a, 0x0b, 0x73, 0x61, 0x6d, 0x70, 0x6c, 0x65, 0x5f, 0x74, 0x79, 0x70, 0x65, 0x18,
+	0x01, 0x20, 0x03, 0x28, 0x03, 0x52, 0x0a, 0x73, 0x61, 0x6d, 0x70, 0x6c, 0x65, 0x54, 0x79, 0x70,
+	0x65, 0x12, 0x2e, 0x0a, 0x07, 0x73, 0x61, 0x6d, 0x70, 0x6c, 0x65, 0x73, 0x18, 0x02, 0x20, 0x03,
+	0x28, 0x0b, 0x32, 0x14, 0x2e, 0x70, 0x70, 0x6d, 0x65, 0x72, 0x67, 0x65, 0x2e, 0x4d, 0x65, 0x72,
+	0x67, 0x65, 0x53, 0x61, 0x6d, 0x70, 0x6c, 0x65, 0x52, 0x07, 0x73, 0x61, 0x6d, 0x70, 0x6c, 0x65,
+	0x73, 0x12, 0x34, 0x0a, 0x09, 0x66, 0x75, 0x6e, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x18, 0x03,
+	0x20, 0x03, 0x28, 0x0b, 0x32, 0x16, 0x2e, 0x70, 0x70, 0x6d, 0x65, 0x72, 0x67, 0x65, 0x2e, 0x4d,
+	0x65, 0x72, 0x67, 0x65, 0x46, 0x75, 0x6e, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x09, 0x66, 0x75,
+	0x6e, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x12, 0x34, 0x0a, 0x09, 0x6c, 0x6f, 0x63, 0x61, 0x74,
+	0x69, 0x6f, 0x6e, 0x73, 0x18, 0x04, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x16, 0x2e, 0x70, 0x70, 0x6d,
+	0x65, 0x72, 0x67, 0x65, 0x2e, 0x4d, 0x65, 0x72, 0x67, 0x65, 0x4c, 0x6f, 0x63, 0x61, 0x74, 0x69,
+	0x6f, 0x6e, 0x52, 0x09, 0x6c, 0x6f, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x12, 0x31, 0x0a,
+	0x08, 0x6d, 0x61, 0x70, 0x70, 0x69, 0x6e, 0x67, 0x73, 0x18, 0x05, 0x20, 0x03, 0x28, 0x0b, 0x32,
+	0x15, 0x2e, 0x70, 0x70, 0x6d, 0x65, 0x72, 0x67, 0x65, 0x2e, 0x4d, 0x65, 0x72, 0x67, 0x65, 0x4d,
+	0x61, 0x70, 0x70, 0x69, 0x6e, 0x67, 0x52, 0x08, 0x6d, 0x61, 0x70, 0x70, 0x69, 0x6e, 0x67, 0x73,
+	0x12, 0x21, 0x0a, 0x0c, 0x70, 0x65, 0x72, 0x69, 0x6f, 0x64, 0x5f, 0x74, 0x79, 0x70, 0x65, 0x73,
+	0x18, 0x06, 0x20, 0x03, 0x28, 0x03, 0x52, 0x0b, 0x70, 0x65, 0x72, 0x69, 0x6f, 0x64, 0x54, 0x79,
+	0x70, 0x65, 0x73, 0x12, 0x18, 0x0a, 0x07, 0x70, 0x65, 0x72, 0x69, 0x6f, 0x64, 0x73, 0x18, 0x07,
+	0x20, 0x03, 0x28, 0x03, 0x52, 0x07, 0x70, 0x65, 0x72, 0x69, 0x6f, 0x64, 0x73, 0x12, 0x1f, 0x0a,
+	0x0b, 0x74, 0x69, 0x6d, 0x65, 0x73, 0x5f, 0x6e, 0x61, 0x6e, 0x6f, 0x73, 0x18, 0x08, 0x20, 0x03,
+	0x28, 0x03, 0x52, 0x0a, 0x74, 0x69, 0x6d, 0x65, 0x73, 0x4e, 0x61, 0x6e, 0x6f, 0x73, 0x12, 0x27,
+	0x0a, 0x0f, 0x64, 0x75, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x5f, 0x6e, 0x61, 0x6e, 0x6f,
+	0x73, 0x18, 0x09, 0x20, 0x03, 0x28, 0x03, 0x52, 0x0e, 0x64, 0x75, 0x72, 0x61, 0x74, 0x69, 0x6f,
+	0x6e, 0x73, 0x4e, 0x61, 0x6e, 0x6f, 0x73, 0x12, 0x21, 0x0a, 0x0c, 0x73, 0x74, 0x72, 0x69, 0x6e,
+	0x67, 0x5f, 0x74, 0x61, 0x62, 0x6c, 0x65, 0x18, 0x0a, 0x20, 0x03, 0x28, 0x09, 0x52, 0x0b, 0x73,
+	0x74, 0x72, 0x69, 0x6e, 0x67, 0x54, 0x61, 0x62, 0x6c, 0x65, 0x12, 0x23, 0x0a, 0x0d, 0x6e, 0x75,
+	0x6d, 0x5f, 0x66, 0x75, 0x6e, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x18, 0x0b, 0x20, 0x03, 0x28,
+	0x04, 0x52, 0x0c, 0x6e, 0x75, 0x6d, 0x46, 0x75, 0x6e, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x12,
+	0x23, 0x0a, 0x0d, 0x6e, 0x75, 0x6d, 0x5f, 0x6c, 0x6f, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x73,
+	0x18, 0x0c, 0x20, 0x03, 0x28, 0x04, 0x52, 0x0c, 0x6e, 0x75, 0x6d, 0x4c, 0x6f, 0x63, 0x61, 0x74,
+	0x69, 0x6f, 0x6e, 0x73, 0x12, 0x28, 0x0a, 0x10, 0x6e, 0x75, 0x6d, 0x5f, 0x73, 0x61, 0x6d, 0x70,
+	0x6c, 0x65, 0x5f, 0x74, 0x79, 0x70, 0x65, 0x73, 0x18, 0x0d, 0x20, 0x03, 0x28, 0x04, 0x52, 0x0e,
+	0x6e, 0x75, 0x6d, 0x53, 0x61, 0x6d, 0x70, 0x6c, 0x65, 0x54, 0x79, 0x70, 0x65, 0x73, 0x12, 0x21,
+	0x0a, 0x0c, 0x6e, 0x75, 0x6d, 0x5f, 0x6d, 0x61, 0x70, 0x70, 0x69, 0x6e, 0x67, 0x73, 0x18, 0x0e,
+	0x20, 0x03, 0x28, 0x04, 0x52, 0x0b, 0x6e, 0x75, 0x6d, 0x4d, 0x61, 0x70, 0x70, 0x69, 0x6e, 0x67,
+	0x73, 0x12, 0x1f, 0x0a, 0x0b, 0x6e, 0x75, 0x6d, 0x5f, 0x73, 0x61, 0x6d, 0x70, 0x6c, 0x65, 0x73,
+	0x18, 0x0f, 0x20, 0x03, 0x28, 0x04, 0x52, 0x0a, 0x6e, 0x75, 0x6d, 0x53, 0x61, 0x6d, 0x70, 0x6c,
+	0x65, 0x73, 0x12, 0x3a, 0x0a, 0x06, 0x6c, 0x61, 0x62, 0x65, 0x6c, 0x73, 0x18, 0x10, 0x20, 0x03,
+	0x28, 0x0b, 0x32, 0x22, 0x2e, 0x70, 0x70, 0x6d, 0x65, 0x72, 0x67, 0x65, 0x2e, 0x4d, 0x65, 0x72,
+	0x67, 0x65, 0x64, 0x50, 0x72, 0x6f, 0x66, 0x69, 0x6c, 0x65, 0x2e, 0x4c, 0x61, 0x62, 0x65, 0x6c,
+	0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x06, 0x6c, 0x61, 0x62, 0x65, 0x6c, 0x73, 0x12, 0x25,
+	0x0a, 0x0e, 0x6d, 0x61, 0x70, 0x70, 0x69, 0x6e, 0x67, 0x5f, 0x73, 0x74, 0x61, 0x72, 0x74, 0x73,
+	0x18, 0x11, 0x20, 0x03, 0x28, 0x04, 0x52, 0x0d, 0x6d, 0x61, 0x70, 0x70, 0x69, 0x6e, 0x67, 0x53,
+	0x74, 0x61, 0x72, 0x74, 0x73, 0x12, 0x2c, 0x0a, 0x12, 0x6e, 0x75, 0x6d, 0x5f, 0x6d, 0x61, 0x70,
+	0x70, 0x69, 0x6e, 0x67, 0x5f, 0x73, 0x74, 0x61, 0x72, 0x74, 0x73, 0x18, 0x12, 0x20, 0x03, 0x28,
+	0x04, 0x52, 0x10, 0x6e, 0x75, 0x6d, 0x4d, 0x61, 0x70, 0x70, 0x69, 0x6e, 0x67, 0x53, 0x74, 0x61,
+	0x72, 0x74, 0x73, 0x12, 0x2e, 0x0a, 0x13, 0x66, 0x72, 0x6f, 0x6e, 0x74, 0x5f, 0x63, 0x6f, 0x64,
+	0x65, 0x64, 0x5f, 0x73, 0x74, 0x72, 0x69, 0x6e, 0x67, 0x73, 0x18, 0x13, 0x20, 0x01, 0x28, 0x0c,
+	0x52, 0x11, 0x66, 0x72, 0x6f, 0x6e, 0x74, 0x43, 0x6f, 0x64, 0x65, 0x64, 0x53, 0x74, 0x72, 0x69,
+	0x6e, 0x67, 0x73, 0x12, 0x1e, 0x0a, 0x0a, 0x64, 0x69, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x61, 0x72,
+	0x79, 0x18, 0x14, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0a, 0x64, 0x69, 0x63, 0x74, 0x69, 0x6f, 0x6e,
+	0x61, 0x72, 0x79, 0x12, 0x2d, 0x0a, 0x12, 0x64, 0x69, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x61, 0x72,
+	0x79, 0x5f, 0x73, 0x74, 0x72, 0x69, 0x6e, 0x67, 0x73, 0x18, 0x15, 0x20, 0x01, 0x28, 0x04, 0x52,
+	0x11, 0x64, 0x69, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x61, 0x72, 0x79, 0x53, 0x74, 0x72, 0x69, 0x6e,
+	0x67, 0x73, 0x12, 0x21, 0x0a, 0x0c, 0x64, 0x65, 0x6c, 0x74, 0x61, 0x5f, 0x76, 0x61, 0x6c, 0x75,
+	0x65, 0x73, 0x18, 0x16, 0x20, 0x01, 0x28, 0x08, 0x52, 0x0b, 0x64, 0x65, 0x6c, 0x74, 0x61, 0x56,
+	0x61, 0x6c, 0x75, 0x65, 0x73, 0x1a, 0x4a, 0x0a, 0x0b, 0x4c, 0x61, 0x62, 0x65, 0x6c, 0x73, 0x45,
+	0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x04, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x25, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18,
+	0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0f, 0x2e, 0x70, 0x70, 0x6d, 0x65, 0x72, 0x67, 0x65, 0x2e,
+	0x4c, 0x61, 0x62, 0x65, 0x6c, 0x73, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38,
+	0x01, 0x22, 0xb0, 0x01, 0x0a, 0x10, 0x53, 0x79, 0x6d, 0x62, 0x6f, 0x6c, 0x44, 0x69, 0x63, 0x74,
+	0x69, 0x6f, 0x6e, 0x61, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x21, 0x0a, 0x0c, 0x73, 0x74, 0x72, 0x69,
+	0x6e, 0x67, 0x5f, 0x74, 0x61, 0x62, 0x6c, 0x65, 0x18, 0x02, 0x20, 0x03, 0x28, 0x09, 0x52, 0x0b,
+	0x73, 0x74, 0x72, 0x69, 0x6e, 0x67, 0x54, 0x61, 0x62, 0x6c, 0x65, 0x12, 0x34, 0x0a, 0x09, 0x66,
+	0x75, 0x6e, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x18, 0x03, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x16,
+	0x2e, 0x70, 0x70, 0x6d, 0x65, 0x72, 0x67, 0x65, 0x2e, 0x4d, 0x65, 0x72, 0x67, 0x65, 0x46, 0x75,
+	0x6e, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x09, 0x66, 0x75, 0x6e, 0x63, 0x74, 0x69, 0x6f, 0x6e,
+	0x73, 0x12, 0x31, 0x0a, 0x08, 0x6d, 0x61, 0x70, 0x70, 0x69, 0x6e, 0x67, 0x73, 0x18, 0x04, 0x20,
+	0x03, 0x28, 0x0b, 0x32, 0x15, 0x2e, 0x70, 0x70, 0x6d, 0x65, 0x72, 0x67, 0x65, 0x2e, 0x4d, 0x65,
+	0x72, 0x67, 0x65, 0x4d, 0x61, 0x70, 0x70, 0x69, 0x6e, 0x67, 0x52, 0x08, 0x6d, 0x61, 0x70, 0x70,
+	0x69, 0x6e, 0x67, 0x73, 0x22, 0xa8, 0x01, 0x0a, 0x0e, 0x53, 0x68, 0x61, 0x72, 0x64, 0x65, 0x64,
+	0x50, 0x72, 0x6f, 0x66, 0x69, 0x6c, 0x65, 0x12, 0x2e, 0x0a, 0x06, 0x73, 0x68, 0x61, 0x72, 0x64,
+	0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x16, 0x2e, 0x70, 0x70, 0x6d, 0x65, 0x72, 0x67,
+	0x65, 0x2e, 0x4d, 0x65, 0x72, 0x67, 0x65, 0x64, 0x50, 0x72, 0x6f, 0x66, 0x69, 0x6c, 0x65, 0x52,
+	0x06, 0x73, 0x68, 0x61, 0x72, 0x64, 0x73, 0x12, 0x1e, 0x0a, 0x0a, 0x73, 0x69, 0x67, 0x6e, 0x61,
+	0x74, 0x75, 0x72, 0x65, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x09, 0x52, 0x0a, 0x73, 0x69, 0x67,
+	0x6e, 0x61, 0x74, 0x75, 0x72, 0x65, 0x73, 0x12, 0x21, 0x0a, 0x0c, 0x65, 0x6e, 0x74, 0x72, 0x79,
+	0x5f, 0x73, 0x68, 0x61, 0x72, 0x64, 0x73, 0x18, 0x03, 0x20, 0x03, 0x28, 0x04, 0x52, 0x0b, 0x65,
+	0x6e, 0x74, 0x72, 0x79, 0x53, 0x68, 0x61, 0x72, 0x64, 0x73, 0x12, 0x23, 0x0a, 0x0d, 0x65, 0x6e,
+	0x74, 0x72, 0x79, 0x5f, 0x69, 0x6e, 0x64, 0x65, 0x78, 0x65, 0x73, 0x18, 0x04, 0x20, 0x03, 0x28,
+	0x04, 0x52, 0x0c, 0x65, 0x6e, 0x74, 0x72, 0x79, 0x49, 0x6e, 0x64, 0x65, 0x78, 0x65, 0x73, 0x22,
+	0x38, 0x0a, 0x0e, 0x4d, 0x65, 0x72, 0x67, 0x65, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x54, 0x79, 0x70,
+	0x65, 0x12, 0x12, 0x0a, 0x04, 0x74, 0x79, 0x70, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x03, 0x52,
+	0x04, 0x74, 0x79, 0x70, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x75, 0x6e, 0x69, 0x74, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x03, 0x52, 0x04, 0x75, 0x6e, 0x69, 0x74, 0x22, 0x44, 0x0a, 0x0b, 0x4d, 0x65, 0x72,
+	0x67, 0x65, 0x53, 0x61, 0x6d, 0x70, 0x6c, 0x65, 0x12, 0x1f, 0x0a, 0x0b, 0x6c, 0x6f, 0x63, 0x61,
+	0x74, 0x69, 0x6f, 0x6e, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x03, 0x28, 0x03, 0x52, 0x0a, 0x6c,
+	0x6f, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x49, 0x64, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c,
+	0x75, 0x65, 0x18, 0x02, 0x20, 0x03, 0x28, 0x03, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x22,
+	0x1c, 0x0a, 0x0a, 0x4c, 0x6f, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x49, 0x44, 0x12, 0x0e, 0x0a,
+	0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x03, 0x28, 0x03, 0x52, 0x02, 0x69, 0x64, 0x22, 0x25, 0x0a,
+	0x0f, 0x46, 0x75, 0x6e, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x43, 0x6f, 0x6d, 0x70, 0x61, 0x63, 0x74,
+	0x12, 0x12, 0x0a, 0x04, 0x64, 0x61, 0x74, 0x61, 0x18, 0x01, 0x20, 0x03, 0x28, 0x03, 0x52, 0x04,
+	0x64, 0x61, 0x74, 0x61, 0x22, 0x88, 0x01, 0x0a, 0x15, 0x46, 0x75, 0x6e, 0x63, 0x74, 0x69, 0x6f,
+	0x6e, 0x4f, 0x72, 0x46, 0x75, 0x6e, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x65, 0x66, 0x12, 0x34,
+	0x0a, 0x08, 0x66, 0x75, 0x6e, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b,
+	0x32, 0x16, 0x2e, 0x70, 0x70, 0x6d, 0x65, 0x72, 0x67, 0x65, 0x2e, 0x4d, 0x65, 0x72, 0x67, 0x65,
+	0x46, 0x75, 0x6e, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x48, 0x00, 0x52, 0x08, 0x66, 0x75, 0x6e, 0x63,
+	0x74, 0x69, 0x6f, 0x6e, 0x12, 0x28, 0x0a, 0x03, 0x72, 0x65, 0x66, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x0b, 0x32, 0x14, 0x2e, 0x70, 0x70, 0x6d, 0x65, 0x72, 0x67, 0x65, 0x2e, 0x46, 0x75, 0x6e, 0x63,
+	0x74, 0x69, 0x6f, 0x6e, 0x52, 0x65, 0x66, 0x48, 0x00, 0x52, 0x03, 0x72, 0x65, 0x66, 0x42, 0x0f,
+	0x0a, 0x0d, 0x66, 0x75, 0x6e, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x4f, 0x72, 0x52, 0x65, 0x66, 0x22,
+	0x45, 0x0a, 0x0b, 0x46, 0x75, 0x6e, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x65, 0x66, 0x12, 0x0e,
+	0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x02, 0x69, 0x64, 0x12, 0x26,
+	0x0a, 0x0f, 0x66, 0x75, 0x6e, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x5f, 0x72, 0x65, 0x66, 0x5f, 0x69,
+	0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x04, 0x52, 0x0d, 0x66, 0x75, 0x6e, 0x63, 0x74, 0x69, 0x6f,
+	0x6e, 0x52, 0x65, 0x66, 0x49, 0x64, 0x22, 0x8f, 0x01, 0x0a, 0x0d, 0x4d, 0x65, 0x72, 0x67, 0x65,
+	0x46, 0x75, 0x6e, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x04, 0x52, 0x02, 0x69, 0x64, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65,
+	0x18, 0x03, 0x20, 0x01, 0x28, 0x03, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x1f, 0x0a, 0x0b,
+	0x73, 0x79, 0x73, 0x74, 0x65, 0x6d, 0x5f, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28,
+	0x03, 0x52, 0x0a, 0x73, 0x79, 0x73, 0x74, 0x65, 0x6d, 0x4e, 0x61, 0x6d, 0x65, 0x12, 0x1a, 0x0a,
+	0x08, 0x66, 0x69, 0x6c, 0x65, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x05, 0x20, 0x01, 0x28, 0x03, 0x52,
+	0x08, 0x66, 0x69, 0x6c, 0x65, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x1d, 0x0a, 0x0a, 0x73, 0x74, 0x61,
+	0x72, 0x74, 0x5f, 0x6c, 0x69, 0x6e, 0x65, 0x18, 0x06, 0x20, 0x01, 0x28, 0x03, 0x52, 0x09, 0x73,
+	0x74, 0x61, 0x72, 0x74, 0x4c, 0x69, 0x6e, 0x65, 0x22, 0x9d, 0x01, 0x0a, 0x0d, 0x4d, 0x65, 0x72,
+	0x67, 0x65, 0x4c, 0x6f, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x02, 0x69, 0x64, 0x12, 0x1d, 0x0a, 0x0a, 0x6d, 0x61,
+	0x70, 0x70, 0x69, 0x6e, 0x67, 0x5f, 0x69, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x04, 0x52, 0x09,
+	0x6d, 0x61, 0x70, 0x70, 0x69, 0x6e, 0x67, 0x49, 0x64, 0x12, 0x18, 0x0a, 0x07, 0x61, 0x64, 0x64,
+	0x72, 0x65, 0x73, 0x73, 0x18, 0x03, 0x20, 0x01, 0x28, 0x04, 0x52, 0x07, 0x61, 0x64, 0x64, 0x72,
+	0x65, 0x73, 0x73, 0x12, 0x26, 0x0a, 0x04, 0x6c, 0x69, 0x6e, 0x65, 0x18, 0x04, 0x20, 0x03, 0x28,
+	0x0b, 0x32, 0x12, 0x2e, 0x70, 0x70, 0x6d, 0x65, 0x72, 0x67, 0x65, 0x2e, 0x4d, 0x65, 0x72, 0x67,
+	0x65, 0x4c, 0x69, 0x6e, 0x65, 0x52, 0x04, 0x6c, 0x69, 0x6e, 0x65, 0x12, 0x1b, 0x0a, 0x09, 0x69,
+	0x73, 0x5f, 0x66, 0x6f, 0x6c, 0x64, 0x65, 0x64, 0x18, 0x05, 0x20, 0x01, 0x28, 0x08, 0x52, 0x08,
+	0x69, 0x73, 0x46, 0x6f, 0x6c, 0x64, 0x65, 0x64, 0x22, 0x40, 0x0a, 0x09, 0x4d, 0x65, 0x72, 0x67,
+	0x65, 0x4c, 0x69, 0x6e, 0x65, 0x12, 0x1f, 0x0a, 0x0b, 0x66, 0x75, 0x6e, 0x63, 0x74, 0x69, 0x6f,
+	0x6e, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x0a, 0x66, 0x75, 0x6e, 0x63,
+	0x74, 0x69, 0x6f, 0x6e, 0x49, 0x64, 0x12, 0x12, 0x0a, 0x04, 0x6c, 0x69, 0x6e, 0x65, 0x18, 0x02,
+	0x20, 0x01, 0x28, 0x03, 0x52, 0x04, 0x6c, 0x69, 0x6e, 0x65, 0x22, 0xdc, 0x02, 0x0a, 0x0c, 0x4d,
+	0x65, 0x72, 0x67, 0x65, 0x4d, 0x61, 0x70, 0x70, 0x69, 0x6e, 0x67, 0x12, 0x0e, 0x0a, 0x02, 0x69,
+	0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x02, 0x69, 0x64, 0x12, 0x21, 0x0a, 0x0c, 0x6d,
+	0x65, 0x6d, 0x6f, 0x72, 0x79, 0x5f, 0x73, 0x74, 0x61, 0x72, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x04, 0x52, 0x0b, 0x6d, 0x65, 0x6d, 0x6f, 0x72, 0x79, 0x53, 0x74, 0x61, 0x72, 0x74, 0x12, 0x21,
+	0x0a, 0x0c, 0x6d, 0x65, 0x6d, 0x6f, 0x72, 0x79, 0x5f, 0x6c, 0x69, 0x6d, 0x69, 0x74, 0x18, 0x03,
+	0x20, 0x01, 0x28, 0x04, 0x52, 0x0b, 0x6d, 0x65, 0x6d, 0x6f, 0x72, 0x79, 0x4c, 0x69, 0x6d, 0x69,
+	0x74, 0x12, 0x1f, 0x0a, 0x0b, 0x66, 0x69, 0x6c, 0x65, 0x5f, 0x6f, 0x66, 0x66, 0x73, 0x65, 0x74,
+	0x18, 0x04, 0x20, 0x01, 0x28, 0x04, 0x52, 0x0a, 0x66, 0x69, 0x6c, 0x65, 0x4f, 0x66, 0x66, 0x73,
+	0x65, 0x74, 0x12, 0x1a, 0x0a, 0x08, 0x66, 0x69, 0x6c, 0x65, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x05,
+	0x20, 0x01, 0x28, 0x03, 0x52, 0x08, 0x66, 0x69, 0x6c, 0x65, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x19,
+	0x0a, 0x08, 0x62, 0x75, 0x69, 0x6c, 0x64, 0x5f, 0x69, 0x64, 0x18, 0x06, 0x20, 0x01, 0x28, 0x03,
+	0x52, 0x07, 0x62, 0x75, 0x69, 0x6c, 0x64, 0x49, 0x64, 0x12, 0x23, 0x0a, 0x0d, 0x68, 0x61, 0x73,
+	0x5f, 0x66, 0x75, 0x6e, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x18, 0x07, 0x20, 0x01, 0x28, 0x08,
+	0x52, 0x0c, 0x68, 0x61, 0x73, 0x46, 0x75, 0x6e, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x12, 0x23,
+	0x0a, 0x0d, 0x68, 0x61, 0x73, 0x5f, 0x66, 0x69, 0x6c, 0x65, 0x6e, 0x61, 0x6d, 0x65, 0x73, 0x18,
+	0x08, 0x20, 0x01, 0x28, 0x08, 0x52, 0x0c, 0x68, 0x61, 0x73, 0x46, 0x69, 0x6c, 0x65, 0x6e, 0x61,
+	0x6d, 0x65, 0x73, 0x12, 0x28, 0x0a, 0x10, 0x68, 0x61, 0x73, 0x5f, 0x6c, 0x69, 0x6e, 0x65, 0x5f,
+	0x6e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x73, 0x18, 0x09, 0x20, 0x01, 0x28, 0x08, 0x52, 0x0e, 0x68,
+	0x61, 0x73, 0x4c, 0x69, 0x6e, 0x65, 0x4e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x73, 0x12, 0x2a, 0x0a,
+	0x11, 0x68, 0x61, 0x73, 0x5f, 0x69, 0x6e, 0x6c, 0x69, 0x6e, 0x65, 0x5f, 0x66, 0x72, 0x61, 0x6d,
+	0x65, 0x73, 0x18, 0x0a, 0x20, 0x01, 0x28, 0x08, 0x52, 0x0f, 0x68, 0x61, 0x73, 0x49, 0x6e, 0x6c,
+	0x69, 0x6e, 0x65, 0x46, 0x72, 0x61, 0x6d, 0x65, 0x73, 0x42, 0x23, 0x5a, 0x21, 0x67, 0x69, 0x74,
+	0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x74, 0x68, 0x72, 0x65, 0x61, 0x64, 0x65, 0x64,
+	0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x2f, 0x70, 0x70, 0x6d, 0x65, 0x72, 0x67, 0x65, 0x62, 0x06,
+	0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
 }
 
 var (
@@ -1179,41 +1511,47 @@ func file_api_merged_profile_proto_rawDescGZIP() []byte {
 	return file_api_merged_profile_proto_rawDescData
 }
 
-var file_api_merged_profile_proto_msgTypes = make([]protoimpl.MessageInfo, 14)
+var file_api_merged_profile_proto_msgTypes = make([]protoimpl.MessageInfo, 16)
 var file_api_merged_profile_proto_goTypes = []interface{}{
 	(*MergedGoroutineProfile)(nil), // 0: ppmerge.MergedGoroutineProfile
 	(*MergedByteProfile)(nil),      // 1: ppmerge.MergedByteProfile
 	(*MergedProfile)(nil),          // 2: ppmerge.MergedProfile
-	(*MergeValueType)(nil),         // 3: ppmerge.MergeValueType
-	(*MergeSample)(nil),            // 4: ppmerge.MergeSample
-	(*LocationID)(nil),             // 5: ppmerge.LocationID
-	(*FunctionCompact)(nil),        // 6: ppmerge.FunctionCompact
-	(*FunctionOrFunctionRef)(nil),  // 7: ppmerge.FunctionOrFunctionRef
-	(*FunctionRef)(nil),            // 8: ppmerge.FunctionRef
-	(*MergeFunction)(nil),          // 9: ppmerge.MergeFunction
-	(*MergeLocation)(nil),          // 10: ppmerge.MergeLocation
-	(*MergeLine)(nil),              // 11: ppmerge.MergeLine
-	(*MergeMapping)(nil),           // 12: ppmerge.MergeMapping
-	nil,                            // 13: ppmerge.MergedProfile.LabelsEntry
-	(*profile.Stacktrace)(nil),     // 14: ppmerge.Stacktrace
-	(*profile.Labels)(nil),         // 15: ppmerge.Labels
+	(*SymbolDictionary)(nil),       // 3: ppmerge.SymbolDictionary
+	(*ShardedProfile)(nil),         // 4: ppmerge.ShardedProfile
+	(*MergeValueType)(nil),         // 5: ppmerge.MergeValueType
+	(*MergeSample)(nil),            // 6: ppmerge.MergeSample
+	(*LocationID)(nil),             // 7: ppmerge.LocationID
+	(*FunctionCompact)(nil),        // 8: ppmerge.FunctionCompact
+	(*FunctionOrFunctionRef)(nil),  // 9: ppmerge.FunctionOrFunctionRef
+	(*FunctionRef)(nil),            // 10: ppmerge.FunctionRef
+	(*MergeFunction)(nil),          // 11: ppmerge.MergeFunction
+	(*MergeLocation)(nil),          // 12: ppmerge.MergeLocation
+	(*MergeLine)(nil),              // 13: ppmerge.MergeLine
+	(*MergeMapping)(nil),           // 14: ppmerge.MergeMapping
+	nil,                            // 15: ppmerge.MergedProfile.LabelsEntry
+	(*profile.Stacktrace)(nil),     // 16: ppmerge.Stacktrace
+	(*profile.Labels)(nil),         // 17: ppmerge.Labels
 }
 var file_api_merged_profile_proto_depIdxs = []int32{
-	14, // 0: ppmerge.MergedGoroutineProfile.stacktraces:type_name -> ppmerge.Stacktrace
-	4,  // 1: ppmerge.MergedProfile.samples:type_name -> ppmerge.MergeSample
-	9,  // 2: ppmerge.MergedProfile.functions:type_name -> ppmerge.MergeFunction
-	10, // 3: ppmerge.MergedProfile.locations:type_name -> ppmerge.MergeLocation
-	12, // 4: ppmerge.MergedProfile.mappings:type_name -> ppmerge.MergeMapping
-	13, // 5: ppmerge.MergedProfile.labels:type_name -> ppmerge.MergedProfile.LabelsEntry
-	9,  // 6: ppmerge.FunctionOrFunctionRef.function:type_name -> ppmerge.MergeFunction
-	8,  // 7: ppmerge.FunctionOrFunctionRef.ref:type_name -> ppmerge.FunctionRef
-	11, // 8: ppmerge.MergeLocation.line:type_name -> ppmerge.MergeLine
-	15, // 9: ppmerge.MergedProfile.LabelsEntry.value:type_name -> ppmerge.Labels
-	10, // [10:10] is the sub-list for method output_type
-	10, // [10:10] is the sub-list for method input_type
-	10, // [10:10] is the sub-list for extension type_name
-	10, // [10:10] is the sub-list for extension extendee
-	0,  // [0:10] is the sub-list for field type_name
+	16, // 0: ppmerge.MergedGoroutineProfile.stacktraces:type_name -> ppmerge.Stacktrace
+	17, // 1: ppmerge.MergedGoroutineProfile.labels:type_name -> ppmerge.Labels
+	6,  // 2: ppmerge.MergedProfile.samples:type_name -> ppmerge.MergeSample
+	11, // 3: ppmerge.MergedProfile.functions:type_name -> ppmerge.MergeFunction
+	12, // 4: ppmerge.MergedProfile.locations:type_name -> ppmerge.MergeLocation
+	14, // 5: ppmerge.MergedProfile.mappings:type_name -> ppmerge.MergeMapping
+	15, // 6: ppmerge.MergedProfile.labels:type_name -> ppmerge.MergedProfile.LabelsEntry
+	11, // 7: ppmerge.SymbolDictionary.functions:type_name -> ppmerge.MergeFunction
+	14, // 8: ppmerge.SymbolDictionary.mappings:type_name -> ppmerge.MergeMapping
+	2,  // 9: ppmerge.ShardedProfile.shards:type_name -> ppmerge.MergedProfile
+	11, // 10: ppmerge.FunctionOrFunctionRef.function:type_name -> ppmerge.MergeFunction
+	10, // 11: ppmerge.FunctionOrFunctionRef.ref:type_name -> ppmerge.FunctionRef
+	13, // 12: ppmerge.MergeLocation.line:type_name -> ppmerge.MergeLine
+	17, // 13: ppmerge.MergedProfile.LabelsEntry.value:type_name -> ppmerge.Labels
+	14, // [14:14] is the sub-list for method output_type
+	14, // [14:14] is the sub-list for method input_type
+	14, // [14:14] is the sub-list for extension type_name
+	14, // [14:14] is the sub-list for extension extendee
+	0,  // [0:14] is the sub-list for field type_name
 }
 
 func init() { file_api_merged_profile_proto_init() }
@@ -1259,7 +1597,7 @@ func file_api_merged_profile_proto_init() {
 			}
 		}
 		file_api_merged_profile_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*MergeValueType); i {
+			switch v := v.(*SymbolDictionary); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -1271,7 +1609,7 @@ func file_api_merged_profile_proto_init() {
 			}
 		}
 		file_api_merged_profile_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*MergeSample); i {
+			switch v := v.(*ShardedProfile); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -1283,7 +1621,7 @@ func file_api_merged_profile_proto_init() {
 			}
 		}
 		file_api_merged_profile_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*LocationID); i {
+			switch v := v.(*MergeValueType); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -1295,7 +1633,7 @@ func file_api_merged_profile_proto_init() {
 			}
 		}
 		file_api_merged_profile_proto_msgTypes[6].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*FunctionCompact); i {
+			switch v := v.(*MergeSample); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -1307,7 +1645,7 @@ func file_api_merged_profile_proto_init() {
 			}
 		}
 		file_api_merged_profile_proto_msgTypes[7].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*FunctionOrFunctionRef); i {
+			switch v := v.(*LocationID); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -1319,7 +1657,7 @@ func file_api_merged_profile_proto_init() {
 			}
 		}
 		file_api_merged_profile_proto_msgTypes[8].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*FunctionRef); i {
+			switch v := v.(*FunctionCompact); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -1331,7 +1669,7 @@ func file_api_merged_profile_proto_init() {
 			}
 		}
 		file_api_merged_profile_proto_msgTypes[9].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*MergeFunction); i {
+			switch v := v.(*FunctionOrFunctionRef); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -1343,7 +1681,7 @@ func file_api_merged_profile_proto_init() {
 			}
 		}
 		file_api_merged_profile_proto_msgTypes[10].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*MergeLocation); i {
+			switch v := v.(*FunctionRef); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -1355,7 +1693,7 @@ func file_api_merged_profile_proto_init() {
 			}
 		}
 		file_api_merged_profile_proto_msgTypes[11].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*MergeLine); i {
+			switch v := v.(*MergeFunction); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -1367,6 +1705,30 @@ func file_api_merged_profile_proto_init() {
 			}
 		}
 		file_api_merged_profile_proto_msgTypes[12].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*MergeLocation); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_api_merged_profile_proto_msgTypes[13].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*MergeLine); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_api_merged_profile_proto_msgTypes[14].Exporter = func(v interface{}, i int) interface{} {
 			switch v := v.(*MergeMapping); i {
 			case 0:
 				return &v.state
@@ -1379,7 +1741,7 @@ func file_api_merged_profile_proto_init() {
 			}
 		}
 	}
-	file_api_merged_profile_proto_msgTypes[7].OneofWrappers = []interface{}{
+	file_api_merged_profile_proto_msgTypes[9].OneofWrappers = []interface{}{
 		(*FunctionOrFunctionRef_Function)(nil),
 		(*FunctionOrFunctionRef_Ref)(nil),
 	}
@@ -1389,7 +1751,7 @@ func file_api_merged_profile_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_api_merged_profile_proto_rawDesc,
 			NumEnums:      0,
-			NumMessages:   14,
+			NumMessages:   16,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
@@ -0,0 +1,129 @@
+package ppmerge
+
+import (
+	"sort"
+
+	"github.com/pkg/errors"
+	"github.com/threadedstream/ppmerge/profile"
+)
+
+// MergerOption configures ProfileMerger
+type MergerOption func(pw *ProfileMerger)
+
+// WithNormalizedAddresses makes merger key mappings by file or build ID, offset and size instead
+// of their absolute memory range, and store addresses of their locations relative to the mapping
+// start. Processes of the same binary loaded at different addresses then share mappings and
+// locations. Memory start of every mapping is kept per entry, so that Unpack restores original
+// addresses. Mappings having neither file nor build ID are kept absolute.
+func WithNormalizedAddresses() MergerOption {
+	return func(pw *ProfileMerger) {
+		pw.normalizeAddresses = true
+	}
+}
+
+// putNormalizedMapping interns mapping relative to its start. False is returned and mapping
+// is left intact if it can't be normalized, e.g. entry has another mapping of the same binary
+// loaded elsewhere.
+func (pw *ProfileMerger) putNormalizedMapping(mapping *MergeMapping, p *profile.Profile, src *profile.Mapping) (uint64, bool) {
+	if p.StringTable[src.Filename] == "" && p.StringTable[src.BuildId] == "" {
+		return 0, false
+	}
+
+	start, limit := mapping.MemoryStart, mapping.MemoryLimit
+	mapping.MemoryStart, mapping.MemoryLimit = 0, limit-start
+
+	key := pw.getMappingKey(mapping)
+	key.normalized = true
+	mappingID, ok := pw.mappingTable[key]
+	if prevStart, seen := pw.entryStarts[mappingID]; ok && seen && prevStart != start {
+		mapping.MemoryStart, mapping.MemoryLimit = start, limit
+		return 0, false
+	}
+	pw.dedup.mappings.count(ok)
+	if !ok {
+		mappingID = uint64(len(pw.mergedProfile.Mappings) + 1)
+		mapping.Id = mappingID
+		pw.mappingTable[key] = mappingID
+		pw.mergedProfile.Mappings = append(pw.mergedProfile.Mappings, mapping)
+	}
+
+	if pw.entryStarts == nil {
+		pw.entryStarts = make(map[uint64]uint64)
+	}
+	pw.entryStarts[mappingID] = start
+	return mappingID, true
+}
+
+// flushMappingStarts records starts of mappings normalized in the current entry
+func (pw *ProfileMerger) flushMappingStarts() {
+	if !pw.normalizeAddresses {
+		return
+	}
+
+	mp := pw.mergedProfile
+	mp.NumMappingStarts = append(mp.NumMappingStarts, uint64(len(pw.entryStarts)))
+	mp.MappingStarts = appendMappingStarts(mp.MappingStarts, pw.entryStarts)
+	clear(pw.entryStarts)
+}
+
+// appendMappingStarts appends pairs of mapping id and start ordered by mapping id to data
+func appendMappingStarts(data []uint64, starts map[uint64]uint64) []uint64 {
+	mappingIDs := make([]uint64, 0, len(starts))
+	for mappingID := range starts {
+		mappingIDs = append(mappingIDs, mappingID)
+	}
+	sort.Slice(mappingIDs, func(i, j int) bool {
+		return mappingIDs[i] < mappingIDs[j]
+	})
+
+	for _, mappingID := range mappingIDs {
+		data = append(data, mappingID, starts[mappingID])
+	}
+	return data
+}
+
+// loadMappingStarts loads starts of mappings normalized in entry idx
+func (pu *ProfileUnPacker) loadMappingStarts(idx uint64) error {
+	mp := pu.decoded
+	if idx >= uint64(len(mp.NumMappingStarts)) {
+		return nil
+	}
+
+	var offset uint64
+	for i := uint64(0); i < idx; i++ {
+		offset += mp.NumMappingStarts[i] * 2
+	}
+	limit := offset + mp.NumMappingStarts[idx]*2
+	if limit > uint64(len(mp.MappingStarts)) {
+		return indexOutOfRangeErr
+	}
+
+	if pu.mappingStarts == nil {
+		pu.mappingStarts = make(map[uint64]uint64)
+	}
+	for ; offset < limit; offset += 2 {
+		pu.mappingStarts[mp.MappingStarts[offset]] = mp.MappingStarts[offset+1]
+	}
+	return nil
+}
+
+// Denormalized returns x with absolute addresses, i.e. as if it was merged without
+// WithNormalizedAddresses. x is returned as is if it has no normalized mappings.
+func (x *MergedProfile) Denormalized() (*MergedProfile, error) {
+	if len(x.MappingStarts) == 0 {
+		return x, nil
+	}
+
+	unpacker := NewProfileUnPacker(x)
+	ps := make([]*profile.Profile, len(x.NumSamples))
+	for idx := range ps {
+		p, err := unpacker.Unpack(uint64(idx))
+		if err != nil {
+			return nil, errors.Wrapf(err, "unpack entry %d", idx)
+		}
+		ps[idx] = new(profile.Profile)
+		ps[idx].From(p)
+	}
+
+	return NewProfileMerger().Merge(ps...), nil
+}
@@ -0,0 +1,127 @@
+package ppmerge
+
+import (
+	"bytes"
+	"testing"
+
+	pprofile "github.com/google/pprof/profile"
+	"github.com/stretchr/testify/require"
+	"github.com/threadedstream/ppmerge/profile"
+	"google.golang.org/protobuf/proto"
+)
+
+// relocatedProfiles returns profiles of paths with their mappings loaded delta bytes higher,
+// like profiles of the same binaries taken from processes with another ASLR base
+func relocatedProfiles(t *testing.T, delta uint64, paths ...string) []*profile.Profile {
+	ps := getProfilesVtProto(t, false, paths...)
+	for _, p := range ps {
+		for _, m := range p.Mapping {
+			m.MemoryStart += delta
+			m.MemoryLimit += delta
+		}
+		for _, loc := range p.Location {
+			if loc.MappingId != 0 {
+				loc.Address += delta
+			}
+		}
+	}
+	return ps
+}
+
+func TestNormalizedAddresses(t *testing.T) {
+	paths := []string{"hprof1", "parca_cpu", "hprof2"}
+	var profiles []*profile.Profile
+	for _, delta := range []uint64{0, 0x10000, 0x7f0000000000} {
+		profiles = append(profiles, relocatedProfiles(t, delta, paths...)...)
+	}
+
+	expected := NewProfileMerger().Merge(profiles...)
+	normalized := NewProfileMerger(WithNormalizedAddresses()).Merge(profiles...)
+	require.Len(t, normalized.NumMappingStarts, len(profiles))
+	require.Less(t, len(normalized.Mappings), len(expected.Mappings))
+	require.Less(t, len(normalized.Locations), len(expected.Locations))
+
+	requireSameEntries(t, expected, normalized)
+
+	// parallel merge yields the same result
+	for _, workers := range []int{1, 4} {
+		actual := NewProfileMerger(WithNormalizedAddresses()).MergeParallel(workers, profiles...)
+		require.True(t, proto.Equal(normalized, actual), "workers %d", workers)
+	}
+
+	// so does stream merge
+	bb := bytes.NewBuffer(nil)
+	sm := NewStreamMerger(bb, WithNormalizedAddresses())
+	for _, p := range profiles {
+		require.NoError(t, sm.Add(p))
+	}
+	require.NoError(t, sm.Close())
+	streamed := new(MergedProfile)
+	require.NoError(t, streamed.UnmarshalVT(bb.Bytes()))
+	require.Equal(t, normalized.NumMappingStarts, streamed.NumMappingStarts)
+	requireSameEntries(t, expected, streamed)
+
+	// encoding keeps mapping starts
+	data, err := normalized.MarshalDeterministic()
+	require.NoError(t, err)
+	decoded := new(MergedProfile)
+	require.NoError(t, decoded.UnmarshalVT(data))
+	requireSameEntries(t, expected, decoded)
+
+	denormalized, err := normalized.Denormalized()
+	require.NoError(t, err)
+	require.Empty(t, denormalized.MappingStarts)
+	requireSameEntries(t, expected, denormalized)
+
+	same, err := expected.Denormalized()
+	require.NoError(t, err)
+	require.Same(t, expected, same)
+}
+
+func TestNormalizedAddressesSameBinaryTwice(t *testing.T) {
+	first := &pprofile.Mapping{ID: 1, Start: 0x400000, Limit: 0x500000, File: "/srv/bin/app"}
+	second := &pprofile.Mapping{ID: 2, Start: 0x800000, Limit: 0x900000, File: "/srv/bin/app"}
+	anonymous := &pprofile.Mapping{ID: 3, Start: 0xa00000, Limit: 0xb00000}
+	src := &pprofile.Profile{
+		SampleType: []*pprofile.ValueType{{Type: "samples", Unit: "count"}},
+		PeriodType: &pprofile.ValueType{Type: "cpu", Unit: "nanoseconds"},
+		Mapping:    []*pprofile.Mapping{first, second, anonymous},
+		Location: []*pprofile.Location{
+			{ID: 1, Mapping: first, Address: 0x400010},
+			{ID: 2, Mapping: second, Address: 0x800010},
+			{ID: 3, Mapping: anonymous, Address: 0xa00010},
+		},
+	}
+	src.Sample = []*pprofile.Sample{{Location: src.Location, Value: []int64{1}}}
+	require.NoError(t, src.CheckValid())
+
+	p := new(profile.Profile)
+	p.From(src)
+
+	// the second copy is kept absolute, and so is the mapping without file
+	normalized := NewProfileMerger(WithNormalizedAddresses()).Merge(p)
+	require.Equal(t, []uint64{1, 0x400000}, normalized.MappingStarts)
+	require.Equal(t, []uint64{1}, normalized.NumMappingStarts)
+	require.Equal(t, uint64(0x800000), normalized.Mappings[1].MemoryStart)
+	require.Equal(t, uint64(0xa00000), normalized.Mappings[2].MemoryStart)
+
+	actual, err := NewProfileUnPacker(normalized).Unpack(0)
+	require.NoError(t, err)
+	require.NoError(t, actual.CheckValid())
+	require.Equal(t, src.String(), actual.String())
+}
+
+// requireSameEntries checks that every entry of actual unpacks to the same profile as in expected
+func requireSameEntries(t *testing.T, expected, actual *MergedProfile) {
+	require.Equal(t, len(expected.NumSamples), len(actual.NumSamples))
+
+	expectedUnpacker := NewProfileUnPacker(expected)
+	actualUnpacker := NewProfileUnPacker(actual)
+	for idx := range expected.NumSamples {
+		expectedProfile, err := expectedUnpacker.Unpack(uint64(idx))
+		require.NoError(t, err)
+		actualProfile, err := actualUnpacker.Unpack(uint64(idx))
+		require.NoError(t, err)
+		require.Equal(t, expectedProfile.String(), actualProfile.String(), "entry %d", idx)
+	}
+}
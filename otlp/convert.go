@@ -0,0 +1,326 @@
+package otlp
+
+import (
+	"encoding/binary"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/threadedstream/ppmerge"
+	"github.com/threadedstream/ppmerge/profile"
+)
+
+const (
+	scopeName = "github.com/threadedstream/ppmerge"
+
+	// attributes carrying fields of pprof mappings and locations that have no counterpart in OTLP
+	buildIDGNUAttr      = "process.executable.build_id.gnu"
+	buildIDGoAttr       = "process.executable.build_id.go"
+	hasFunctionsAttr    = "pprof.mapping.has_functions"
+	hasFilenamesAttr    = "pprof.mapping.has_filenames"
+	hasLineNumbersAttr  = "pprof.mapping.has_line_numbers"
+	hasInlineFramesAttr = "pprof.mapping.has_inline_frames"
+	isFoldedAttr        = "pprof.location.is_folded"
+)
+
+// exporter converts MergedProfile to ProfilesData
+type exporter struct {
+	mergedProfile *ppmerge.MergedProfile
+	dict          *ProfilesDictionary
+
+	stringIdx    map[string]int32
+	attributeIdx map[string]int32
+	stackIdx     map[string]int32
+}
+
+// FromMergedProfile converts mergedProfile to ProfilesData. Tables of mergedProfile become
+// the shared dictionary, every entry becomes one profile per its sample type, entries having
+// no sample types become a single profile without sample type and values, so that indexes
+// of entries survive ToMergedProfile. Addresses of profiles merged with normalized addresses
+// are converted back to absolute ones. Symbol dictionary mergedProfile refers to is resolved
+// with resolver, which may be nil if there's none.
+func FromMergedProfile(mergedProfile *ppmerge.MergedProfile, resolver ppmerge.DictionaryResolver) (*ProfilesData, error) {
+	mergedProfile, err := mergedProfile.Decoded(resolver)
+	if err != nil {
+		return nil, err
+	}
+	mergedProfile, err = mergedProfile.Denormalized()
+	if err != nil {
+		return nil, errors.Wrap(err, "denormalize addresses")
+	}
+
+	e := &exporter{
+		mergedProfile: mergedProfile,
+		dict: &ProfilesDictionary{
+			MappingTable:   []*Mapping{{}},
+			LocationTable:  []*Location{{}},
+			FunctionTable:  []*Function{{}},
+			LinkTable:      []*Link{{}},
+			AttributeTable: []*KeyValueAndUnit{{}},
+			StackTable:     []*Stack{{}},
+		},
+		stringIdx:    make(map[string]int32),
+		attributeIdx: make(map[string]int32),
+		stackIdx:     make(map[string]int32),
+	}
+
+	// string indexes of mergedProfile stay valid
+	e.dict.StringTable = append(e.dict.StringTable, mergedProfile.StringTable...)
+	if len(e.dict.StringTable) == 0 || e.dict.StringTable[0] != "" {
+		return nil, errors.New("string table must start with empty string")
+	}
+	for i, s := range e.dict.StringTable {
+		if _, ok := e.stringIdx[s]; !ok {
+			e.stringIdx[s] = int32(i)
+		}
+	}
+
+	e.exportMappings()
+	e.exportLocations()
+	e.exportFunctions()
+
+	profiles, err := e.exportProfiles()
+	if err != nil {
+		return nil, err
+	}
+
+	return &ProfilesData{
+		ResourceProfiles: []*ResourceProfiles{{
+			Resource: &Resource{},
+			ScopeProfiles: []*ScopeProfiles{{
+				Scope:    &InstrumentationScope{Name: scopeName},
+				Profiles: profiles,
+			}},
+		}},
+		Dictionary: e.dict,
+	}, nil
+}
+
+func (e *exporter) exportMappings() {
+	for _, m := range e.mergedProfile.Mappings {
+		mapping := &Mapping{
+			MemoryStart:      Uint64(m.MemoryStart),
+			MemoryLimit:      Uint64(m.MemoryLimit),
+			FileOffset:       Uint64(m.FileOffset),
+			FilenameStrindex: int32(m.Filename),
+		}
+
+		if buildID := e.mergedProfile.StringTable[m.BuildId]; buildID != "" {
+			key := buildIDGNUAttr
+			if strings.Contains(buildID, "/") {
+				// go build ids consist of several slash separated hashes
+				key = buildIDGoAttr
+			}
+			mapping.AttributeIndices = append(mapping.AttributeIndices, e.putAttribute(key, &AnyValue{StringValue: &buildID}, 0))
+		}
+
+		for _, flag := range []struct {
+			key string
+			set bool
+		}{
+			{hasFunctionsAttr, m.HasFunctions},
+			{hasFilenamesAttr, m.HasFilenames},
+			{hasLineNumbersAttr, m.HasLineNumbers},
+			{hasInlineFramesAttr, m.HasInlineFrames},
+		} {
+			if flag.set {
+				mapping.AttributeIndices = append(mapping.AttributeIndices, e.putAttribute(flag.key, boolValue(true), 0))
+			}
+		}
+
+		e.dict.MappingTable = append(e.dict.MappingTable, mapping)
+	}
+}
+
+func (e *exporter) exportLocations() {
+	for _, loc := range e.mergedProfile.Locations {
+		location := &Location{
+			MappingIndex: int32(loc.MappingId),
+			Address:      Uint64(loc.Address),
+			Lines:        make([]*Line, 0, len(loc.Line)),
+		}
+		for _, line := range loc.Line {
+			location.Lines = append(location.Lines, &Line{
+				FunctionIndex: int32(line.FunctionId),
+				Line:          Int64(line.Line),
+			})
+		}
+		if loc.IsFolded {
+			location.AttributeIndices = append(location.AttributeIndices, e.putAttribute(isFoldedAttr, boolValue(true), 0))
+		}
+		e.dict.LocationTable = append(e.dict.LocationTable, location)
+	}
+}
+
+func (e *exporter) exportFunctions() {
+	for _, fn := range e.mergedProfile.Functions {
+		e.dict.FunctionTable = append(e.dict.FunctionTable, &Function{
+			NameStrindex:       int32(fn.Name),
+			SystemNameStrindex: int32(fn.SystemName),
+			FilenameStrindex:   int32(fn.Filename),
+			StartLine:          Int64(fn.StartLine),
+		})
+	}
+}
+
+func (e *exporter) exportProfiles() ([]*Profile, error) {
+	mp := e.mergedProfile
+
+	var (
+		profiles         []*Profile
+		sampleOffset     uint64
+		sampleTypeOffset uint64
+	)
+	for idx, numSamples := range mp.NumSamples {
+		if idx >= len(mp.NumSampleTypes) || idx >= len(mp.TimesNanos) || idx >= len(mp.DurationsNanos) ||
+			idx >= len(mp.Periods) || idx*2+1 >= len(mp.PeriodTypes) {
+			return nil, errors.Errorf("entry %d: incomplete metadata", idx)
+		}
+
+		samples := make([]*Sample, 0, numSamples)
+		for i := sampleOffset; i < sampleOffset+numSamples; i++ {
+			if i >= uint64(len(mp.Samples)) {
+				return nil, errors.Errorf("entry %d: sample %d out of range", idx, i)
+			}
+			samples = append(samples, &Sample{
+				StackIndex:       e.putStack(mp.Samples[i].LocationId),
+				AttributeIndices: e.exportLabels(mp.Labels[i]),
+			})
+		}
+
+		var profileID ProfileID
+		binary.BigEndian.PutUint64(profileID[:8], uint64(mp.TimesNanos[idx]))
+		binary.BigEndian.PutUint64(profileID[8:], uint64(idx)+1)
+
+		numSampleTypes := mp.NumSampleTypes[idx]
+		if numSampleTypes == 0 {
+			profiles = append(profiles, &Profile{
+				Samples:      samples,
+				TimeUnixNano: Uint64(mp.TimesNanos[idx]),
+				DurationNano: Uint64(mp.DurationsNanos[idx]),
+				PeriodType: &ValueType{
+					TypeStrindex: int32(mp.PeriodTypes[idx*2]),
+					UnitStrindex: int32(mp.PeriodTypes[idx*2+1]),
+				},
+				Period:    Int64(mp.Periods[idx]),
+				ProfileID: profileID,
+			})
+		}
+		for j := uint64(0); j < numSampleTypes; j++ {
+			p := &Profile{
+				SampleType: &ValueType{
+					TypeStrindex: int32(mp.SampleType[(sampleTypeOffset+j)*2]),
+					UnitStrindex: int32(mp.SampleType[(sampleTypeOffset+j)*2+1]),
+				},
+				Samples:      make([]*Sample, len(samples)),
+				TimeUnixNano: Uint64(mp.TimesNanos[idx]),
+				DurationNano: Uint64(mp.DurationsNanos[idx]),
+				PeriodType: &ValueType{
+					TypeStrindex: int32(mp.PeriodTypes[idx*2]),
+					UnitStrindex: int32(mp.PeriodTypes[idx*2+1]),
+				},
+				Period:    Int64(mp.Periods[idx]),
+				ProfileID: profileID,
+			}
+
+			for i, s := range samples {
+				value := mp.Samples[sampleOffset+uint64(i)].Value[j]
+				p.Samples[i] = &Sample{
+					StackIndex:       s.StackIndex,
+					Values:           []Int64{Int64(value)},
+					AttributeIndices: s.AttributeIndices,
+				}
+			}
+
+			profiles = append(profiles, p)
+		}
+
+		sampleOffset += numSamples
+		sampleTypeOffset += numSampleTypes
+	}
+
+	return profiles, nil
+}
+
+func (e *exporter) exportLabels(labels *profile.Labels) []int32 {
+	if labels == nil {
+		return nil
+	}
+
+	indices := make([]int32, 0, len(labels.Labels))
+	for _, label := range labels.Labels {
+		key := e.mergedProfile.StringTable[label.Key]
+		if label.Str > 0 {
+			value := e.mergedProfile.StringTable[label.Str]
+			indices = append(indices, e.putAttribute(key, &AnyValue{StringValue: &value}, 0))
+			continue
+		}
+		num := Int64(label.Num)
+		indices = append(indices, e.putAttribute(key, &AnyValue{IntValue: &num}, int32(label.NumUnit)))
+	}
+	return indices
+}
+
+func (e *exporter) putString(s string) int32 {
+	if idx, ok := e.stringIdx[s]; ok {
+		return idx
+	}
+	idx := int32(len(e.dict.StringTable))
+	e.dict.StringTable = append(e.dict.StringTable, s)
+	e.stringIdx[s] = idx
+	return idx
+}
+
+func (e *exporter) putAttribute(key string, value *AnyValue, unit int32) int32 {
+	keyIdx := e.putString(key)
+
+	var sb strings.Builder
+	sb.WriteString(strconv.Itoa(int(keyIdx)) + "|" + strconv.Itoa(int(unit)) + "|")
+	switch {
+	case value.StringValue != nil:
+		sb.WriteString("s" + *value.StringValue)
+	case value.BoolValue != nil:
+		sb.WriteString("b" + strconv.FormatBool(*value.BoolValue))
+	case value.IntValue != nil:
+		sb.WriteString("i" + strconv.FormatInt(int64(*value.IntValue), 10))
+	}
+
+	if idx, ok := e.attributeIdx[sb.String()]; ok {
+		return idx
+	}
+
+	idx := int32(len(e.dict.AttributeTable))
+	e.dict.AttributeTable = append(e.dict.AttributeTable, &KeyValueAndUnit{
+		KeyStrindex:  keyIdx,
+		Value:        value,
+		UnitStrindex: unit,
+	})
+	e.attributeIdx[sb.String()] = idx
+	return idx
+}
+
+func (e *exporter) putStack(locationIDs []int64) int32 {
+	ids := make([]string, len(locationIDs))
+	for i, id := range locationIDs {
+		ids[i] = strconv.FormatInt(id, 16)
+	}
+	key := strings.Join(ids, "|")
+
+	if idx, ok := e.stackIdx[key]; ok {
+		return idx
+	}
+
+	stack := &Stack{LocationIndices: make([]int32, len(locationIDs))}
+	for i, id := range locationIDs {
+		stack.LocationIndices[i] = int32(id)
+	}
+
+	idx := int32(len(e.dict.StackTable))
+	e.dict.StackTable = append(e.dict.StackTable, stack)
+	e.stackIdx[key] = idx
+	return idx
+}
+
+func boolValue(v bool) *AnyValue {
+	return &AnyValue{BoolValue: &v}
+}
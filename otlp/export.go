@@ -0,0 +1,48 @@
+package otlp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ProfilesPath is the path of OTLP/HTTP endpoint accepting profiles
+const ProfilesPath = "/v1development/profiles"
+
+// Export sends pd to OTLP/HTTP collector at endpoint (e.g. http://localhost:4318) as OTLP/JSON.
+// http.DefaultClient is used if client is nil.
+func Export(ctx context.Context, client *http.Client, endpoint string, pd *ProfilesData) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	body, err := json.Marshal(pd)
+	if err != nil {
+		return errors.Wrap(err, "marshal profiles")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(endpoint, "/")+ProfilesPath, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return errors.Errorf("unexpected status %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	return nil
+}
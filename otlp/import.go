@@ -0,0 +1,349 @@
+package otlp
+
+import (
+	"github.com/pkg/errors"
+	"github.com/threadedstream/ppmerge"
+	"github.com/threadedstream/ppmerge/profile"
+)
+
+// importer converts ProfilesData to MergedProfile
+type importer struct {
+	dict          *ProfilesDictionary
+	mergedProfile *ppmerge.MergedProfile
+
+	stringIdx map[string]int64
+}
+
+// ToMergedProfile converts profiles data back to MergedProfile. Consecutive profiles
+// sharing ProfileID are joined into a single entry with several sample types, they
+// must list the same samples in the same order. Profile without sample type becomes
+// an entry without sample types, its samples must have no values.
+//
+// NumFunctions, NumLocations and NumMappings of every entry hold number of distinct
+// functions, locations and mappings referenced by its samples, as OTLP doesn't keep
+// the original counts.
+func ToMergedProfile(pd *ProfilesData) (*ppmerge.MergedProfile, error) {
+	if pd.Dictionary == nil {
+		return nil, errors.New("missing dictionary")
+	}
+
+	im := &importer{
+		dict: pd.Dictionary,
+		mergedProfile: &ppmerge.MergedProfile{
+			Labels: make(map[uint64]*profile.Labels),
+		},
+		stringIdx: make(map[string]int64),
+	}
+
+	im.mergedProfile.StringTable = append(im.mergedProfile.StringTable, pd.Dictionary.StringTable...)
+	if len(im.mergedProfile.StringTable) == 0 || im.mergedProfile.StringTable[0] != "" {
+		return nil, errors.New("string table must start with empty string")
+	}
+	for i, s := range im.mergedProfile.StringTable {
+		if _, ok := im.stringIdx[s]; !ok {
+			im.stringIdx[s] = int64(i)
+		}
+	}
+
+	if err := im.importMappings(); err != nil {
+		return nil, err
+	}
+	if err := im.importLocations(); err != nil {
+		return nil, err
+	}
+	if err := im.importFunctions(); err != nil {
+		return nil, err
+	}
+
+	var profiles []*Profile
+	for _, rp := range pd.ResourceProfiles {
+		for _, sp := range rp.ScopeProfiles {
+			profiles = append(profiles, sp.Profiles...)
+		}
+	}
+
+	for len(profiles) > 0 {
+		n := 1
+		for n < len(profiles) && profiles[n].ProfileID == profiles[0].ProfileID {
+			n++
+		}
+		if err := im.importEntry(profiles[:n]); err != nil {
+			return nil, errors.Wrapf(err, "entry %d", len(im.mergedProfile.NumSamples))
+		}
+		profiles = profiles[n:]
+	}
+
+	return im.mergedProfile, nil
+}
+
+func (im *importer) importMappings() error {
+	for i, m := range im.dict.MappingTable {
+		if i == 0 {
+			continue
+		}
+		if err := im.checkString(m.FilenameStrindex); err != nil {
+			return errors.Wrapf(err, "mapping %d", i)
+		}
+
+		mapping := &ppmerge.MergeMapping{
+			Id:          uint64(i),
+			MemoryStart: uint64(m.MemoryStart),
+			MemoryLimit: uint64(m.MemoryLimit),
+			FileOffset:  uint64(m.FileOffset),
+			Filename:    int64(m.FilenameStrindex),
+		}
+
+		for _, attrIdx := range m.AttributeIndices {
+			attr, err := im.attribute(attrIdx)
+			if err != nil {
+				return errors.Wrapf(err, "mapping %d", i)
+			}
+			switch im.mergedProfile.StringTable[attr.KeyStrindex] {
+			case buildIDGNUAttr, buildIDGoAttr:
+				if attr.Value.StringValue != nil {
+					mapping.BuildId = im.putString(*attr.Value.StringValue)
+				}
+			case hasFunctionsAttr:
+				mapping.HasFunctions = isTrue(attr.Value)
+			case hasFilenamesAttr:
+				mapping.HasFilenames = isTrue(attr.Value)
+			case hasLineNumbersAttr:
+				mapping.HasLineNumbers = isTrue(attr.Value)
+			case hasInlineFramesAttr:
+				mapping.HasInlineFrames = isTrue(attr.Value)
+			}
+		}
+
+		im.mergedProfile.Mappings = append(im.mergedProfile.Mappings, mapping)
+	}
+	return nil
+}
+
+func (im *importer) importLocations() error {
+	for i, loc := range im.dict.LocationTable {
+		if i == 0 {
+			continue
+		}
+		if loc.MappingIndex < 0 || int(loc.MappingIndex) >= len(im.dict.MappingTable) {
+			return errors.Errorf("location %d: mapping index %d out of range", i, loc.MappingIndex)
+		}
+
+		location := &ppmerge.MergeLocation{
+			Id:        uint64(i),
+			MappingId: uint64(loc.MappingIndex),
+			Address:   uint64(loc.Address),
+			Line:      make([]*ppmerge.MergeLine, 0, len(loc.Lines)),
+		}
+		for _, line := range loc.Lines {
+			if line.FunctionIndex < 0 || int(line.FunctionIndex) >= len(im.dict.FunctionTable) {
+				return errors.Errorf("location %d: function index %d out of range", i, line.FunctionIndex)
+			}
+			location.Line = append(location.Line, &ppmerge.MergeLine{
+				FunctionId: uint64(line.FunctionIndex),
+				Line:       int64(line.Line),
+			})
+		}
+
+		for _, attrIdx := range loc.AttributeIndices {
+			attr, err := im.attribute(attrIdx)
+			if err != nil {
+				return errors.Wrapf(err, "location %d", i)
+			}
+			if im.mergedProfile.StringTable[attr.KeyStrindex] == isFoldedAttr {
+				location.IsFolded = isTrue(attr.Value)
+			}
+		}
+
+		im.mergedProfile.Locations = append(im.mergedProfile.Locations, location)
+	}
+	return nil
+}
+
+func (im *importer) importFunctions() error {
+	for i, fn := range im.dict.FunctionTable {
+		if i == 0 {
+			continue
+		}
+		for _, idx := range []int32{fn.NameStrindex, fn.SystemNameStrindex, fn.FilenameStrindex} {
+			if err := im.checkString(idx); err != nil {
+				return errors.Wrapf(err, "function %d", i)
+			}
+		}
+
+		im.mergedProfile.Functions = append(im.mergedProfile.Functions, &ppmerge.MergeFunction{
+			Id:         uint64(i),
+			Name:       int64(fn.NameStrindex),
+			SystemName: int64(fn.SystemNameStrindex),
+			Filename:   int64(fn.FilenameStrindex),
+			StartLine:  int64(fn.StartLine),
+		})
+	}
+	return nil
+}
+
+// importEntry joins profiles of a single pprof profile into the next entry
+func (im *importer) importEntry(profiles []*Profile) error {
+	mp := im.mergedProfile
+	first := profiles[0]
+
+	numSampleTypes := len(profiles)
+	if first.SampleType == nil && len(profiles) == 1 {
+		// entry having no sample types
+		numSampleTypes, profiles = 0, nil
+	}
+
+	for i, p := range profiles {
+		if len(p.Samples) != len(first.Samples) {
+			return errors.Errorf("profile %d has %d samples, expected %d", i, len(p.Samples), len(first.Samples))
+		}
+		if p.SampleType == nil {
+			return errors.Errorf("profile %d has no sample type", i)
+		}
+		if err := im.checkValueType(p.SampleType); err != nil {
+			return errors.Wrapf(err, "profile %d", i)
+		}
+		mp.SampleType = append(mp.SampleType, int64(p.SampleType.TypeStrindex), int64(p.SampleType.UnitStrindex))
+	}
+
+	periodType := first.PeriodType
+	if periodType == nil {
+		periodType = &ValueType{}
+	}
+	if err := im.checkValueType(periodType); err != nil {
+		return errors.Wrap(err, "period type")
+	}
+
+	var (
+		functions = make(map[uint64]struct{})
+		locations = make(map[uint64]struct{})
+		mappings  = make(map[uint64]struct{})
+	)
+	for i, s := range first.Samples {
+		if s.StackIndex < 0 || int(s.StackIndex) >= len(im.dict.StackTable) {
+			return errors.Errorf("sample %d: stack index %d out of range", i, s.StackIndex)
+		}
+
+		sample := &ppmerge.MergeSample{
+			LocationId: make([]int64, 0, len(im.dict.StackTable[s.StackIndex].LocationIndices)),
+			Value:      make([]int64, 0, numSampleTypes),
+		}
+		for _, locIdx := range im.dict.StackTable[s.StackIndex].LocationIndices {
+			if locIdx <= 0 || int(locIdx) >= len(im.dict.LocationTable) {
+				return errors.Errorf("sample %d: location index %d out of range", i, locIdx)
+			}
+			sample.LocationId = append(sample.LocationId, int64(locIdx))
+
+			loc := mp.Locations[locIdx-1]
+			locations[loc.Id] = struct{}{}
+			if loc.MappingId > 0 {
+				mappings[loc.MappingId] = struct{}{}
+			}
+			for _, line := range loc.Line {
+				functions[line.FunctionId] = struct{}{}
+			}
+		}
+
+		if numSampleTypes == 0 && len(s.Values) > 0 {
+			return errors.Errorf("sample %d has values, but profile has no sample type", i)
+		}
+		for j, p := range profiles {
+			other := p.Samples[i]
+			if other.StackIndex != s.StackIndex || len(other.Values) != 1 {
+				return errors.Errorf("sample %d of profile %d doesn't match the first profile", i, j)
+			}
+			sample.Value = append(sample.Value, int64(other.Values[0]))
+		}
+
+		if len(s.AttributeIndices) > 0 {
+			labels, err := im.importLabels(s.AttributeIndices)
+			if err != nil {
+				return errors.Wrapf(err, "sample %d", i)
+			}
+			mp.Labels[uint64(len(mp.Samples))] = labels
+		}
+
+		mp.Samples = append(mp.Samples, sample)
+	}
+
+	mp.NumSamples = append(mp.NumSamples, uint64(len(first.Samples)))
+	mp.NumSampleTypes = append(mp.NumSampleTypes, uint64(numSampleTypes))
+	mp.NumFunctions = append(mp.NumFunctions, uint64(len(functions)))
+	mp.NumLocations = append(mp.NumLocations, uint64(len(locations)))
+	mp.NumMappings = append(mp.NumMappings, uint64(len(mappings)))
+	mp.PeriodTypes = append(mp.PeriodTypes, int64(periodType.TypeStrindex), int64(periodType.UnitStrindex))
+	mp.Periods = append(mp.Periods, int64(first.Period))
+	mp.TimesNanos = append(mp.TimesNanos, int64(first.TimeUnixNano))
+	mp.DurationsNanos = append(mp.DurationsNanos, int64(first.DurationNano))
+
+	return nil
+}
+
+func (im *importer) importLabels(attributeIndices []int32) (*profile.Labels, error) {
+	labels := &profile.Labels{
+		Labels: make([]*profile.Label, 0, len(attributeIndices)),
+	}
+	for _, attrIdx := range attributeIndices {
+		attr, err := im.attribute(attrIdx)
+		if err != nil {
+			return nil, err
+		}
+
+		label := &profile.Label{Key: int64(attr.KeyStrindex)}
+		switch v := attr.Value; {
+		case v.StringValue != nil:
+			label.Str = im.putString(*v.StringValue)
+		case v.IntValue != nil:
+			label.Num = int64(*v.IntValue)
+			label.NumUnit = int64(attr.UnitStrindex)
+		default:
+			return nil, errors.Errorf("attribute %d: unsupported label value", attrIdx)
+		}
+		labels.Labels = append(labels.Labels, label)
+	}
+	return labels, nil
+}
+
+func (im *importer) attribute(idx int32) (*KeyValueAndUnit, error) {
+	if idx <= 0 || int(idx) >= len(im.dict.AttributeTable) {
+		return nil, errors.Errorf("attribute index %d out of range", idx)
+	}
+	attr := im.dict.AttributeTable[idx]
+	if err := im.checkString(attr.KeyStrindex); err != nil {
+		return nil, err
+	}
+	if err := im.checkString(attr.UnitStrindex); err != nil {
+		return nil, err
+	}
+	if attr.Value == nil {
+		return nil, errors.Errorf("attribute %d has no value", idx)
+	}
+	return attr, nil
+}
+
+func (im *importer) checkValueType(vt *ValueType) error {
+	if err := im.checkString(vt.TypeStrindex); err != nil {
+		return err
+	}
+	return im.checkString(vt.UnitStrindex)
+}
+
+func (im *importer) checkString(idx int32) error {
+	if idx < 0 || int(idx) >= len(im.dict.StringTable) {
+		return errors.Errorf("string index %d out of range", idx)
+	}
+	return nil
+}
+
+func (im *importer) putString(s string) int64 {
+	if idx, ok := im.stringIdx[s]; ok {
+		return idx
+	}
+	idx := int64(len(im.mergedProfile.StringTable))
+	im.mergedProfile.StringTable = append(im.mergedProfile.StringTable, s)
+	im.stringIdx[s] = idx
+	return idx
+}
+
+func isTrue(v *AnyValue) bool {
+	return v.BoolValue != nil && *v.BoolValue
+}
@@ -0,0 +1,193 @@
+// Package otlp converts merged profiles to and from the OpenTelemetry profiles
+// signal. Types of this package mirror messages of
+// opentelemetry/proto/profiles/v1development/profiles.proto as of OTLP v1.9.0
+// and are encoded in OTLP/JSON format.
+package otlp
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// ProfilesData is the top-level message of profiles signal. Profiles of all
+// resources share the same dictionary.
+type ProfilesData struct {
+	ResourceProfiles []*ResourceProfiles `json:"resourceProfiles"`
+	Dictionary       *ProfilesDictionary `json:"dictionary"`
+}
+
+// ProfilesDictionary holds lookup tables referenced by profiles. The first entry
+// of every table is the zero value, so that index 0 means unset.
+type ProfilesDictionary struct {
+	MappingTable   []*Mapping         `json:"mappingTable"`
+	LocationTable  []*Location        `json:"locationTable"`
+	FunctionTable  []*Function        `json:"functionTable"`
+	LinkTable      []*Link            `json:"linkTable"`
+	StringTable    []string           `json:"stringTable"`
+	AttributeTable []*KeyValueAndUnit `json:"attributeTable"`
+	StackTable     []*Stack           `json:"stackTable"`
+}
+
+type ResourceProfiles struct {
+	Resource      *Resource        `json:"resource,omitempty"`
+	ScopeProfiles []*ScopeProfiles `json:"scopeProfiles"`
+	SchemaURL     string           `json:"schemaUrl,omitempty"`
+}
+
+type Resource struct {
+	Attributes []*KeyValue `json:"attributes,omitempty"`
+}
+
+type ScopeProfiles struct {
+	Scope     *InstrumentationScope `json:"scope,omitempty"`
+	Profiles  []*Profile            `json:"profiles"`
+	SchemaURL string                `json:"schemaUrl,omitempty"`
+}
+
+type InstrumentationScope struct {
+	Name    string `json:"name,omitempty"`
+	Version string `json:"version,omitempty"`
+}
+
+// Profile holds values of a single sample type. A pprof profile with several
+// sample types is represented by several profiles sharing ProfileID.
+type Profile struct {
+	SampleType       *ValueType `json:"sampleType"`
+	Samples          []*Sample  `json:"samples"`
+	TimeUnixNano     Uint64     `json:"timeUnixNano,omitempty"`
+	DurationNano     Uint64     `json:"durationNano,omitempty"`
+	PeriodType       *ValueType `json:"periodType,omitempty"`
+	Period           Int64      `json:"period,omitempty"`
+	ProfileID        ProfileID  `json:"profileId"`
+	AttributeIndices []int32    `json:"attributeIndices,omitempty"`
+}
+
+type ValueType struct {
+	TypeStrindex int32 `json:"typeStrindex,omitempty"`
+	UnitStrindex int32 `json:"unitStrindex,omitempty"`
+}
+
+type Sample struct {
+	StackIndex       int32   `json:"stackIndex,omitempty"`
+	Values           []Int64 `json:"values"`
+	AttributeIndices []int32 `json:"attributeIndices,omitempty"`
+	LinkIndex        int32   `json:"linkIndex,omitempty"`
+}
+
+type Stack struct {
+	LocationIndices []int32 `json:"locationIndices,omitempty"`
+}
+
+type Mapping struct {
+	MemoryStart      Uint64  `json:"memoryStart,omitempty"`
+	MemoryLimit      Uint64  `json:"memoryLimit,omitempty"`
+	FileOffset       Uint64  `json:"fileOffset,omitempty"`
+	FilenameStrindex int32   `json:"filenameStrindex,omitempty"`
+	AttributeIndices []int32 `json:"attributeIndices,omitempty"`
+}
+
+type Location struct {
+	MappingIndex     int32   `json:"mappingIndex,omitempty"`
+	Address          Uint64  `json:"address,omitempty"`
+	Lines            []*Line `json:"lines,omitempty"`
+	AttributeIndices []int32 `json:"attributeIndices,omitempty"`
+}
+
+type Line struct {
+	FunctionIndex int32 `json:"functionIndex,omitempty"`
+	Line          Int64 `json:"line,omitempty"`
+	Column        Int64 `json:"column,omitempty"`
+}
+
+type Function struct {
+	NameStrindex       int32 `json:"nameStrindex,omitempty"`
+	SystemNameStrindex int32 `json:"systemNameStrindex,omitempty"`
+	FilenameStrindex   int32 `json:"filenameStrindex,omitempty"`
+	StartLine          Int64 `json:"startLine,omitempty"`
+}
+
+type Link struct {
+	TraceID string `json:"traceId,omitempty"`
+	SpanID  string `json:"spanId,omitempty"`
+}
+
+type KeyValueAndUnit struct {
+	KeyStrindex  int32     `json:"keyStrindex,omitempty"`
+	Value        *AnyValue `json:"value,omitempty"`
+	UnitStrindex int32     `json:"unitStrindex,omitempty"`
+}
+
+type KeyValue struct {
+	Key   string    `json:"key"`
+	Value *AnyValue `json:"value,omitempty"`
+}
+
+// AnyValue holds exactly one of its fields
+type AnyValue struct {
+	StringValue *string `json:"stringValue,omitempty"`
+	BoolValue   *bool   `json:"boolValue,omitempty"`
+	IntValue    *Int64  `json:"intValue,omitempty"`
+}
+
+// Int64 is encoded as a decimal string as required by OTLP/JSON
+type Int64 int64
+
+func (i Int64) MarshalJSON() ([]byte, error) {
+	return json.Marshal(strconv.FormatInt(int64(i), 10))
+}
+
+func (i *Int64) UnmarshalJSON(data []byte) error {
+	v, err := unmarshalInteger(data, 64, true)
+	*i = Int64(v)
+	return err
+}
+
+// Uint64 is encoded as a decimal string as required by OTLP/JSON
+type Uint64 uint64
+
+func (u Uint64) MarshalJSON() ([]byte, error) {
+	return json.Marshal(strconv.FormatUint(uint64(u), 10))
+}
+
+func (u *Uint64) UnmarshalJSON(data []byte) error {
+	v, err := unmarshalInteger(data, 64, false)
+	*u = Uint64(v)
+	return err
+}
+
+// unmarshalInteger accepts both quoted and plain integers, as OTLP/JSON receivers must
+func unmarshalInteger(data []byte, bitSize int, signed bool) (uint64, error) {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		s = string(data)
+	}
+	if signed {
+		v, err := strconv.ParseInt(s, 10, bitSize)
+		return uint64(v), errors.Wrapf(err, "invalid integer %s", data)
+	}
+	v, err := strconv.ParseUint(s, 10, bitSize)
+	return v, errors.Wrapf(err, "invalid integer %s", data)
+}
+
+// ProfileID is encoded as a hex string as required by OTLP/JSON
+type ProfileID [16]byte
+
+func (id ProfileID) MarshalJSON() ([]byte, error) {
+	return json.Marshal(hex.EncodeToString(id[:]))
+}
+
+func (id *ProfileID) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) != len(id) {
+		return errors.Errorf("invalid profile id %q", s)
+	}
+	copy(id[:], b)
+	return nil
+}
@@ -0,0 +1,218 @@
+package otlp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/threadedstream/ppmerge"
+	"github.com/threadedstream/ppmerge/profile"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+func TestFromMergedProfileGolden(t *testing.T) {
+	for name, paths := range map[string][]string{
+		"hprof":  {"hprof1", "hprof2"},
+		"labels": {"labels.prof"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			mergedProfile := ppmerge.NewProfileMerger().Merge(getProfiles(t, paths...)...)
+
+			pd, err := FromMergedProfile(mergedProfile, nil)
+			require.NoError(t, err)
+
+			actual, err := json.MarshalIndent(pd, "", "  ")
+			require.NoError(t, err)
+
+			golden := filepath.Join("testdata", name+".json")
+			if *update {
+				require.NoError(t, os.WriteFile(golden, actual, 0644))
+			}
+
+			expected, err := os.ReadFile(golden)
+			require.NoError(t, err)
+			require.JSONEq(t, string(expected), string(actual))
+
+			// golden file must be importable on its own
+			var decoded ProfilesData
+			require.NoError(t, json.Unmarshal(expected, &decoded))
+			_, err = ToMergedProfile(&decoded)
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	mergedProfile := ppmerge.NewProfileMerger().Merge(getProfiles(t, "hprof1", "hprof2", "parca_cpu", "labels.prof", "multilabels.prof")...)
+
+	pd, err := FromMergedProfile(mergedProfile, nil)
+	require.NoError(t, err)
+	require.Len(t, pd.ResourceProfiles[0].ScopeProfiles[0].Profiles, 4+4+2+2+2)
+
+	data, err := json.Marshal(pd)
+	require.NoError(t, err)
+	var decoded ProfilesData
+	require.NoError(t, json.Unmarshal(data, &decoded))
+
+	imported, err := ToMergedProfile(&decoded)
+	require.NoError(t, err)
+	require.Len(t, imported.NumSamples, len(mergedProfile.NumSamples))
+
+	expectedUnpacker := ppmerge.NewProfileUnPacker(mergedProfile)
+	actualUnpacker := ppmerge.NewProfileUnPacker(imported)
+	for idx := range mergedProfile.NumSamples {
+		expected, err := expectedUnpacker.Unpack(uint64(idx))
+		require.NoError(t, err)
+		actual, err := actualUnpacker.Unpack(uint64(idx))
+		require.NoError(t, err)
+		require.Equal(t, expected.String(), actual.String())
+	}
+}
+
+func TestRoundTripWithoutSampleTypes(t *testing.T) {
+	profiles := getProfiles(t, "hprof1", "hprof2", "hprof3")
+	profiles[1].SampleType = nil
+	for _, s := range profiles[1].Sample {
+		s.Value = nil
+	}
+	mergedProfile := ppmerge.NewProfileMerger().Merge(profiles...)
+	require.Equal(t, uint64(0), mergedProfile.NumSampleTypes[1])
+
+	pd, err := FromMergedProfile(mergedProfile, nil)
+	require.NoError(t, err)
+	require.Len(t, pd.ResourceProfiles[0].ScopeProfiles[0].Profiles, 4+1+4)
+
+	imported, err := ToMergedProfile(pd)
+	require.NoError(t, err)
+	require.Equal(t, mergedProfile.NumSampleTypes, imported.NumSampleTypes)
+
+	expectedUnpacker := ppmerge.NewProfileUnPacker(mergedProfile)
+	actualUnpacker := ppmerge.NewProfileUnPacker(imported)
+	for idx := range profiles {
+		expected, err := expectedUnpacker.Unpack(uint64(idx))
+		require.NoError(t, err)
+		actual, err := actualUnpacker.Unpack(uint64(idx))
+		require.NoError(t, err)
+		require.Equal(t, expected.String(), actual.String())
+	}
+}
+
+func TestFromMergedProfileDictionary(t *testing.T) {
+	profiles := getProfiles(t, "hprof1", "hprof2")
+	mergedProfile := ppmerge.NewProfileMerger().Merge(profiles...)
+	dict, err := ppmerge.NewSymbolDictionary(mergedProfile)
+	require.NoError(t, err)
+
+	profileMerger := ppmerge.NewProfileMerger(ppmerge.WithDictionary(dict))
+	profileMerger.Merge(profiles...)
+	var buf bytes.Buffer
+	require.NoError(t, profileMerger.WriteUncompressed(&buf))
+	archive := new(ppmerge.MergedProfile)
+	require.NoError(t, archive.UnmarshalVT(buf.Bytes()))
+	require.NotEmpty(t, archive.Dictionary)
+
+	_, err = FromMergedProfile(archive, nil)
+	require.Error(t, err)
+
+	resolver := ppmerge.DictionaryResolverFunc(func(key string) (*ppmerge.SymbolDictionary, error) {
+		require.Equal(t, dict.Key, key)
+		return dict, nil
+	})
+	pd, err := FromMergedProfile(archive, resolver)
+	require.NoError(t, err)
+	imported, err := ToMergedProfile(pd)
+	require.NoError(t, err)
+
+	expectedUnpacker := ppmerge.NewProfileUnPacker(mergedProfile)
+	actualUnpacker := ppmerge.NewProfileUnPacker(imported)
+	for idx := range profiles {
+		expected, err := expectedUnpacker.Unpack(uint64(idx))
+		require.NoError(t, err)
+		actual, err := actualUnpacker.Unpack(uint64(idx))
+		require.NoError(t, err)
+		require.Equal(t, expected.String(), actual.String())
+	}
+}
+
+func TestToMergedProfileInvalid(t *testing.T) {
+	mergedProfile := ppmerge.NewProfileMerger().Merge(getProfiles(t, "hprof1")...)
+	pd, err := FromMergedProfile(mergedProfile, nil)
+	require.NoError(t, err)
+
+	pd.ResourceProfiles[0].ScopeProfiles[0].Profiles[0].Samples[0].StackIndex = int32(len(pd.Dictionary.StackTable))
+	_, err = ToMergedProfile(pd)
+	require.Error(t, err)
+
+	_, err = ToMergedProfile(&ProfilesData{})
+	require.Error(t, err)
+}
+
+func TestExport(t *testing.T) {
+	var received ProfilesData
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != ProfilesPath || r.Header.Get("Content-Type") != "application/json" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil || json.Unmarshal(body, &received) != nil {
+			http.Error(w, "malformed body", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte("{}"))
+	}))
+	defer srv.Close()
+
+	mergedProfile := ppmerge.NewProfileMerger().Merge(getProfiles(t, "hprof1", "hprof2")...)
+	pd, err := FromMergedProfile(mergedProfile, nil)
+	require.NoError(t, err)
+
+	require.NoError(t, Export(context.Background(), srv.Client(), srv.URL, pd))
+
+	imported, err := ToMergedProfile(&received)
+	require.NoError(t, err)
+	require.Equal(t, mergedProfile.NumSamples, imported.NumSamples)
+	require.Equal(t, mergedProfile.Samples, imported.Samples)
+
+	err = Export(context.Background(), srv.Client(), srv.URL+"/unknown", pd)
+	require.ErrorContains(t, err, "400")
+}
+
+func TestInt64JSON(t *testing.T) {
+	var v struct {
+		A Int64  `json:"a"`
+		B Uint64 `json:"b"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(`{"a":"-12","b":34}`), &v))
+	require.Equal(t, Int64(-12), v.A)
+	require.Equal(t, Uint64(34), v.B)
+
+	data, err := json.Marshal(v)
+	require.NoError(t, err)
+	require.True(t, bytes.Equal([]byte(`{"a":"-12","b":"34"}`), data))
+
+	require.Error(t, json.Unmarshal([]byte(`{"a":"x"}`), &v))
+}
+
+func getProfiles(t *testing.T, paths ...string) []*profile.Profile {
+	var profiles []*profile.Profile
+	for _, path := range paths {
+		file, err := os.Open(filepath.Join("..", "testdata", path))
+		require.NoError(t, err)
+		prof, err := profile.ParseProfile(file)
+		require.NoError(t, err)
+		require.NoError(t, file.Close())
+		profiles = append(profiles, prof)
+	}
+	return profiles
+}
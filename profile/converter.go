@@ -1,9 +1,16 @@
 package profile
 
-import "github.com/google/pprof/profile"
+import (
+	"sort"
 
+	"github.com/google/pprof/profile"
+	"github.com/pkg/errors"
+)
+
+// From fills p with contents of src. Labels are encoded the same way as google/pprof does it:
+// string labels go first, keys are sorted and every value of key becomes a separate label.
 func (p *Profile) From(src *profile.Profile) {
-	m := map[string]uint64{}
+	m := map[string]uint64{"": 0}
 
 	p.convertSamples(src.Sample, m)
 	p.convertFunctions(src.Function, m)
@@ -18,9 +25,12 @@ func (p *Profile) From(src *profile.Profile) {
 		}
 	}
 
-	p.PeriodType = &ValueType{
-		Type: int64(p.putString(src.PeriodType.Type, m)),
-		Unit: int64(p.putString(src.PeriodType.Unit, m)),
+	p.PeriodType = nil
+	if src.PeriodType != nil {
+		p.PeriodType = &ValueType{
+			Type: int64(p.putString(src.PeriodType.Type, m)),
+			Unit: int64(p.putString(src.PeriodType.Unit, m)),
+		}
 	}
 
 	p.Comment = make([]int64, len(src.Comments))
@@ -35,12 +45,129 @@ func (p *Profile) From(src *profile.Profile) {
 	p.TimeNanos = src.TimeNanos
 	p.Period = src.Period
 
-	p.StringTable = make([]string, len(m)+1)
+	p.StringTable = make([]string, len(m))
 	for s, i := range m {
 		p.StringTable[i] = s
 	}
 }
 
+// To converts p to google/pprof profile
+func (p *Profile) To() (*profile.Profile, error) {
+	dst := &profile.Profile{
+		SampleType: make([]*profile.ValueType, len(p.SampleType)),
+		Sample:     make([]*profile.Sample, len(p.Sample)),
+		Mapping:    make([]*profile.Mapping, len(p.Mapping)),
+		Location:   make([]*profile.Location, len(p.Location)),
+		Function:   make([]*profile.Function, len(p.Function)),
+		PeriodType: &profile.ValueType{},
+
+		DropFrames:        p.getString(p.DropFrames),
+		KeepFrames:        p.getString(p.KeepFrames),
+		TimeNanos:         p.TimeNanos,
+		DurationNanos:     p.DurationNanos,
+		Period:            p.Period,
+		DefaultSampleType: p.getString(p.DefaultSampleType),
+	}
+
+	for i, vt := range p.SampleType {
+		dst.SampleType[i] = &profile.ValueType{
+			Type: p.getString(vt.Type),
+			Unit: p.getString(vt.Unit),
+		}
+	}
+	if p.PeriodType != nil {
+		dst.PeriodType.Type = p.getString(p.PeriodType.Type)
+		dst.PeriodType.Unit = p.getString(p.PeriodType.Unit)
+	}
+	for _, c := range p.Comment {
+		dst.Comments = append(dst.Comments, p.getString(c))
+	}
+
+	mappings := make(map[uint64]*profile.Mapping, len(p.Mapping))
+	for i, m := range p.Mapping {
+		dst.Mapping[i] = &profile.Mapping{
+			ID:              m.Id,
+			Start:           m.MemoryStart,
+			Limit:           m.MemoryLimit,
+			Offset:          m.FileOffset,
+			File:            p.getString(m.Filename),
+			BuildID:         p.getString(m.BuildId),
+			HasFunctions:    m.HasFunctions,
+			HasFilenames:    m.HasFilenames,
+			HasLineNumbers:  m.HasLineNumbers,
+			HasInlineFrames: m.HasInlineFrames,
+		}
+		mappings[m.Id] = dst.Mapping[i]
+	}
+
+	functions := make(map[uint64]*profile.Function, len(p.Function))
+	for i, f := range p.Function {
+		dst.Function[i] = &profile.Function{
+			ID:         f.Id,
+			Name:       p.getString(f.Name),
+			SystemName: p.getString(f.SystemName),
+			Filename:   p.getString(f.Filename),
+			StartLine:  f.StartLine,
+		}
+		functions[f.Id] = dst.Function[i]
+	}
+
+	locations := make(map[uint64]*profile.Location, len(p.Location))
+	for i, loc := range p.Location {
+		l := &profile.Location{
+			ID:       loc.Id,
+			Address:  loc.Address,
+			IsFolded: loc.IsFolded,
+		}
+		if loc.MappingId != 0 {
+			if l.Mapping = mappings[loc.MappingId]; l.Mapping == nil {
+				return nil, errors.Errorf("location %d: unknown mapping %d", loc.Id, loc.MappingId)
+			}
+		}
+		if len(loc.Line) > 0 {
+			l.Line = make([]profile.Line, len(loc.Line))
+			for j, line := range loc.Line {
+				l.Line[j].Line = line.Line
+				if line.FunctionId != 0 {
+					if l.Line[j].Function = functions[line.FunctionId]; l.Line[j].Function == nil {
+						return nil, errors.Errorf("location %d: unknown function %d", loc.Id, line.FunctionId)
+					}
+				}
+			}
+		}
+		dst.Location[i] = l
+		locations[loc.Id] = l
+	}
+
+	for i, s := range p.Sample {
+		sample := &profile.Sample{
+			Location: make([]*profile.Location, len(s.LocationId)),
+			Value:    make([]int64, len(s.Value)),
+		}
+		copy(sample.Value, s.Value)
+
+		for j, id := range s.LocationId {
+			if sample.Location[j] = locations[id]; sample.Location[j] == nil {
+				return nil, errors.Errorf("sample %d: unknown location %d", i, id)
+			}
+		}
+
+		ConvertLabels(sample, &Labels{Labels: s.Label}, p.StringTable)
+		dst.Sample[i] = sample
+	}
+
+	return dst, nil
+}
+
+func (p *Profile) getString(idx int64) string {
+	if idx < 0 || idx >= int64(len(p.StringTable)) {
+		return ""
+	}
+	return p.StringTable[idx]
+}
+
+// ConvertLabels sets labels of s, values of repeated keys are appended in order.
+// String labels take precedence over numeric ones, numeric labels without value and unit are skipped.
 func ConvertLabels(s *profile.Sample, labels *Labels, stringTable []string) {
 	for _, label := range labels.GetLabels() {
 		key := stringTable[label.GetKey()]
@@ -48,47 +175,83 @@ func ConvertLabels(s *profile.Sample, labels *Labels, stringTable []string) {
 			if s.Label == nil {
 				s.Label = make(map[string][]string)
 			}
-			s.Label[key] = []string{stringTable[strIdx]}
+			s.Label[key] = append(s.Label[key], stringTable[strIdx])
 		} else if label.GetNum() != 0 || label.GetNumUnit() > 0 {
 			if s.NumLabel == nil {
 				s.NumLabel = make(map[string][]int64)
 				s.NumUnit = make(map[string][]string)
 			}
-			s.NumLabel[key] = []int64{label.GetNum()}
-			s.NumUnit[key] = []string{stringTable[label.GetNumUnit()]}
+			if unitIdx := label.GetNumUnit(); unitIdx > 0 {
+				// units are either absent or present for every value of key
+				units := padStrings(s.NumUnit[key], len(s.NumLabel[key]))
+				s.NumUnit[key] = append(units, stringTable[unitIdx])
+			}
+			s.NumLabel[key] = append(s.NumLabel[key], label.GetNum())
 		}
 	}
+
+	for key, units := range s.NumUnit {
+		s.NumUnit[key] = padStrings(units, len(s.NumLabel[key]))
+	}
+}
+
+// padStrings pads ss with empty strings up to length n
+func padStrings(ss []string, n int) []string {
+	if n <= len(ss) {
+		return ss
+	}
+	return append(ss, make([]string, n-len(ss))...)
 }
 
 func (p *Profile) convertSamples(samples []*profile.Sample, m map[string]uint64) {
 	p.Sample = make([]*Sample, len(samples))
 	for i, sample := range samples {
 		p.Sample[i] = &Sample{
-			Value: make([]int64, len(sample.Value)),
+			LocationId: make([]uint64, len(sample.Location)),
+			Value:      make([]int64, len(sample.Value)),
 		}
 		copy(p.Sample[i].Value, sample.Value)
 
-		if sample.Label != nil {
-			for key, values := range sample.Label {
+		for j, loc := range sample.Location {
+			p.Sample[i].LocationId[j] = loc.ID
+		}
+
+		for _, key := range sortedKeys(sample.Label) {
+			keyIdx := int64(p.putString(key, m))
+			for _, value := range sample.Label[key] {
 				p.Sample[i].Label = append(p.Sample[i].Label, &Label{
-					Key: int64(p.putString(key, m)),
-					Str: int64(p.putString(values[0], m)),
+					Key: keyIdx,
+					Str: int64(p.putString(value, m)),
 				})
 			}
 		}
 
-		if sample.NumLabel != nil {
-			for key, values := range sample.NumLabel {
-				p.Sample[i].Label = append(p.Sample[i].Label, &Label{
-					Key:     int64(p.putString(key, m)),
-					Num:     values[0],
-					NumUnit: int64(p.putString(sample.NumUnit[key][0], m)),
-				})
+		for _, key := range sortedKeys(sample.NumLabel) {
+			keyIdx := int64(p.putString(key, m))
+			units := sample.NumUnit[key]
+			for j, value := range sample.NumLabel[key] {
+				label := &Label{
+					Key: keyIdx,
+					Num: value,
+				}
+				if j < len(units) {
+					label.NumUnit = int64(p.putString(units[j], m))
+				}
+				p.Sample[i].Label = append(p.Sample[i].Label, label)
 			}
 		}
 	}
 }
 
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 func (p *Profile) convertFunctions(functions []*profile.Function, m map[string]uint64) {
 	p.Function = make([]*Function, len(functions))
 	for i, f := range functions {
@@ -151,7 +314,7 @@ func (p *Profile) putString(val string, m map[string]uint64) uint64 {
 	if id, ok := m[val]; ok {
 		return id
 	}
-	nextID := uint64(len(m)) + 1
+	nextID := uint64(len(m))
 	m[val] = nextID
 	return nextID
 }
@@ -0,0 +1,108 @@
+package profile
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/pprof/profile"
+	"github.com/stretchr/testify/require"
+)
+
+var testdataProfiles = []string{
+	"hprof1", "hprof2", "hprof3", "hprof4", "labels.prof", "parca_cpu", "parca_goroutine", "parca_heap",
+}
+
+func TestConvertRoundTrip(t *testing.T) {
+	for _, name := range testdataProfiles {
+		t.Run(name, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("..", "testdata", name))
+			require.NoError(t, err)
+
+			expected, err := profile.ParseData(data)
+			require.NoError(t, err)
+
+			// google/pprof -> vtproto -> google/pprof
+			var p Profile
+			p.From(expected)
+			actual, err := p.To()
+			require.NoError(t, err)
+			require.NoError(t, actual.CheckValid())
+			requireEqualProfiles(t, expected, actual)
+
+			// vtproto -> google/pprof matches google/pprof parser
+			parsed, err := ParseProfileData(data)
+			require.NoError(t, err)
+			actual, err = parsed.To()
+			require.NoError(t, err)
+			requireEqualProfiles(t, expected, actual)
+
+			// vtproto encoding of converted profile is readable by google/pprof
+			encoded, err := p.MarshalVT()
+			require.NoError(t, err)
+			actual, err = profile.ParseData(encoded)
+			require.NoError(t, err)
+			requireEqualProfiles(t, expected, actual)
+		})
+	}
+}
+
+func TestConvertMultiValuedLabels(t *testing.T) {
+	fn := &profile.Function{ID: 1, Name: "main"}
+	loc := &profile.Location{ID: 1, Line: []profile.Line{{Function: fn, Line: 7}}}
+	expected := &profile.Profile{
+		SampleType: []*profile.ValueType{{Type: "samples", Unit: "count"}},
+		PeriodType: &profile.ValueType{Type: "cpu", Unit: "nanoseconds"},
+		Sample: []*profile.Sample{{
+			Location: []*profile.Location{loc},
+			Value:    []int64{3},
+			Label:    map[string][]string{"tag": {"a", "b", "a"}, "env": {"prod"}},
+			NumLabel: map[string][]int64{"size": {1, 0, 3}, "count": {5, 6}},
+			NumUnit:  map[string][]string{"size": {"bytes", "", "kilobytes"}},
+		}},
+		Location: []*profile.Location{loc},
+		Function: []*profile.Function{fn},
+		Comments: []string{"first", "second"},
+	}
+
+	var p Profile
+	p.From(expected)
+	require.Len(t, p.Sample[0].Label, 4+5)
+	require.Equal(t, []uint64{1}, p.Sample[0].LocationId)
+
+	actual, err := p.To()
+	require.NoError(t, err)
+
+	// google/pprof drops numeric label holding zero without unit, so compare with its own round trip
+	var buf bytes.Buffer
+	require.NoError(t, expected.Write(&buf))
+	reference, err := profile.Parse(&buf)
+	require.NoError(t, err)
+	require.Equal(t, []int64{1, 3}, reference.Sample[0].NumLabel["size"])
+
+	requireEqualProfiles(t, reference, actual)
+	require.Equal(t, []string{"a", "b", "a"}, actual.Sample[0].Label["tag"])
+	require.Equal(t, []string{"bytes", "kilobytes"}, actual.Sample[0].NumUnit["size"])
+	require.Equal(t, expected.Comments, actual.Comments)
+}
+
+func TestToInvalid(t *testing.T) {
+	p := &Profile{
+		StringTable: []string{""},
+		Sample:      []*Sample{{LocationId: []uint64{42}, Value: []int64{1}}},
+	}
+	_, err := p.To()
+	require.ErrorContains(t, err, "unknown location 42")
+}
+
+// requireEqualProfiles compares profiles by their encoding, which covers every field
+func requireEqualProfiles(t *testing.T, expected, actual *profile.Profile) {
+	t.Helper()
+
+	var expectedBuf, actualBuf bytes.Buffer
+	require.NoError(t, expected.WriteUncompressed(&expectedBuf))
+	require.NoError(t, actual.WriteUncompressed(&actualBuf))
+	require.Equal(t, expectedBuf.Bytes(), actualBuf.Bytes())
+	require.Equal(t, expected.String(), actual.String())
+}
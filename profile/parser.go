@@ -9,6 +9,7 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"text/tabwriter"
 
 	"github.com/pkg/errors"
 )
@@ -19,6 +20,9 @@ var (
 	frameInfoRe  = regexp.MustCompile(`\A#\t+(0x[0-9a-f]+)\t+(\S+)[+](0x[0-9a-f]+)\t+(\S+):(\d+)\z`)
 )
 
+// labelsPrefix starts the line listing labels of goroutines of a stacktrace
+const labelsPrefix = "# labels: "
+
 var errUnrecognized = fmt.Errorf("unrecognized profile format")
 var errMalformed = fmt.Errorf("malformed profile format")
 
@@ -56,39 +60,66 @@ func (gp *GoroutineProfile) MarshalDebug() string {
 
 	gp.writeProlog(&sb)
 
-	gp.writeStackTraces(&sb)
+	gp.writeStackTraces(&sb, false)
 
 	return sb.String()
 }
 
-func (gp *GoroutineProfile) writeProlog(sb *strings.Builder) {
-	fmt.Fprintf(sb, "goroutine profile: total %d\n", gp.GetTotal())
+// MarshalDebugExact writes gp in debug=1 format exactly the way runtime/pprof does, i.e. with labels
+// and frames aligned by tabwriter. Leading comments and trimmed newlines of text gp was parsed from
+// are restored too, so that text written by runtime/pprof round trips byte for byte.
+func (gp *GoroutineProfile) MarshalDebugExact() string {
+	var sb strings.Builder
+	for _, comment := range gp.GetComments() {
+		sb.WriteString(comment)
+		sb.WriteByte('\n')
+	}
+
+	// runtime/pprof aligns frames with the same tabwriter
+	tw := tabwriter.NewWriter(&sb, 1, 8, 1, '\t', 0)
+	gp.writeProlog(tw)
+	gp.writeStackTraces(tw, true)
+	// writing to strings.Builder never fails
+	_ = tw.Flush()
+
+	text := sb.String()
+	for i := uint64(0); i < gp.GetTrimmedNewlines() && strings.HasSuffix(text, "\n"); i++ {
+		text = text[:len(text)-1]
+	}
+	return text
 }
 
-func (gp *GoroutineProfile) writeStackTraces(sb *strings.Builder) {
+func (gp *GoroutineProfile) writeProlog(w io.Writer) {
+	fmt.Fprintf(w, "goroutine profile: total %d\n", gp.GetTotal())
+}
+
+func (gp *GoroutineProfile) writeStackTraces(w io.Writer, withLabels bool) {
 	for _, st := range gp.GetStacktraces() {
-		gp.writeStackTrace(sb, st)
-		sb.WriteRune('\n')
+		gp.writeStackTrace(w, st, withLabels)
+		io.WriteString(w, "\n")
 	}
 }
 
-func (gp *GoroutineProfile) writeStackTrace(sb *strings.Builder, st *Stacktrace) {
+func (gp *GoroutineProfile) writeStackTrace(w io.Writer, st *Stacktrace, withLabels bool) {
 	pc := make([]string, 0, len(st.PC))
 	for _, addr := range st.PC {
 		pc = append(pc, fmt.Sprintf("%#x", addr))
 	}
-	fmt.Fprintf(sb, "%d @ %s\n", st.Total, strings.Join(pc, " "))
+	fmt.Fprintf(w, "%d @ %s\n", st.Total, strings.Join(pc, " "))
+	if withLabels && st.Labels != 0 {
+		fmt.Fprintf(w, "%s%s\n", labelsPrefix, gp.StringTable[st.Labels])
+	}
 	for _, f := range st.GetFrames() {
-		gp.writeFrame(sb, f)
+		gp.writeFrame(w, f)
 	}
 }
 
-func (gp *GoroutineProfile) writeFrame(sb *strings.Builder, f *Frame) {
+func (gp *GoroutineProfile) writeFrame(w io.Writer, f *Frame) {
 	if f.FunctionName == 0 {
 		// empty function name
-		fmt.Fprintf(sb, "#\t%#x\n", f.Address)
+		fmt.Fprintf(w, "#\t%#x\n", f.Address)
 	} else {
-		fmt.Fprintf(sb, "#\t%#x\t%s+%#x\t%s:%d\n", f.Address, gp.StringTable[f.FunctionName], f.Offset, gp.StringTable[f.Filename], f.Line)
+		fmt.Fprintf(w, "#\t%#x\t%s+%#x\t%s:%d\n", f.Address, gp.StringTable[f.FunctionName], f.Offset, gp.StringTable[f.Filename], f.Line)
 	}
 }
 
@@ -96,6 +127,7 @@ func (gp *GoroutineProfile) writeFrame(sb *strings.Builder, f *Frame) {
 func (gp *GoroutineProfile) Parse(rawProfile []byte) error {
 	s := bufio.NewScanner(bytes.NewBuffer(rawProfile))
 	for s.Scan() && isSpaceOrComment(s.Text()) {
+		gp.Comments = append(gp.Comments, s.Text())
 	}
 	if err := s.Err(); err != nil {
 		return err
@@ -115,6 +147,14 @@ func (gp *GoroutineProfile) Parse(rawProfile []byte) error {
 
 	gp.finalizeStringTable(stringTable)
 
+	// runtime/pprof ends every stacktrace with a blank line
+	written := 1
+	if len(gp.Stacktraces) > 0 {
+		written = 2
+	}
+	trailing := len(rawProfile) - len(bytes.TrimRight(rawProfile, "\n"))
+	gp.TrimmedNewlines = uint64(max(written-trailing, 0))
+
 	return nil
 }
 
@@ -190,7 +230,8 @@ func parseStackTrace(line string, s *bufio.Scanner, stringTable map[string]uint6
 	for s.Scan() && !isSpace(s.Text()) {
 		frame := &Frame{}
 		line = s.Text()
-		if strings.HasPrefix(line, "# labels:") {
+		if labels, ok := strings.CutPrefix(line, "# labels:"); ok {
+			st.Labels = putString(stringTable, strings.TrimPrefix(labels, " "))
 			continue
 		}
 		m = frameInfoRe.FindStringSubmatch(line)
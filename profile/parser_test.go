@@ -0,0 +1,66 @@
+package profile
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"runtime/pprof"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var testdataGoroutineProfiles = []string{
+	"parca_goroutine_debug_1_1", "parca_goroutine_debug_1_2", "parca_goroutine_debug_1_3",
+}
+
+func TestMarshalDebugExact(t *testing.T) {
+	for _, name := range testdataGoroutineProfiles {
+		t.Run(name, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("..", "testdata", name))
+			require.NoError(t, err)
+
+			// testdata is trimmed, so the last stacktrace lacks its blank line
+			gp := new(GoroutineProfile)
+			require.NoError(t, gp.Parse(data))
+			require.Equal(t, uint64(2), gp.TrimmedNewlines)
+			require.Equal(t, string(data), gp.MarshalDebugExact())
+
+			// the way runtime/pprof writes it, with leading comments
+			data = append([]byte("# captured by ppmerge\n\n"), data...)
+			data = append(data, "\n\n"...)
+			gp = new(GoroutineProfile)
+			require.NoError(t, gp.Parse(data))
+			require.Equal(t, []string{"# captured by ppmerge", ""}, gp.Comments)
+			require.Zero(t, gp.TrimmedNewlines)
+			require.Equal(t, string(data), gp.MarshalDebugExact())
+
+			// the lossy writer drops labels and alignment
+			debug := gp.MarshalDebug()
+			require.NotContains(t, debug, "# labels:")
+			require.NotContains(t, debug, "\t\t")
+		})
+	}
+}
+
+func TestMarshalDebugExactRuntime(t *testing.T) {
+	done := make(chan struct{})
+	defer close(done)
+	started := make(chan struct{})
+	go pprof.Do(context.Background(), pprof.Labels("component", "test", "zone", "a"), func(context.Context) {
+		close(started)
+		<-done
+	})
+	<-started
+
+	var buf bytes.Buffer
+	require.NoError(t, pprof.Lookup("goroutine").WriteTo(&buf, 1))
+	require.Contains(t, buf.String(), `# labels: {"component":"test", "zone":"a"}`)
+
+	gp := new(GoroutineProfile)
+	require.NoError(t, gp.Parse(buf.Bytes()))
+	require.Empty(t, gp.Comments)
+	require.Zero(t, gp.TrimmedNewlines)
+	require.Equal(t, buf.String(), gp.MarshalDebugExact())
+}
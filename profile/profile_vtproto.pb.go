@@ -50,6 +50,20 @@ func (m *GoroutineProfile) MarshalToSizedBufferVT(dAtA []byte) (int, error) {
 		i -= len(m.unknownFields)
 		copy(dAtA[i:], m.unknownFields)
 	}
+	if m.TrimmedNewlines != 0 {
+		i = protohelpers.EncodeVarint(dAtA, i, uint64(m.TrimmedNewlines))
+		i--
+		dAtA[i] = 0x28
+	}
+	if len(m.Comments) > 0 {
+		for iNdEx := len(m.Comments) - 1; iNdEx >= 0; iNdEx-- {
+			i -= len(m.Comments[iNdEx])
+			copy(dAtA[i:], m.Comments[iNdEx])
+			i = protohelpers.EncodeVarint(dAtA, i, uint64(len(m.Comments[iNdEx])))
+			i--
+			dAtA[i] = 0x22
+		}
+	}
 	if len(m.StringTable) > 0 {
 		for iNdEx := len(m.StringTable) - 1; iNdEx >= 0; iNdEx-- {
 			i -= len(m.StringTable[iNdEx])
@@ -109,6 +123,11 @@ func (m *Stacktrace) MarshalToSizedBufferVT(dAtA []byte) (int, error) {
 		i -= len(m.unknownFields)
 		copy(dAtA[i:], m.unknownFields)
 	}
+	if m.Labels != 0 {
+		i = protohelpers.EncodeVarint(dAtA, i, uint64(m.Labels))
+		i--
+		dAtA[i] = 0x20
+	}
 	if len(m.Frames) > 0 {
 		for iNdEx := len(m.Frames) - 1; iNdEx >= 0; iNdEx-- {
 			size, err := m.Frames[iNdEx].MarshalToSizedBufferVT(dAtA[:i])
@@ -884,9 +903,11 @@ func (m *GoroutineProfile) ResetVT() {
 		}
 		f0 := m.Stacktraces[:0]
 		f1 := m.StringTable[:0]
+		f2 := m.Comments[:0]
 		m.Reset()
 		m.Stacktraces = f0
 		m.StringTable = f1
+		m.Comments = f2
 	}
 }
 func (m *GoroutineProfile) ReturnToVTPool() {
@@ -969,6 +990,15 @@ func (m *GoroutineProfile) SizeVT() (n int) {
 			n += 1 + l + protohelpers.SizeOfVarint(uint64(l))
 		}
 	}
+	if len(m.Comments) > 0 {
+		for _, s := range m.Comments {
+			l = len(s)
+			n += 1 + l + protohelpers.SizeOfVarint(uint64(l))
+		}
+	}
+	if m.TrimmedNewlines != 0 {
+		n += 1 + protohelpers.SizeOfVarint(uint64(m.TrimmedNewlines))
+	}
 	n += len(m.unknownFields)
 	return n
 }
@@ -995,6 +1025,9 @@ func (m *Stacktrace) SizeVT() (n int) {
 			n += 1 + l + protohelpers.SizeOfVarint(uint64(l))
 		}
 	}
+	if m.Labels != 0 {
+		n += 1 + protohelpers.SizeOfVarint(uint64(m.Labels))
+	}
 	n += len(m.unknownFields)
 	return n
 }
@@ -1413,6 +1446,57 @@ func (m *GoroutineProfile) UnmarshalVT(dAtA []byte) error {
 			}
 			m.StringTable = append(m.StringTable, string(dAtA[iNdEx:postIndex]))
 			iNdEx = postIndex
+		case 4:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Comments", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return protohelpers.ErrIntOverflow
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return protohelpers.ErrInvalidLength
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return protohelpers.ErrInvalidLength
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Comments = append(m.Comments, string(dAtA[iNdEx:postIndex]))
+			iNdEx = postIndex
+		case 5:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field TrimmedNewlines", wireType)
+			}
+			m.TrimmedNewlines = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return protohelpers.ErrIntOverflow
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.TrimmedNewlines |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
 		default:
 			iNdEx = preIndex
 			skippy, err := protohelpers.Skip(dAtA[iNdEx:])
@@ -1593,6 +1677,25 @@ func (m *Stacktrace) UnmarshalVT(dAtA []byte) error {
 				return err
 			}
 			iNdEx = postIndex
+		case 4:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Labels", wireType)
+			}
+			m.Labels = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return protohelpers.ErrIntOverflow
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Labels |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
 		default:
 			iNdEx = preIndex
 			skippy, err := protohelpers.Skip(dAtA[iNdEx:])
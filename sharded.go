@@ -0,0 +1,280 @@
+package ppmerge
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"strings"
+
+	pprofile "github.com/google/pprof/profile"
+	"github.com/pkg/errors"
+	"github.com/threadedstream/ppmerge/profile"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// shardsFieldNumber is the number of shards field of ShardedProfile
+const shardsFieldNumber = 1
+
+// Signature returns sample type signature of p: its sample types followed by period type, e.g.
+// "samples/count,cpu/nanoseconds;cpu/nanoseconds". Profiles of the same kind share a signature.
+func Signature(p *profile.Profile) string {
+	var sb strings.Builder
+	for i, vt := range p.SampleType {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(p.StringTable[vt.Type])
+		sb.WriteByte('/')
+		sb.WriteString(p.StringTable[vt.Unit])
+	}
+	sb.WriteByte(';')
+	if pt := p.PeriodType; pt != nil {
+		sb.WriteString(p.StringTable[pt.Type])
+		sb.WriteByte('/')
+		sb.WriteString(p.StringTable[pt.Unit])
+	}
+	return sb.String()
+}
+
+// ShardedMerger merges profiles of mixed kinds into ShardedProfile, which holds a separate
+// MergedProfile for every sample type signature. Profiles of the same kind share most of their
+// functions, locations and strings, so tables of every shard stay dense, while profiles of
+// different kinds merged together mostly add up.
+//
+// ShardedProfile returned by Merge is owned by the merger: it stays valid until the next call
+// to Merge, Reset or Release.
+type ShardedMerger struct {
+	shardedProfile *ShardedProfile
+	mergers        []*ProfileMerger
+	shardIDs       map[string]int
+	opts           []MergerOption
+}
+
+// NewShardedMerger returns new ShardedMerger instance, opts are applied to merger of every shard
+func NewShardedMerger(opts ...MergerOption) *ShardedMerger {
+	return &ShardedMerger{
+		shardedProfile: new(ShardedProfile),
+		shardIDs:       make(map[string]int),
+		opts:           opts,
+	}
+}
+
+// Merge partitions ps by signature and merges every partition into its own shard. Entry i of
+// result refers to ps[i]. Shards are numbered in order of their first profile.
+// Result of previous call is dropped.
+func (sm *ShardedMerger) Merge(ps ...*profile.Profile) *ShardedProfile {
+	sm.Reset()
+
+	sp := sm.shardedProfile
+	sp.EntryShards = make([]uint64, 0, len(ps))
+	sp.EntryIndexes = make([]uint64, 0, len(ps))
+
+	var groups [][]*profile.Profile
+	for _, p := range ps {
+		signature := Signature(p)
+		shard, ok := sm.shardIDs[signature]
+		if !ok {
+			shard = len(groups)
+			sm.shardIDs[signature] = shard
+			sp.Signatures = append(sp.Signatures, signature)
+			groups = append(groups, nil)
+		}
+
+		sp.EntryShards = append(sp.EntryShards, uint64(shard))
+		sp.EntryIndexes = append(sp.EntryIndexes, uint64(len(groups[shard])))
+		groups[shard] = append(groups[shard], p)
+	}
+
+	sm.mergers = make([]*ProfileMerger, len(groups))
+	sp.Shards = make([]*MergedProfile, len(groups))
+	for shard, group := range groups {
+		sm.mergers[shard] = NewProfileMerger(sm.opts...)
+		sp.Shards[shard] = sm.mergers[shard].Merge(group...)
+	}
+
+	return sp
+}
+
+func (sm *ShardedMerger) WriteCompressed(w io.Writer) error {
+	zw := gzip.NewWriter(w)
+	defer zw.Close()
+	serialized, err := sm.marshal()
+	if err != nil {
+		return err
+	}
+
+	_, err = zw.Write(serialized)
+	return err
+}
+
+func (sm *ShardedMerger) WriteUncompressed(w io.Writer) error {
+	serialized, err := sm.marshal()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(serialized)
+	return err
+}
+
+// marshal marshals sharded profile the way it's written, see ProfileMerger.written
+func (sm *ShardedMerger) marshal() ([]byte, error) {
+	sp := sm.shardedProfile
+	if len(sm.mergers) > 0 {
+		shards := make([]*MergedProfile, len(sp.Shards))
+		for i := range sp.Shards {
+			shards[i] = sm.mergers[i].written()
+		}
+		sp = &ShardedProfile{
+			Shards:       shards,
+			Signatures:   sp.Signatures,
+			EntryShards:  sp.EntryShards,
+			EntryIndexes: sp.EntryIndexes,
+		}
+	}
+	return sp.MarshalDeterministic()
+}
+
+// Reset returns shards to the vtproto pool and clears sharded profile, so that merger can be
+// reused. It may also be called after Release.
+func (sm *ShardedMerger) Reset() {
+	sm.releaseShards()
+	if sm.shardedProfile == nil {
+		sm.shardedProfile = new(ShardedProfile)
+	}
+	sm.shardedProfile.Reset()
+}
+
+// Release returns shards to the vtproto pool. Neither merger nor sharded profile must be used
+// afterwards until Reset is called.
+func (sm *ShardedMerger) Release() {
+	sm.releaseShards()
+	sm.shardedProfile = nil
+}
+
+func (sm *ShardedMerger) releaseShards() {
+	for _, pw := range sm.mergers {
+		pw.Release()
+	}
+	sm.mergers = nil
+	clear(sm.shardIDs)
+}
+
+// Shard returns shard holding profiles of signature, nil is returned if there is no such shard
+func (x *ShardedProfile) Shard(signature string) *MergedProfile {
+	for i, s := range x.Signatures {
+		if s == signature && i < len(x.Shards) {
+			return x.Shards[i]
+		}
+	}
+	return nil
+}
+
+// MarshalDeterministic marshals x like MarshalVT does, but every shard is marshaled
+// with MergedProfile.MarshalDeterministic, so that equal profiles are always encoded to the same bytes.
+func (x *ShardedProfile) MarshalDeterministic() ([]byte, error) {
+	withoutShards := &ShardedProfile{
+		Signatures:   x.Signatures,
+		EntryShards:  x.EntryShards,
+		EntryIndexes: x.EntryIndexes,
+	}
+	data, err := withoutShards.MarshalVT()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, shard := range x.Shards {
+		shardData, err := shard.MarshalDeterministic()
+		if err != nil {
+			return nil, err
+		}
+		data = protowire.AppendTag(data, shardsFieldNumber, protowire.BytesType)
+		data = protowire.AppendBytes(data, shardData)
+	}
+
+	return data, nil
+}
+
+// ShardedProfileUnPacker recovers profiles stored in ShardedProfile by their index in merge order.
+//
+// Sharded profile passed to NewShardedProfileUnPacker stays owned by the caller, while the one
+// decoded by UnpackRaw is owned by unpacker and replaces it.
+type ShardedProfileUnPacker struct {
+	shardedProfile *ShardedProfile
+	ownsProfile    bool
+	unpackers      []*ProfileUnPacker
+	opts           []UnPackerOption
+}
+
+// NewShardedProfileUnPacker returns new ShardedProfileUnPacker instance, opts are applied
+// to unpacker of every shard
+func NewShardedProfileUnPacker(shardedProfile *ShardedProfile, opts ...UnPackerOption) *ShardedProfileUnPacker {
+	return &ShardedProfileUnPacker{
+		shardedProfile: shardedProfile,
+		opts:           opts,
+	}
+}
+
+// Reset drops unpackers of shards and clears sharded profile decoded by UnpackRaw
+func (pu *ShardedProfileUnPacker) Reset() {
+	pu.unpackers = nil
+	if pu.ownsProfile {
+		pu.shardedProfile.Reset()
+	}
+}
+
+// Release drops reference to sharded profile
+func (pu *ShardedProfileUnPacker) Release() {
+	pu.unpackers = nil
+	pu.shardedProfile = nil
+	pu.ownsProfile = false
+}
+
+func (pu *ShardedProfileUnPacker) UnpackRaw(compressedRawProfile []byte, idx uint64) (*pprofile.Profile, error) {
+	gzReader, err := gzip.NewReader(bytes.NewReader(compressedRawProfile))
+	if err != nil {
+		return nil, err
+	}
+
+	rawProfile, err := io.ReadAll(gzReader)
+	if err != nil {
+		return nil, err
+	}
+
+	// sharded profile passed by the caller is left intact, while the owned one is reused
+	if pu.ownsProfile {
+		pu.shardedProfile.Reset()
+	} else {
+		pu.shardedProfile = new(ShardedProfile)
+		pu.ownsProfile = true
+	}
+	pu.unpackers = nil
+
+	if err = pu.shardedProfile.UnmarshalVT(rawProfile); err != nil {
+		return nil, err
+	}
+
+	return pu.Unpack(idx)
+}
+
+// Unpack recovers profile idx in order of merge. Returned profile is owned by the caller.
+func (pu *ShardedProfileUnPacker) Unpack(idx uint64) (*pprofile.Profile, error) {
+	sp := pu.shardedProfile
+	if idx >= uint64(len(sp.EntryShards)) || idx >= uint64(len(sp.EntryIndexes)) {
+		return nil, indexOutOfRangeErr
+	}
+
+	shard := sp.EntryShards[idx]
+	if shard >= uint64(len(sp.Shards)) {
+		return nil, errors.Errorf("entry %d refers to unknown shard %d", idx, shard)
+	}
+
+	if pu.unpackers == nil {
+		pu.unpackers = make([]*ProfileUnPacker, len(sp.Shards))
+	}
+	if pu.unpackers[shard] == nil {
+		pu.unpackers[shard] = NewProfileUnPacker(sp.Shards[shard], pu.opts...)
+	}
+
+	p, err := pu.unpackers[shard].Unpack(sp.EntryIndexes[idx])
+	return p, errors.Wrapf(err, "unpack entry %d of shard %d", sp.EntryIndexes[idx], shard)
+}
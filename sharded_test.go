@@ -0,0 +1,103 @@
+package ppmerge
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/threadedstream/ppmerge/profile"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestShardedMerge(t *testing.T) {
+	paths := []string{"hprof1", "parca_cpu", "parca_goroutine", "hprof2", "labels.prof", "hprof3"}
+	profiles := getProfilesVtProto(t, false, paths...)
+
+	shardedMerger := NewShardedMerger()
+	defer shardedMerger.Release()
+	shardedProfile := shardedMerger.Merge(profiles...)
+
+	require.Equal(t, []string{
+		"alloc_objects/count,alloc_space/bytes,inuse_objects/count,inuse_space/bytes;space/bytes",
+		"samples/count,cpu/nanoseconds;cpu/nanoseconds",
+		"goroutine/count;goroutine/count",
+	}, shardedProfile.Signatures)
+	require.Equal(t, []uint64{0, 1, 2, 0, 1, 0}, shardedProfile.EntryShards)
+	require.Equal(t, []uint64{0, 0, 0, 1, 1, 2}, shardedProfile.EntryIndexes)
+	require.Len(t, shardedProfile.Shards, 3)
+	require.Len(t, shardedProfile.Shard(Signature(profiles[1])).NumSamples, 2)
+	require.Nil(t, shardedProfile.Shard("unknown"))
+
+	var compressed bytes.Buffer
+	require.NoError(t, shardedMerger.WriteCompressed(&compressed))
+
+	unpacker := NewShardedProfileUnPacker(shardedProfile)
+	rawUnpacker := NewShardedProfileUnPacker(nil)
+	for i, p := range profiles {
+		expected, err := NewProfileUnPacker(NewProfileMerger().Merge(p)).Unpack(0)
+		require.NoError(t, err)
+
+		actual, err := unpacker.Unpack(uint64(i))
+		require.NoError(t, err)
+		require.Equal(t, expected.String(), actual.String(), paths[i])
+
+		actual, err = rawUnpacker.UnpackRaw(compressed.Bytes(), uint64(i))
+		require.NoError(t, err)
+		require.Equal(t, expected.String(), actual.String(), paths[i])
+	}
+
+	_, err := unpacker.Unpack(uint64(len(profiles)))
+	require.Error(t, err)
+
+	// shards are compressed better than the mix of kinds
+	var mixed bytes.Buffer
+	mixedMerger := NewProfileMerger()
+	mixedMerger.Merge(profiles...)
+	require.NoError(t, mixedMerger.WriteCompressed(&mixed))
+	require.Less(t, compressed.Len(), mixed.Len())
+
+	// encoding is reproducible
+	var first, second bytes.Buffer
+	require.NoError(t, shardedMerger.WriteUncompressed(&first))
+	shardedMerger.Merge(profiles...)
+	require.NoError(t, shardedMerger.WriteUncompressed(&second))
+	require.Equal(t, first.Bytes(), second.Bytes())
+
+	shardedMerger.Reset()
+	require.Empty(t, shardedMerger.Merge().Shards)
+}
+
+func TestShardedUnpackRawTwice(t *testing.T) {
+	compress := func(paths ...string) ([]byte, []*profile.Profile) {
+		profiles := getProfilesVtProto(t, false, paths...)
+		shardedMerger := NewShardedMerger()
+		shardedMerger.Merge(profiles...)
+		var compressed bytes.Buffer
+		require.NoError(t, shardedMerger.WriteCompressed(&compressed))
+		return compressed.Bytes(), profiles
+	}
+	first, _ := compress("hprof1", "parca_cpu")
+	second, profiles := compress("parca_cpu", "hprof2")
+
+	// profile passed by the caller isn't written to
+	callerProfile := NewShardedMerger().Merge(getProfilesVtProto(t, false, "hprof3")...)
+	expectedCallerProfile := proto.Clone(callerProfile)
+	unpacker := NewShardedProfileUnPacker(callerProfile)
+	for _, data := range [][]byte{first, first, second} {
+		_, err := unpacker.UnpackRaw(data, 0)
+		require.NoError(t, err)
+	}
+	require.True(t, proto.Equal(expectedCallerProfile, callerProfile))
+	require.Len(t, unpacker.shardedProfile.Shards, 2)
+	require.Len(t, unpacker.shardedProfile.EntryShards, 2)
+
+	for i, p := range profiles {
+		expected, err := NewProfileUnPacker(NewProfileMerger().Merge(p)).Unpack(0)
+		require.NoError(t, err)
+		actual, err := unpacker.UnpackRaw(second, uint64(i))
+		require.NoError(t, err)
+		require.Equal(t, expected.String(), actual.String())
+	}
+	_, err := unpacker.UnpackRaw(second, 2)
+	require.Error(t, err)
+}
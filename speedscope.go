@@ -0,0 +1,126 @@
+package ppmerge
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const speedscopeSchema = "https://www.speedscope.app/file-format-schema.json"
+
+// speedscopeFile is the root of speedscope file format, see https://www.speedscope.app/file-format-schema.json
+type speedscopeFile struct {
+	Schema             string              `json:"$schema"`
+	Shared             speedscopeShared    `json:"shared"`
+	Profiles           []speedscopeProfile `json:"profiles"`
+	Name               string              `json:"name,omitempty"`
+	ActiveProfileIndex int                 `json:"activeProfileIndex"`
+	Exporter           string              `json:"exporter"`
+}
+
+type speedscopeShared struct {
+	Frames []speedscopeFrame `json:"frames"`
+}
+
+type speedscopeFrame struct {
+	Name string `json:"name"`
+	File string `json:"file,omitempty"`
+	Line int64  `json:"line,omitempty"`
+}
+
+type speedscopeProfile struct {
+	Type       string  `json:"type"`
+	Name       string  `json:"name"`
+	Unit       string  `json:"unit"`
+	StartValue int64   `json:"startValue"`
+	EndValue   int64   `json:"endValue"`
+	Samples    [][]int `json:"samples"`
+	Weights    []int64 `json:"weights"`
+}
+
+// WriteSpeedscope writes entries idxs as speedscope JSON file with one sampled profile per
+// entry, so that the viewer can scrub through them. Values are taken from sampleType,
+// the last sample type of entry is used if it's empty.
+func (pu *ProfileUnPacker) WriteSpeedscope(w io.Writer, sampleType string, idxs ...uint64) error {
+	file := speedscopeFile{
+		Schema:   speedscopeSchema,
+		Exporter: "ppmerge",
+		Profiles: make([]speedscopeProfile, 0, len(idxs)),
+	}
+	frameIdx := make(map[stackFrame]int)
+
+	for _, idx := range idxs {
+		p, err := pu.Unpack(idx)
+		if err != nil {
+			return errors.Wrapf(err, "unpack profile %d", idx)
+		}
+
+		valueIdx := sampleTypeIndex(p, sampleType)
+		if valueIdx < 0 {
+			return errors.Errorf("profile %d has no sample type %q", idx, sampleType)
+		}
+
+		sp := speedscopeProfile{
+			Type: "sampled",
+			Name: fmt.Sprintf("#%d %s %s", idx, p.SampleType[valueIdx].Type, time.Unix(0, p.TimeNanos).UTC().Format(time.RFC3339)),
+			Unit: speedscopeUnit(p.SampleType[valueIdx].Unit),
+		}
+
+		for _, s := range p.Sample {
+			v := s.Value[valueIdx]
+			if v == 0 {
+				continue
+			}
+
+			frames := sampleFrames(s)
+			stack := make([]int, len(frames))
+			for i, f := range frames {
+				fi, ok := frameIdx[f]
+				if !ok {
+					fi = len(file.Shared.Frames)
+					frameIdx[f] = fi
+					file.Shared.Frames = append(file.Shared.Frames, speedscopeFrame{
+						Name: f.name,
+						File: f.file,
+						Line: f.line,
+					})
+				}
+				stack[i] = fi
+			}
+
+			sp.Samples = append(sp.Samples, stack)
+			sp.Weights = append(sp.Weights, v)
+			sp.EndValue += v
+		}
+
+		// speedscope rejects nulls
+		if sp.Samples == nil {
+			sp.Samples, sp.Weights = [][]int{}, []int64{}
+		}
+		file.Profiles = append(file.Profiles, sp)
+	}
+
+	if file.Shared.Frames == nil {
+		file.Shared.Frames = []speedscopeFrame{}
+	}
+
+	return json.NewEncoder(w).Encode(file)
+}
+
+// WriteSpeedscopeRange writes entries captured within [start, end] interval as speedscope JSON file
+func (pu *ProfileUnPacker) WriteSpeedscopeRange(w io.Writer, sampleType string, start, end time.Time) error {
+	return pu.WriteSpeedscope(w, sampleType, pu.mergedProfile.EntriesBetween(start, end)...)
+}
+
+// speedscopeUnit converts pprof unit to one of the units supported by speedscope
+func speedscopeUnit(unit string) string {
+	switch unit {
+	case "nanoseconds", "microseconds", "milliseconds", "seconds", "bytes":
+		return unit
+	default:
+		return "none"
+	}
+}
@@ -0,0 +1,57 @@
+package ppmerge
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteSpeedscope(t *testing.T) {
+	profiles := getProfilesVtProto(t, false, "hprof1", "hprof2", "hprof3", "hprof4")
+	actualProfiles := getProfiles(t, "hprof1", "hprof2", "hprof3", "hprof4")
+
+	mergedProfile := NewProfileMerger().Merge(profiles...)
+	unpacker := NewProfileUnPacker(mergedProfile)
+
+	bb := bytes.NewBuffer(nil)
+	require.NoError(t, unpacker.WriteSpeedscope(bb, "alloc_space", 0, 1, 2))
+
+	var file speedscopeFile
+	require.NoError(t, json.Unmarshal(bb.Bytes(), &file))
+	require.Equal(t, speedscopeSchema, file.Schema)
+	require.Len(t, file.Profiles, 3)
+
+	for i, sp := range file.Profiles {
+		require.Equal(t, "sampled", sp.Type)
+		require.Equal(t, "bytes", sp.Unit)
+		require.Len(t, sp.Weights, len(sp.Samples))
+
+		var expected int64
+		for _, s := range actualProfiles[i].Sample {
+			expected += s.Value[1]
+		}
+		require.Equal(t, expected, sp.EndValue)
+
+		for _, stack := range sp.Samples {
+			for _, fi := range stack {
+				require.Less(t, fi, len(file.Shared.Frames))
+			}
+		}
+	}
+
+	// hprof3 and hprof4 were captured before hprof1 and hprof2
+	bb.Reset()
+	start := time.Unix(0, actualProfiles[3].TimeNanos)
+	end := time.Unix(0, actualProfiles[2].TimeNanos)
+	require.NoError(t, unpacker.WriteSpeedscopeRange(bb, "", start, end))
+
+	file = speedscopeFile{}
+	require.NoError(t, json.Unmarshal(bb.Bytes(), &file))
+	require.Len(t, file.Profiles, 2)
+	require.True(t, strings.HasPrefix(file.Profiles[0].Name, "#2 inuse_space"))
+	require.True(t, strings.HasPrefix(file.Profiles[1].Name, "#3 inuse_space"))
+}
@@ -0,0 +1,245 @@
+package ppmerge
+
+import (
+	"compress/gzip"
+	"sort"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Stats describes contents of merged profile and how much space every part of it takes
+type Stats struct {
+	// Entries is number of profiles stored in merged profile
+	Entries int
+	// EntrySamples holds number of samples of every entry. It's number of stacktraces
+	// for goroutine profiles and size in bytes for byte profiles.
+	EntrySamples []uint64
+	// Tables are shared tables entries reference
+	Tables []TableStats
+	// Fields are protobuf fields of merged profile ordered by field number
+	Fields []FieldStats
+	// Size is size of marshaled merged profile and CompressedSize is the one written by WriteCompressed
+	Size           int
+	CompressedSize int
+	// Dictionary is key of symbol dictionary tables of merged profile start from, if any.
+	// Tables hold only the rest of entries then.
+	Dictionary string
+}
+
+// TableStats describes a shared table. Lookups and Hits are counted by mergers only,
+// they're zero for merged profiles decoded from archives.
+type TableStats struct {
+	Name string
+	Len  int
+	// Lookups is number of values interned into table and Hits is number of those already present in it
+	Lookups uint64
+	Hits    uint64
+}
+
+// HitRate returns share of lookups deduplicated by table
+func (ts TableStats) HitRate() float64 {
+	if ts.Lookups == 0 {
+		return 0
+	}
+	return float64(ts.Hits) / float64(ts.Lookups)
+}
+
+// FieldStats describes space taken by protobuf field. CompressedSize is size of field gzipped
+// on its own, so sum of them doesn't add up to Stats.CompressedSize.
+type FieldStats struct {
+	Name           string
+	Number         int
+	Size           int
+	CompressedSize int
+}
+
+// dedupCounter counts lookups of interned table
+type dedupCounter struct {
+	lookups uint64
+	hits    uint64
+}
+
+func (dc *dedupCounter) count(hit bool) {
+	dc.lookups++
+	if hit {
+		dc.hits++
+	}
+}
+
+// add adds lookups counted by another merger, extraHits of which were deduplicated afterwards
+func (dc *dedupCounter) add(other dedupCounter, extraHits int) {
+	dc.lookups += other.lookups
+	dc.hits += other.hits + uint64(extraHits)
+}
+
+func (dc dedupCounter) tableStats(name string, size int) TableStats {
+	return TableStats{
+		Name:    name,
+		Len:     size,
+		Lookups: dc.lookups,
+		Hits:    dc.hits,
+	}
+}
+
+// mergeDedup holds lookups of tables interned by ProfileMerger
+type mergeDedup struct {
+	strings   dedupCounter
+	functions dedupCounter
+	mappings  dedupCounter
+	locations dedupCounter
+}
+
+// Stats returns statistics of x
+func (x *MergedProfile) Stats() (*Stats, error) {
+	return x.stats(mergeDedup{})
+}
+
+func (x *MergedProfile) stats(dedup mergeDedup) (*Stats, error) {
+	data, err := x.MarshalDeterministic()
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal merged profile")
+	}
+
+	stats, err := newStats(data, x.ProtoReflect().Descriptor())
+	if err != nil {
+		return nil, err
+	}
+	stats.Entries = len(x.NumSamples)
+	stats.EntrySamples = x.NumSamples
+	stats.Dictionary = x.Dictionary
+	stats.Tables = []TableStats{
+		dedup.strings.tableStats("strings", numStrings(x.StringTable, x.FrontCodedStrings)),
+		dedup.functions.tableStats("functions", len(x.Functions)),
+		dedup.locations.tableStats("locations", len(x.Locations)),
+		dedup.mappings.tableStats("mappings", len(x.Mappings)),
+	}
+	return stats, nil
+}
+
+// Stats returns statistics of merged profile as it's written, including deduplication counted
+// since the last Reset
+func (pw *ProfileMerger) Stats() (*Stats, error) {
+	return pw.written().stats(pw.dedup)
+}
+
+// Stats returns statistics of x
+func (x *MergedGoroutineProfile) Stats() (*Stats, error) {
+	return x.stats(dedupCounter{})
+}
+
+func (x *MergedGoroutineProfile) stats(stringDedup dedupCounter) (*Stats, error) {
+	data, err := x.MarshalVT()
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal merged profile")
+	}
+
+	stats, err := newStats(data, x.ProtoReflect().Descriptor())
+	if err != nil {
+		return nil, err
+	}
+	stats.Entries = len(x.NumStacktraces)
+	stats.EntrySamples = x.NumStacktraces
+	stats.Tables = []TableStats{
+		stringDedup.tableStats("strings", numStrings(x.StringTable, x.FrontCodedStrings)),
+		{Name: "stacktraces", Len: len(x.Stacktraces)},
+	}
+	return stats, nil
+}
+
+// Stats returns statistics of merged profile as it's written, including deduplication counted
+// since the last Reset
+func (gpm *GoroutineProfileMerger) Stats() (*Stats, error) {
+	return gpm.written().stats(gpm.stringDedup)
+}
+
+// Stats returns statistics of x, raw profiles aren't deduplicated so no tables are reported
+func (x *MergedByteProfile) Stats() (*Stats, error) {
+	data, err := x.MarshalVT()
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal merged profile")
+	}
+
+	stats, err := newStats(data, x.ProtoReflect().Descriptor())
+	if err != nil {
+		return nil, err
+	}
+	stats.Entries = len(x.Profiles)
+	stats.EntrySamples = make([]uint64, len(x.Profiles))
+	for i, p := range x.Profiles {
+		stats.EntrySamples[i] = uint64(len(p))
+	}
+	return stats, nil
+}
+
+// newStats returns stats with sizes of data and of every field of message desc encoded in it
+func newStats(data []byte, desc protoreflect.MessageDescriptor) (*Stats, error) {
+	compressedSize, err := gzipSize(data)
+	if err != nil {
+		return nil, err
+	}
+	stats := &Stats{
+		Size:           len(data),
+		CompressedSize: compressedSize,
+	}
+
+	// records of repeated fields aren't necessarily adjacent, so they're gathered first
+	fields := make(map[protowire.Number][]byte)
+	for rest := data; len(rest) > 0; {
+		num, typ, n := protowire.ConsumeTag(rest)
+		if n < 0 {
+			return nil, errors.Wrap(protowire.ParseError(n), "parse tag")
+		}
+		m := protowire.ConsumeFieldValue(num, typ, rest[n:])
+		if m < 0 {
+			return nil, errors.Wrapf(protowire.ParseError(m), "parse field %d", num)
+		}
+		fields[num] = append(fields[num], rest[:n+m]...)
+		rest = rest[n+m:]
+	}
+
+	for num, fieldData := range fields {
+		fs := FieldStats{
+			Name:   "field" + strconv.Itoa(int(num)),
+			Number: int(num),
+			Size:   len(fieldData),
+		}
+		if fd := desc.Fields().ByNumber(num); fd != nil {
+			fs.Name = string(fd.Name())
+		}
+		if fs.CompressedSize, err = gzipSize(fieldData); err != nil {
+			return nil, err
+		}
+		stats.Fields = append(stats.Fields, fs)
+	}
+	sort.Slice(stats.Fields, func(i, j int) bool {
+		return stats.Fields[i].Number < stats.Fields[j].Number
+	})
+
+	return stats, nil
+}
+
+// gzipSize returns size of data compressed the way WriteCompressed does
+func gzipSize(data []byte) (int, error) {
+	var cw countingWriter
+	zw := gzip.NewWriter(&cw)
+	if _, err := zw.Write(data); err != nil {
+		return 0, errors.Wrap(err, "compress")
+	}
+	if err := zw.Close(); err != nil {
+		return 0, errors.Wrap(err, "compress")
+	}
+	return cw.n, nil
+}
+
+// countingWriter discards data written to it counting its size
+type countingWriter struct {
+	n int
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	cw.n += len(p)
+	return len(p), nil
+}
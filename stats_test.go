@@ -0,0 +1,110 @@
+package ppmerge
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProfileStats(t *testing.T) {
+	profiles := getProfilesVtProto(t, false, "hprof1", "hprof2", "hprof3", "hprof4")
+	profileMerger := NewProfileMerger()
+	mergedProfile := profileMerger.Merge(profiles...)
+
+	stats, err := profileMerger.Stats()
+	require.NoError(t, err)
+	require.Equal(t, 4, stats.Entries)
+	require.Equal(t, mergedProfile.NumSamples, stats.EntrySamples)
+
+	data, err := mergedProfile.MarshalDeterministic()
+	require.NoError(t, err)
+	require.Equal(t, len(data), stats.Size)
+	require.Positive(t, stats.CompressedSize)
+	require.Less(t, stats.CompressedSize, stats.Size)
+
+	fieldsSize := 0
+	for i, fs := range stats.Fields {
+		if i > 0 {
+			require.Less(t, stats.Fields[i-1].Number, fs.Number)
+		}
+		require.Positive(t, fs.CompressedSize)
+		fieldsSize += fs.Size
+	}
+	require.Equal(t, stats.Size, fieldsSize)
+	require.Equal(t, "sample_type", stats.Fields[0].Name)
+	require.Equal(t, "labels", stats.Fields[len(stats.Fields)-1].Name)
+
+	expectedLens := map[string]int{
+		"strings":   len(mergedProfile.StringTable),
+		"functions": len(mergedProfile.Functions),
+		"locations": len(mergedProfile.Locations),
+		"mappings":  len(mergedProfile.Mappings),
+	}
+	require.Len(t, stats.Tables, len(expectedLens))
+	for _, ts := range stats.Tables {
+		require.Equal(t, expectedLens[ts.Name], ts.Len, ts.Name)
+		require.Positive(t, ts.Hits, ts.Name)
+		require.Less(t, ts.Hits, ts.Lookups, ts.Name)
+		require.InDelta(t, 0.5, ts.HitRate(), 0.5)
+	}
+
+	// every lookup either hits or adds a value
+	locations := stats.Tables[2]
+	require.Equal(t, locations.Lookups-locations.Hits, uint64(len(mergedProfile.Locations)))
+
+	// parallel merge interns the same values
+	parallelMerger := NewProfileMerger()
+	parallelMerger.MergeParallel(2, profiles...)
+	parallelStats, err := parallelMerger.Stats()
+	require.NoError(t, err)
+	require.Equal(t, stats, parallelStats)
+
+	// decoded profiles don't know about lookups
+	decoded := new(MergedProfile)
+	require.NoError(t, decoded.UnmarshalVT(data))
+	decodedStats, err := decoded.Stats()
+	require.NoError(t, err)
+	require.Equal(t, stats.Fields, decodedStats.Fields)
+	for i, ts := range decodedStats.Tables {
+		require.Equal(t, stats.Tables[i].Len, ts.Len)
+		require.Zero(t, ts.Lookups)
+		require.Zero(t, ts.HitRate())
+	}
+
+	profileMerger.Reset()
+	profileMerger.Merge(profiles[0])
+	stats, err = profileMerger.Stats()
+	require.NoError(t, err)
+	require.Equal(t, 1, stats.Entries)
+	require.Less(t, stats.Tables[2].Lookups, locations.Lookups)
+}
+
+func TestGoroutineProfileStats(t *testing.T) {
+	goroutineMerger := NewGoroutineProfileMerger()
+	mergedProfile := goroutineMerger.Merge(getGoroutineProfiles(t, "parca_goroutine_debug_1_1", "parca_goroutine_debug_1_2")...)
+
+	stats, err := goroutineMerger.Stats()
+	require.NoError(t, err)
+	require.Equal(t, 2, stats.Entries)
+	require.Equal(t, mergedProfile.NumStacktraces, stats.EntrySamples)
+	require.Equal(t, "strings", stats.Tables[0].Name)
+	require.Equal(t, len(mergedProfile.StringTable), stats.Tables[0].Len)
+	require.Positive(t, stats.Tables[0].HitRate())
+	require.Equal(t, len(mergedProfile.Stacktraces), stats.Tables[1].Len)
+	// testdata is trimmed, so trimmed newlines are stored too
+	require.Len(t, stats.Fields, 5)
+}
+
+func TestByteProfileStats(t *testing.T) {
+	profiles := getDebugProfiles(t, "hprof1", "parca_goroutine_debug_1_1")
+	mergedProfile := NewByteProfileMerger().Merge(profiles...)
+
+	stats, err := mergedProfile.Stats()
+	require.NoError(t, err)
+	require.Equal(t, 2, stats.Entries)
+	require.Equal(t, []uint64{uint64(len(profiles[0])), uint64(len(profiles[1]))}, stats.EntrySamples)
+	require.Empty(t, stats.Tables)
+	require.Len(t, stats.Fields, 1)
+	require.Equal(t, "profiles", stats.Fields[0].Name)
+	require.Equal(t, stats.Size, stats.Fields[0].Size)
+}
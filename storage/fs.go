@@ -0,0 +1,137 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// FSStore stores archives in a local directory, one subdirectory per service
+type FSStore struct {
+	dir string
+}
+
+// NewFSStore returns FSStore rooted at dir
+func NewFSStore(dir string) *FSStore {
+	return &FSStore{
+		dir: dir,
+	}
+}
+
+func (fs *FSStore) Put(ctx context.Context, meta ArchiveMeta, r io.Reader) (ArchiveMeta, error) {
+	key, err := ArchiveKey(meta.Service, meta.Start, meta.End)
+	if err != nil {
+		return ArchiveMeta{}, err
+	}
+
+	path := fs.path(key)
+	if err = os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return ArchiveMeta{}, errors.Wrap(err, "create service directory")
+	}
+
+	// write to a temporary file first, so that readers never see partially written archives
+	file, err := os.CreateTemp(filepath.Dir(path), ".archive-*")
+	if err != nil {
+		return ArchiveMeta{}, errors.Wrap(err, "create archive")
+	}
+	defer os.Remove(file.Name())
+
+	size, err := io.Copy(file, r)
+	if err != nil {
+		file.Close()
+		return ArchiveMeta{}, errors.Wrap(err, "write archive")
+	}
+
+	if err = file.Close(); err != nil {
+		return ArchiveMeta{}, errors.Wrap(err, "write archive")
+	}
+
+	if err = os.Rename(file.Name(), path); err != nil {
+		return ArchiveMeta{}, errors.Wrap(err, "write archive")
+	}
+
+	meta.Key = key
+	meta.Size = size
+	return meta, nil
+}
+
+func (fs *FSStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	return fs.GetRange(ctx, key, 0, -1)
+}
+
+func (fs *FSStore) GetRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	if _, err := ParseArchiveKey(key); err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(fs.path(key))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNotFound
+		}
+		return nil, errors.Wrap(err, "open archive")
+	}
+
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, errors.Wrap(err, "stat archive")
+	}
+
+	length, err = checkRange(stat.Size(), offset, length)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return &sectionReadCloser{
+		SectionReader: io.NewSectionReader(file, offset, length),
+		Closer:        file,
+	}, nil
+}
+
+func (fs *FSStore) List(ctx context.Context, service string, start, end time.Time) ([]ArchiveMeta, error) {
+	if err := validateService(service); err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(filepath.Join(fs.dir, service))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrap(err, "list archives")
+	}
+
+	archives := make([]ArchiveMeta, 0, len(entries))
+	for _, entry := range entries {
+		meta, err := ParseArchiveKey(service + "/" + entry.Name())
+		if err != nil {
+			// not an archive, e.g. temporary file
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return nil, errors.Wrap(err, "list archives")
+		}
+		meta.Size = info.Size()
+
+		archives = append(archives, meta)
+	}
+
+	return filterArchives(archives, start, end), nil
+}
+
+func (fs *FSStore) path(key string) string {
+	return filepath.Join(fs.dir, filepath.FromSlash(key))
+}
+
+type sectionReadCloser struct {
+	*io.SectionReader
+	io.Closer
+}
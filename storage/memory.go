@@ -0,0 +1,90 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps archives in memory. It's mostly useful for tests.
+type MemoryStore struct {
+	mu       sync.RWMutex
+	archives map[string][]byte
+}
+
+// NewMemoryStore returns empty MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		archives: make(map[string][]byte),
+	}
+}
+
+func (ms *MemoryStore) Put(ctx context.Context, meta ArchiveMeta, r io.Reader) (ArchiveMeta, error) {
+	key, err := ArchiveKey(meta.Service, meta.Start, meta.End)
+	if err != nil {
+		return ArchiveMeta{}, err
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return ArchiveMeta{}, err
+	}
+
+	ms.mu.Lock()
+	ms.archives[key] = data
+	ms.mu.Unlock()
+
+	meta.Key = key
+	meta.Size = int64(len(data))
+	return meta, nil
+}
+
+func (ms *MemoryStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	return ms.GetRange(ctx, key, 0, -1)
+}
+
+func (ms *MemoryStore) GetRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	ms.mu.RLock()
+	data, ok := ms.archives[key]
+	ms.mu.RUnlock()
+
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	length, err := checkRange(int64(len(data)), offset, length)
+	if err != nil {
+		return nil, err
+	}
+
+	return io.NopCloser(bytes.NewReader(data[offset : offset+length])), nil
+}
+
+func (ms *MemoryStore) List(ctx context.Context, service string, start, end time.Time) ([]ArchiveMeta, error) {
+	if err := validateService(service); err != nil {
+		return nil, err
+	}
+
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	var archives []ArchiveMeta
+	for key, data := range ms.archives {
+		if !strings.HasPrefix(key, service+"/") {
+			continue
+		}
+
+		meta, err := ParseArchiveKey(key)
+		if err != nil {
+			continue
+		}
+		meta.Size = int64(len(data))
+
+		archives = append(archives, meta)
+	}
+
+	return filterArchives(archives, start, end), nil
+}
@@ -0,0 +1,248 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// S3Config configures S3Store
+type S3Config struct {
+	// Endpoint is the base URL of S3-compatible service, e.g. https://s3.eu-west-1.amazonaws.com
+	Endpoint string
+	// Bucket archives are stored in. Path-style addressing is used.
+	Bucket string
+	// Prefix is prepended to every archive key
+	Prefix string
+	// Region the bucket is located in
+	Region string
+
+	AccessKey string
+	SecretKey string
+
+	// Client is used to make requests to the service
+	Client *http.Client
+}
+
+// S3Store stores archives in a bucket of S3-compatible object storage
+type S3Store struct {
+	cfg      S3Config
+	endpoint *url.URL
+
+	now func() time.Time
+}
+
+// NewS3Store returns S3Store instance
+func NewS3Store(cfg S3Config) (*S3Store, error) {
+	endpoint, err := url.Parse(cfg.Endpoint)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse endpoint")
+	}
+	if endpoint.Scheme == "" || endpoint.Host == "" {
+		return nil, errors.Errorf("invalid endpoint %q", cfg.Endpoint)
+	}
+	if cfg.Bucket == "" {
+		return nil, errors.New("no bucket specified")
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	if cfg.Client == nil {
+		cfg.Client = http.DefaultClient
+	}
+
+	return &S3Store{
+		cfg:      cfg,
+		endpoint: endpoint,
+		now:      time.Now,
+	}, nil
+}
+
+func (s *S3Store) Put(ctx context.Context, meta ArchiveMeta, r io.Reader) (ArchiveMeta, error) {
+	key, err := ArchiveKey(meta.Service, meta.Start, meta.End)
+	if err != nil {
+		return ArchiveMeta{}, err
+	}
+
+	// body has to be hashed before being signed
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return ArchiveMeta{}, errors.Wrap(err, "read archive")
+	}
+
+	req, err := s.newRequest(ctx, http.MethodPut, s.cfg.Prefix+key, nil, data)
+	if err != nil {
+		return ArchiveMeta{}, err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+
+	resp, err := s.do(req)
+	if err != nil {
+		return ArchiveMeta{}, errors.Wrap(err, "put archive")
+	}
+	resp.Body.Close()
+
+	meta.Key = key
+	meta.Size = int64(len(data))
+	return meta, nil
+}
+
+func (s *S3Store) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	return s.GetRange(ctx, key, 0, -1)
+}
+
+func (s *S3Store) GetRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	if _, err := ParseArchiveKey(key); err != nil {
+		return nil, err
+	}
+	if offset < 0 {
+		return nil, errors.Errorf("negative offset %d", offset)
+	}
+	if length == 0 {
+		return io.NopCloser(bytes.NewReader(nil)), nil
+	}
+
+	req, err := s.newRequest(ctx, http.MethodGet, s.cfg.Prefix+key, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case length > 0 && length <= math.MaxInt64-offset:
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, offset+length-1))
+	case offset > 0:
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	resp, err := s.do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "get archive")
+	}
+
+	return resp.Body, nil
+}
+
+func (s *S3Store) List(ctx context.Context, service string, start, end time.Time) ([]ArchiveMeta, error) {
+	if err := validateService(service); err != nil {
+		return nil, err
+	}
+
+	var (
+		archives          []ArchiveMeta
+		continuationToken string
+	)
+	for {
+		query := url.Values{
+			"list-type": {"2"},
+			"prefix":    {s.cfg.Prefix + service + "/"},
+		}
+		if continuationToken != "" {
+			query.Set("continuation-token", continuationToken)
+		}
+
+		req, err := s.newRequest(ctx, http.MethodGet, "", query, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := s.do(req)
+		if err != nil {
+			return nil, errors.Wrap(err, "list archives")
+		}
+
+		var result listBucketResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, errors.Wrap(err, "decode archive list")
+		}
+
+		for _, obj := range result.Contents {
+			meta, err := ParseArchiveKey(strings.TrimPrefix(obj.Key, s.cfg.Prefix))
+			if err != nil {
+				continue
+			}
+			meta.Size = obj.Size
+			archives = append(archives, meta)
+		}
+
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			break
+		}
+		continuationToken = result.NextContinuationToken
+	}
+
+	return filterArchives(archives, start, end), nil
+}
+
+func (s *S3Store) newRequest(ctx context.Context, method, key string, query url.Values, body []byte) (*http.Request, error) {
+	u := *s.endpoint
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + s.cfg.Bucket
+	if key != "" {
+		u.Path += "/" + key
+	}
+	u.RawQuery = strings.ReplaceAll(query.Encode(), "+", "%20")
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, errors.Wrap(err, "create request")
+	}
+
+	payloadHash := emptyPayloadHash
+	if len(body) > 0 {
+		payloadHash = hashHex(body)
+	}
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	return req, nil
+}
+
+func (s *S3Store) do(req *http.Request) (*http.Response, error) {
+	signV4(req, s.cfg.AccessKey, s.cfg.SecretKey, s.cfg.Region, s.now())
+
+	resp, err := s.cfg.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		resp.Body.Close()
+		return nil, ErrNotFound
+	case resp.StatusCode >= 300:
+		var s3Err s3Error
+		_ = xml.NewDecoder(resp.Body).Decode(&s3Err)
+		resp.Body.Close()
+		if s3Err.Code == "NoSuchKey" {
+			return nil, ErrNotFound
+		}
+		return nil, errors.Errorf("unexpected status %s: %s %s", resp.Status, s3Err.Code, s3Err.Message)
+	}
+
+	return resp, nil
+}
+
+type listBucketResult struct {
+	Contents []struct {
+		Key  string `xml:"Key"`
+		Size int64  `xml:"Size"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+type s3Error struct {
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
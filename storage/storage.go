@@ -0,0 +1,160 @@
+// Package storage provides archive stores indexed by service and time.
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const archiveExt = ".pb.gz"
+
+var (
+	// ErrNotFound is returned when requested archive doesn't exist
+	ErrNotFound = errors.New("archive not found")
+
+	errInvalidService  = errors.New("invalid service name")
+	errInvalidKey      = errors.New("invalid archive key")
+	errInvalidInterval = errors.New("invalid archive time interval")
+)
+
+// ArchiveMeta describes a stored archive
+type ArchiveMeta struct {
+	// Service the profiles in archive belong to
+	Service string
+	// Start is the capture time of the first profile in archive
+	Start time.Time
+	// End is the capture time of the last profile in archive
+	End time.Time
+	// Key uniquely identifies archive in a store
+	Key string
+	// Size of archive in bytes
+	Size int64
+}
+
+// Contains reports whether t falls into [Start, End] interval of archive
+func (am ArchiveMeta) Contains(t time.Time) bool {
+	return !t.Before(am.Start) && !t.After(am.End)
+}
+
+// Store stores archives indexed by service and time
+type Store interface {
+	// Put stores archive read from r. Key and Size of the returned meta are filled in by store.
+	Put(ctx context.Context, meta ArchiveMeta, r io.Reader) (ArchiveMeta, error)
+	// Get returns the whole archive stored under key
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// GetRange returns length bytes of archive stored under key starting from offset.
+	// Negative length reads up to the end of archive.
+	GetRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error)
+	// List returns archives of service overlapping [start, end] ordered by start time.
+	// Zero start or end leaves the interval unbounded on that side.
+	List(ctx context.Context, service string, start, end time.Time) ([]ArchiveMeta, error)
+}
+
+// Find returns the archive of service containing profile captured at t
+func Find(ctx context.Context, store Store, service string, t time.Time) (ArchiveMeta, error) {
+	archives, err := store.List(ctx, service, t, t)
+	if err != nil {
+		return ArchiveMeta{}, err
+	}
+
+	if len(archives) == 0 {
+		return ArchiveMeta{}, ErrNotFound
+	}
+
+	// prefer the most recent archive if several overlap
+	return archives[len(archives)-1], nil
+}
+
+// ArchiveKey returns the key archive of service covering [start, end] is stored under.
+// Keys of the same service sort by start time, so start must be set, not earlier than 1970 and not after end.
+func ArchiveKey(service string, start, end time.Time) (string, error) {
+	if err := validateService(service); err != nil {
+		return "", err
+	}
+	if start.IsZero() || start.UnixNano() < 0 || end.Before(start) {
+		return "", errors.Wrapf(errInvalidInterval, "[%s, %s]", start, end)
+	}
+	return fmt.Sprintf("%s/%020d-%020d%s", service, start.UnixNano(), end.UnixNano(), archiveExt), nil
+}
+
+// ParseArchiveKey recovers service and time interval from archive key
+func ParseArchiveKey(key string) (ArchiveMeta, error) {
+	service, name := path.Split(key)
+	service = strings.TrimSuffix(service, "/")
+	if validateService(service) != nil {
+		return ArchiveMeta{}, errInvalidKey
+	}
+
+	name, ok := strings.CutSuffix(name, archiveExt)
+	if !ok {
+		return ArchiveMeta{}, errInvalidKey
+	}
+
+	startStr, endStr, ok := strings.Cut(name, "-")
+	if !ok {
+		return ArchiveMeta{}, errInvalidKey
+	}
+
+	start, err := strconv.ParseInt(startStr, 10, 64)
+	if err != nil {
+		return ArchiveMeta{}, errInvalidKey
+	}
+
+	end, err := strconv.ParseInt(endStr, 10, 64)
+	if err != nil || start < 0 || end < start {
+		return ArchiveMeta{}, errInvalidKey
+	}
+
+	return ArchiveMeta{
+		Service: service,
+		Start:   time.Unix(0, start),
+		End:     time.Unix(0, end),
+		Key:     key,
+	}, nil
+}
+
+func validateService(service string) error {
+	if service == "" || service == "." || service == ".." || strings.ContainsAny(service, "/\\") {
+		return errInvalidService
+	}
+	return nil
+}
+
+// filterArchives keeps archives overlapping [start, end] and sorts them by start time
+func filterArchives(archives []ArchiveMeta, start, end time.Time) []ArchiveMeta {
+	filtered := archives[:0]
+	for _, am := range archives {
+		if !start.IsZero() && am.End.Before(start) {
+			continue
+		}
+		if !end.IsZero() && am.Start.After(end) {
+			continue
+		}
+		filtered = append(filtered, am)
+	}
+
+	sort.Slice(filtered, func(i, j int) bool {
+		return filtered[i].Key < filtered[j].Key
+	})
+
+	return filtered
+}
+
+// checkRange validates range against archive size and returns the actual length of range
+func checkRange(size, offset, length int64) (int64, error) {
+	if offset < 0 || offset > size {
+		return 0, errors.Errorf("offset %d out of range [0, %d]", offset, size)
+	}
+	if length < 0 || length > size-offset {
+		length = size - offset
+	}
+	return length, nil
+}
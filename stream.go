@@ -0,0 +1,277 @@
+package ppmerge
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"slices"
+
+	"github.com/pkg/errors"
+	"github.com/threadedstream/ppmerge/profile"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// samplesFieldNumber is the number of samples field of MergedProfile
+const samplesFieldNumber = 2
+
+// ProfileIterator yields profiles one by one, Next returns io.EOF when there are no more profiles
+type ProfileIterator interface {
+	Next() (*profile.Profile, error)
+}
+
+// ProfileIteratorFunc is an adapter to use ordinary function as ProfileIterator
+type ProfileIteratorFunc func() (*profile.Profile, error)
+
+func (f ProfileIteratorFunc) Next() (*profile.Profile, error) {
+	return f()
+}
+
+// ChanIterator returns iterator yielding profiles received from ch until it's closed
+func ChanIterator(ch <-chan *profile.Profile) ProfileIterator {
+	return ProfileIteratorFunc(func() (*profile.Profile, error) {
+		p, ok := <-ch
+		if !ok {
+			return nil, io.EOF
+		}
+		return p, nil
+	})
+}
+
+// ReaderIterator returns iterator parsing profiles from rs, plain and gzipped ones are accepted
+func ReaderIterator(rs ...io.Reader) ProfileIterator {
+	return ProfileIteratorFunc(func() (*profile.Profile, error) {
+		if len(rs) == 0 {
+			return nil, io.EOF
+		}
+		r := rs[0]
+		rs = rs[1:]
+		return profile.ParseProfile(r)
+	})
+}
+
+// FileIterator returns iterator parsing profiles from files at paths. Every file is opened
+// only when its profile is requested.
+func FileIterator(paths ...string) ProfileIterator {
+	return ProfileIteratorFunc(func() (*profile.Profile, error) {
+		if len(paths) == 0 {
+			return nil, io.EOF
+		}
+		path := paths[0]
+		paths = paths[1:]
+
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+
+		p, err := profile.ParseProfile(file)
+		return p, errors.Wrapf(err, "parse %s", path)
+	})
+}
+
+// MergeStream merges profiles yielded by it into MergedProfile written to w. Every profile is
+// returned to the vtproto pool as soon as it's merged, so that only shared tables are kept in memory.
+func MergeStream(w io.Writer, it ProfileIterator, opts ...MergerOption) error {
+	sm := NewStreamMerger(w, opts...)
+	for {
+		p, err := it.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			sm.Release()
+			return errors.Wrapf(err, "read profile %d", sm.Len())
+		}
+
+		err = sm.Add(p)
+		p.ReturnToVTPool()
+		if err != nil {
+			sm.Release()
+			return err
+		}
+	}
+	return sm.Close()
+}
+
+// StreamMerger merges profiles into MergedProfile written to io.Writer incrementally. Samples
+// and labels of every added profile are written immediately, while functions, locations, mappings,
+// strings and per-entry metadata are kept until Close, so that memory usage is proportional
+// to the shared tables rather than to the total input.
+//
+// Output is a regular MergedProfile encoding, which can be read with UnmarshalVT. String and
+// table ids are assigned in a different order than by Merge, but every entry is unpacked
+// to the same profile. Wrap w with gzip.Writer to get the format of WriteCompressed.
+type StreamMerger struct {
+	pw  *ProfileMerger
+	w   *bufio.Writer
+	buf []byte
+
+	numProfiles int
+	numSamples  uint64
+	err         error
+
+	// values of samples of the last entry of every series, kept only if values are delta encoded
+	deltas deltaCoder
+	series string
+}
+
+// NewStreamMerger returns StreamMerger writing to w
+func NewStreamMerger(w io.Writer, opts ...MergerOption) *StreamMerger {
+	pw := NewProfileMerger(opts...)
+	pw.mergedProfile.Labels = nil
+	return &StreamMerger{
+		pw: pw,
+		w:  bufio.NewWriter(w),
+	}
+}
+
+// Len returns number of profiles added so far
+func (sm *StreamMerger) Len() int {
+	return sm.numProfiles
+}
+
+// Add merges p and writes its samples. p isn't referenced after Add returns, so it can be released.
+func (sm *StreamMerger) Add(p *profile.Profile) error {
+	if sm.err != nil {
+		return sm.err
+	}
+
+	pw := sm.pw
+	mp := pw.mergedProfile
+	if pw.deltaValues {
+		sm.series = profileSeries(p)
+	}
+	mp.NumFunctions = append(mp.NumFunctions, uint64(len(p.Function)))
+	mp.NumLocations = append(mp.NumLocations, uint64(len(p.Location)))
+	mp.NumSampleTypes = append(mp.NumSampleTypes, uint64(len(p.SampleType)))
+	mp.NumMappings = append(mp.NumMappings, uint64(len(p.Mapping)))
+
+	if pw.aggregateSamples {
+		// identical samples may come in any order, so samples of entry are written once it's merged
+		for _, s := range p.Sample {
+			sample := pw.asMergedSample(s, p)
+			var labels *profile.Labels
+			if len(s.Label) > 0 {
+				labels = pw.asMergedLabels(s.Label, p)
+			}
+			pw.putSample(sample, labels)
+		}
+		mp.NumSamples = append(mp.NumSamples, uint64(len(mp.Samples)))
+		for offset, sample := range mp.Samples {
+			if err := sm.writeSample(sample, mp.Labels[uint64(offset)]); err != nil {
+				return err
+			}
+		}
+		mp.Samples = mp.Samples[:0]
+		clear(mp.Labels)
+	} else {
+		mp.NumSamples = append(mp.NumSamples, uint64(len(p.Sample)))
+		for _, s := range p.Sample {
+			sample := pw.asMergedSample(s, p)
+			var labels *profile.Labels
+			if len(s.Label) > 0 {
+				labels = pw.asMergedLabels(s.Label, p)
+			}
+			if err := sm.writeSample(sample, labels); err != nil {
+				return err
+			}
+		}
+	}
+	pw.finishEntry()
+	if pw.deltaValues {
+		sm.deltas.finishEntry(sm.series)
+	}
+	sm.numProfiles++
+
+	for _, vt := range p.SampleType {
+		mp.SampleType = append(mp.SampleType,
+			int64(pw.putString(uint64(vt.Type), p)),
+			int64(pw.putString(uint64(vt.Unit), p)),
+		)
+	}
+	mp.TimesNanos = append(mp.TimesNanos, p.TimeNanos)
+	mp.DurationsNanos = append(mp.DurationsNanos, p.DurationNanos)
+	mp.Periods = append(mp.Periods, p.Period)
+	mp.PeriodTypes = append(mp.PeriodTypes,
+		int64(pw.putString(uint64(p.PeriodType.Type), p)),
+		int64(pw.putString(uint64(p.PeriodType.Unit), p)),
+	)
+
+	return nil
+}
+
+// writeSample writes sample having labels as the next sample of merged profile
+func (sm *StreamMerger) writeSample(sample *MergeSample, labels *profile.Labels) error {
+	if sm.pw.deltaValues {
+		sample = &MergeSample{
+			LocationId: sample.LocationId,
+			Value:      sm.deltas.code(sm.series, getSampleKey(sample, labels), sample.Value, false),
+		}
+	}
+	sm.buf = protowire.AppendTag(sm.buf[:0], samplesFieldNumber, protowire.BytesType)
+	sm.buf, sm.err = appendMessage(sm.buf, sample)
+	if sm.err == nil && labels != nil {
+		sm.buf, sm.err = appendLabelsEntry(sm.buf, sm.numSamples, labels)
+	}
+	if sm.err == nil {
+		_, sm.err = sm.w.Write(sm.buf)
+	}
+	if sm.err != nil {
+		return errors.Wrap(sm.err, "write sample")
+	}
+	sm.numSamples++
+	return nil
+}
+
+// Close writes shared tables and flushes output. StreamMerger must not be used afterwards.
+func (sm *StreamMerger) Close() error {
+	defer sm.Release()
+	if sm.err != nil {
+		return sm.err
+	}
+
+	// samples and labels were written already, so merged profile holds only tables and metadata
+	sm.pw.buildStringTable()
+	mp := sm.pw.mergedProfile
+	mp.DeltaValues = sm.pw.deltaValues
+	if sm.pw.dictionary != nil {
+		mp = mp.withoutDictionary(sm.pw.dictionary)
+	}
+	if sm.pw.frontCodeStrings {
+		mp.FrontCodedStrings = appendFrontCoded(nil, mp.StringTable)
+		mp.StringTable = nil
+	}
+	data, err := mp.MarshalVT()
+	if err != nil {
+		return errors.Wrap(err, "marshal tables")
+	}
+	if _, err = sm.w.Write(data); err != nil {
+		return errors.Wrap(err, "write tables")
+	}
+	return errors.Wrap(sm.w.Flush(), "flush")
+}
+
+// Release returns pooled state of merger without writing tables, it's called by Close
+func (sm *StreamMerger) Release() {
+	if sm.pw.mergedProfile != nil {
+		sm.pw.Release()
+	}
+	if sm.err == nil {
+		sm.err = errors.New("stream merger is closed")
+	}
+}
+
+// appendMessage appends length-prefixed encoding of m to data
+func appendMessage(data []byte, m interface {
+	SizeVT() int
+	MarshalToSizedBufferVT([]byte) (int, error)
+}) ([]byte, error) {
+	size := m.SizeVT()
+	data = protowire.AppendVarint(data, uint64(size))
+	data = slices.Grow(data, size)
+	if _, err := m.MarshalToSizedBufferVT(data[len(data) : len(data)+size]); err != nil {
+		return nil, err
+	}
+	return data[:len(data)+size], nil
+}
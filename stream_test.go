@@ -0,0 +1,100 @@
+package ppmerge
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/threadedstream/ppmerge/profile"
+)
+
+func TestMergeStream(t *testing.T) {
+	paths := []string{"hprof1", "labels.prof", "parca_cpu", "hprof2", "labels.prof", "parca_heap"}
+
+	expected := NewProfileMerger().Merge(getProfilesVtProto(t, false, paths...)...)
+	expectedUnpacker := NewProfileUnPacker(expected)
+
+	filePaths := make([]string, len(paths))
+	for i, path := range paths {
+		filePaths[i] = filepath.Join("testdata", path)
+	}
+
+	bb := bytes.NewBuffer(nil)
+	require.NoError(t, MergeStream(bb, FileIterator(filePaths...)))
+
+	actual := new(MergedProfile)
+	require.NoError(t, actual.UnmarshalVT(bb.Bytes()))
+	require.Equal(t, expected.NumSamples, actual.NumSamples)
+	require.Len(t, actual.Labels, len(expected.Labels))
+	require.Len(t, actual.Functions, len(expected.Functions))
+	require.Len(t, actual.Locations, len(expected.Locations))
+	require.Len(t, actual.StringTable, len(expected.StringTable))
+
+	actualUnpacker := NewProfileUnPacker(actual)
+	for idx := range paths {
+		expectedProfile, err := expectedUnpacker.Unpack(uint64(idx))
+		require.NoError(t, err)
+		actualProfile, err := actualUnpacker.Unpack(uint64(idx))
+		require.NoError(t, err)
+		require.Equal(t, expectedProfile.String(), actualProfile.String())
+	}
+}
+
+func TestStreamMergerCompressed(t *testing.T) {
+	ch := make(chan *profile.Profile)
+	go func() {
+		defer close(ch)
+		for _, p := range getProfilesVtProto(t, false, "hprof1", "hprof2", "hprof3") {
+			ch <- p
+		}
+	}()
+
+	bb := bytes.NewBuffer(nil)
+	zw := gzip.NewWriter(bb)
+	require.NoError(t, MergeStream(zw, ChanIterator(ch)))
+	require.NoError(t, zw.Close())
+
+	p, err := NewProfileUnPacker(nil).UnpackRaw(bb.Bytes(), 2)
+	require.NoError(t, err)
+	require.NoError(t, p.CheckValid())
+}
+
+func TestStreamMergerKeepsNoSamples(t *testing.T) {
+	data, err := os.ReadFile("testdata/parca_cpu")
+	require.NoError(t, err)
+
+	sm := NewStreamMerger(io.Discard)
+	for i := 0; i < 10; i++ {
+		p, err := ReaderIterator(bytes.NewReader(data)).Next()
+		require.NoError(t, err)
+		require.NoError(t, sm.Add(p))
+		p.ReturnToVTPool()
+	}
+	require.Equal(t, 10, sm.Len())
+	require.Empty(t, sm.pw.mergedProfile.Samples)
+	require.Empty(t, sm.pw.mergedProfile.Labels)
+	require.NoError(t, sm.Close())
+
+	require.Error(t, sm.Add(nil))
+}
+
+func TestMergeStreamError(t *testing.T) {
+	err := MergeStream(io.Discard, FileIterator("testdata/hprof1", "testdata/missing"))
+	require.ErrorContains(t, err, "read profile 1")
+}
+
+func BenchmarkMergeStream(b *testing.B) {
+	var paths []string
+	for i := 0; i < 25; i++ {
+		paths = append(paths, "testdata/hprof1", "testdata/hprof2", "testdata/hprof3", "testdata/hprof4")
+	}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		require.NoError(b, MergeStream(io.Discard, FileIterator(paths...)))
+	}
+}
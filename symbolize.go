@@ -0,0 +1,516 @@
+package ppmerge
+
+import (
+	"bytes"
+	"debug/dwarf"
+	"debug/elf"
+	"debug/gosym"
+	"encoding/hex"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// Symbolizer resolves functions and lines of unsymbolized locations of merged profiles using
+// local ELF binaries. Binaries are looked up in a directory by GNU or Go build ID of mapping,
+// or by base name of mapping file if no binary has its build ID.
+//
+// Go binaries are symbolized with their pclntab, others with DWARF and, if there is no debug
+// information, with symbol tables, which give function names only.
+type Symbolizer struct {
+	dir string
+
+	indexed   bool
+	byBuildID map[string]string
+	byName    map[string]string
+	binaries  map[string]*symbolBinary
+}
+
+// NewSymbolizer returns Symbolizer looking up binaries in dir and its subdirectories
+func NewSymbolizer(dir string) *Symbolizer {
+	return &Symbolizer{
+		dir:       dir,
+		byBuildID: make(map[string]string),
+		byName:    make(map[string]string),
+		binaries:  make(map[string]*symbolBinary),
+	}
+}
+
+// Symbolize resolves locations of x that have no lines and rewrites shared tables in place,
+// so that every entry referring to location is symbolized at once. Mappings whose binaries
+// aren't found are left as is. It returns number of symbolized locations.
+func (s *Symbolizer) Symbolize(x *MergedProfile) (int, error) {
+	if err := s.index(); err != nil {
+		return 0, err
+	}
+	if err := x.decodeTables(); err != nil {
+		return 0, err
+	}
+
+	binaries := make([]*symbolBinary, len(x.Mappings))
+	for i, m := range x.Mappings {
+		b, err := s.lookup(m, x.StringTable)
+		if err != nil {
+			return 0, err
+		}
+		binaries[i] = b
+	}
+
+	starts, ambiguous := x.normalizedStarts()
+	st := newSymbolTables(x)
+	symbolized := 0
+	for _, loc := range x.Locations {
+		if len(loc.Line) > 0 || loc.MappingId == 0 || loc.MappingId > uint64(len(x.Mappings)) {
+			continue
+		}
+		b := binaries[loc.MappingId-1]
+		if b == nil {
+			continue
+		}
+
+		m := x.Mappings[loc.MappingId-1]
+		memoryStart, addr := m.MemoryStart, loc.Address
+		if start, ok := starts[loc.MappingId]; ok {
+			// addresses of normalized mapping are relative to its start kept per entry,
+			// the start matters only to position dependent binaries
+			if b.typ == elf.ET_EXEC && ambiguous[loc.MappingId] {
+				continue
+			}
+			memoryStart, addr = start, addr+start
+		}
+		addr, ok := b.objAddr(memoryStart, m.FileOffset, addr)
+		if !ok {
+			continue
+		}
+		frames := b.resolve(addr)
+		if len(frames) == 0 {
+			continue
+		}
+
+		loc.Line = make([]*MergeLine, 0, len(frames))
+		for _, f := range frames {
+			loc.Line = append(loc.Line, &MergeLine{
+				FunctionId: st.putFunction(f),
+				Line:       f.line,
+			})
+			m.HasLineNumbers = m.HasLineNumbers || f.line > 0
+		}
+		m.HasFunctions = true
+		m.HasFilenames = true
+		symbolized++
+	}
+
+	return symbolized, nil
+}
+
+// normalizedStarts returns starts of mappings normalized by WithNormalizedAddresses by mapping id.
+// Mappings loaded at different starts by different entries are reported as ambiguous.
+func (x *MergedProfile) normalizedStarts() (map[uint64]uint64, map[uint64]bool) {
+	starts := make(map[uint64]uint64)
+	ambiguous := make(map[uint64]bool)
+	for i := 0; i+1 < len(x.MappingStarts); i += 2 {
+		mappingID, start := x.MappingStarts[i], x.MappingStarts[i+1]
+		if prev, ok := starts[mappingID]; ok && prev != start {
+			ambiguous[mappingID] = true
+		}
+		starts[mappingID] = start
+	}
+	return starts, ambiguous
+}
+
+// index finds build IDs and names of binaries in directory of s
+func (s *Symbolizer) index() error {
+	if s.indexed {
+		return nil
+	}
+
+	err := filepath.WalkDir(s.dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+
+		ef, err := elf.Open(p)
+		if err != nil {
+			// not an ELF file
+			return nil
+		}
+		defer ef.Close()
+
+		gnuID, goID := elfBuildIDs(ef)
+		for _, id := range []string{gnuID, goID} {
+			if _, ok := s.byBuildID[id]; id != "" && !ok {
+				s.byBuildID[id] = p
+			}
+		}
+		if _, ok := s.byName[d.Name()]; !ok {
+			s.byName[d.Name()] = p
+		}
+		return nil
+	})
+	if err != nil {
+		return errors.Wrap(err, "index binaries")
+	}
+
+	s.indexed = true
+	return nil
+}
+
+// lookup returns binary of mapping m, nil is returned if there is no such binary
+func (s *Symbolizer) lookup(m *MergeMapping, stringTable []string) (*symbolBinary, error) {
+	if m.BuildId < 0 || m.BuildId >= int64(len(stringTable)) || m.Filename < 0 || m.Filename >= int64(len(stringTable)) {
+		return nil, errors.Wrapf(indexOutOfRangeErr, "strings of mapping %d", m.Id)
+	}
+
+	p, ok := s.byBuildID[stringTable[m.BuildId]]
+	if !ok && m.Filename > 0 {
+		p, ok = s.byName[path.Base(stringTable[m.Filename])]
+	}
+	if !ok {
+		return nil, nil
+	}
+
+	if b, ok := s.binaries[p]; ok {
+		return b, nil
+	}
+	b, err := openSymbolBinary(p)
+	if err != nil {
+		return nil, errors.Wrapf(err, "open %s", p)
+	}
+	s.binaries[p] = b
+	return b, nil
+}
+
+// symbolTables interns functions and strings resolved by Symbolizer into tables of merged profile
+type symbolTables struct {
+	x         *MergedProfile
+	strings   map[string]int64
+	functions map[functionKey]uint64
+}
+
+func newSymbolTables(x *MergedProfile) *symbolTables {
+	st := &symbolTables{
+		x:         x,
+		strings:   make(map[string]int64, len(x.StringTable)),
+		functions: make(map[functionKey]uint64, len(x.Functions)),
+	}
+	for i, str := range x.StringTable {
+		if _, ok := st.strings[str]; !ok {
+			st.strings[str] = int64(i)
+		}
+	}
+	for _, f := range x.Functions {
+		st.functions[functionKey{
+			name:       f.Name,
+			systemName: f.SystemName,
+			filename:   f.Filename,
+			startLine:  f.StartLine,
+		}] = f.Id
+	}
+	return st
+}
+
+func (st *symbolTables) putString(str string) int64 {
+	if id, ok := st.strings[str]; ok {
+		return id
+	}
+	id := int64(len(st.x.StringTable))
+	st.x.StringTable = append(st.x.StringTable, str)
+	st.strings[str] = id
+	return id
+}
+
+func (st *symbolTables) putFunction(f symbolFrame) uint64 {
+	fn := &MergeFunction{
+		Name:       st.putString(f.function),
+		SystemName: st.putString(f.function),
+		Filename:   st.putString(f.filename),
+		StartLine:  f.startLine,
+	}
+	key := functionKey{
+		name:       fn.Name,
+		systemName: fn.SystemName,
+		filename:   fn.Filename,
+		startLine:  fn.StartLine,
+	}
+	if id, ok := st.functions[key]; ok {
+		return id
+	}
+
+	fn.Id = uint64(len(st.x.Functions) + 1)
+	st.functions[key] = fn.Id
+	st.x.Functions = append(st.x.Functions, fn)
+	return fn.Id
+}
+
+// symbolFrame is a function frame address is resolved to
+type symbolFrame struct {
+	function  string
+	filename  string
+	line      int64
+	startLine int64
+}
+
+// symbolBinary resolves addresses of a single binary
+type symbolBinary struct {
+	typ     elf.Type
+	loads   []elf.ProgHeader
+	resolve func(addr uint64) []symbolFrame
+}
+
+func openSymbolBinary(p string) (*symbolBinary, error) {
+	ef, err := elf.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer ef.Close()
+
+	b := &symbolBinary{
+		typ: ef.Type,
+	}
+	for _, prog := range ef.Progs {
+		if prog.Type == elf.PT_LOAD {
+			b.loads = append(b.loads, prog.ProgHeader)
+		}
+	}
+
+	if b.resolve, err = goResolver(ef); err != nil || b.resolve != nil {
+		return b, err
+	}
+	if b.resolve, err = dwarfResolver(ef); err != nil || b.resolve != nil {
+		return b, err
+	}
+	b.resolve, err = symtabResolver(ef)
+	return b, err
+}
+
+// objAddr translates runtime address of mapping starting at memoryStart and mapped from
+// fileOffset to virtual address of binary
+func (b *symbolBinary) objAddr(memoryStart, fileOffset, addr uint64) (uint64, bool) {
+	if b.typ == elf.ET_EXEC {
+		// position dependent binaries are loaded at their virtual addresses
+		return addr, true
+	}
+
+	if addr < memoryStart {
+		return 0, false
+	}
+	offset := addr - memoryStart + fileOffset
+	for _, prog := range b.loads {
+		if offset >= prog.Off && offset < prog.Off+prog.Filesz {
+			return offset - prog.Off + prog.Vaddr, true
+		}
+	}
+	return 0, false
+}
+
+// goResolver returns resolver using pclntab of Go binary, nil is returned for other binaries
+func goResolver(ef *elf.File) (func(uint64) []symbolFrame, error) {
+	pclntab, text := ef.Section(".gopclntab"), ef.Section(".text")
+	if pclntab == nil || text == nil {
+		return nil, nil
+	}
+
+	pclnData, err := pclntab.Data()
+	if err != nil {
+		return nil, errors.Wrap(err, "read pclntab")
+	}
+	var symtabData []byte
+	if symtab := ef.Section(".gosymtab"); symtab != nil {
+		if symtabData, err = symtab.Data(); err != nil {
+			return nil, errors.Wrap(err, "read symtab")
+		}
+	}
+
+	table, err := gosym.NewTable(symtabData, gosym.NewLineTable(pclnData, text.Addr))
+	if err != nil {
+		return nil, errors.Wrap(err, "parse pclntab")
+	}
+
+	return func(addr uint64) []symbolFrame {
+		file, line, fn := table.PCToLine(addr)
+		if fn == nil {
+			return nil
+		}
+		_, startLine, _ := table.PCToLine(fn.Entry)
+		return []symbolFrame{{
+			function:  fn.Name,
+			filename:  file,
+			line:      int64(line),
+			startLine: int64(startLine),
+		}}
+	}, nil
+}
+
+// dwarfFunction is the address range of a function described by DWARF
+type dwarfFunction struct {
+	name      string
+	low, high uint64
+	startLine int64
+	cu        *dwarf.Entry
+}
+
+// dwarfResolver returns resolver using DWARF, nil is returned if binary has no debug information
+func dwarfResolver(ef *elf.File) (func(uint64) []symbolFrame, error) {
+	if ef.Section(".debug_info") == nil && ef.Section(".zdebug_info") == nil {
+		return nil, nil
+	}
+	data, err := ef.DWARF()
+	if err != nil {
+		return nil, errors.Wrap(err, "read dwarf")
+	}
+
+	var (
+		functions []dwarfFunction
+		cu        *dwarf.Entry
+	)
+	r := data.Reader()
+	for {
+		entry, err := r.Next()
+		if err != nil {
+			return nil, errors.Wrap(err, "read dwarf")
+		}
+		if entry == nil {
+			break
+		}
+
+		switch entry.Tag {
+		case dwarf.TagCompileUnit:
+			cu = entry
+		case dwarf.TagSubprogram:
+			name, _ := entry.Val(dwarf.AttrName).(string)
+			if name == "" {
+				continue
+			}
+			startLine, _ := entry.Val(dwarf.AttrDeclLine).(int64)
+			ranges, err := data.Ranges(entry)
+			if err != nil {
+				continue
+			}
+			for _, rng := range ranges {
+				functions = append(functions, dwarfFunction{
+					name:      name,
+					low:       rng[0],
+					high:      rng[1],
+					startLine: startLine,
+					cu:        cu,
+				})
+			}
+		}
+	}
+	if len(functions) == 0 {
+		return nil, nil
+	}
+	sort.Slice(functions, func(i, j int) bool {
+		return functions[i].low < functions[j].low
+	})
+
+	lineReaders := make(map[dwarf.Offset]*dwarf.LineReader)
+	return func(addr uint64) []symbolFrame {
+		i := sort.Search(len(functions), func(i int) bool {
+			return functions[i].low > addr
+		}) - 1
+		if i < 0 || addr >= functions[i].high {
+			return nil
+		}
+		fn := functions[i]
+		frame := symbolFrame{
+			function:  fn.name,
+			startLine: fn.startLine,
+		}
+
+		lr, ok := lineReaders[fn.cu.Offset]
+		if !ok {
+			lr, _ = data.LineReader(fn.cu)
+			lineReaders[fn.cu.Offset] = lr
+		}
+		var le dwarf.LineEntry
+		if lr != nil && lr.SeekPC(addr, &le) == nil && le.File != nil {
+			frame.filename = le.File.Name
+			frame.line = int64(le.Line)
+		}
+		return []symbolFrame{frame}
+	}, nil
+}
+
+// symtabResolver returns resolver using symbol tables, only function names are known to it
+func symtabResolver(ef *elf.File) (func(uint64) []symbolFrame, error) {
+	symbols, err := ef.Symbols()
+	if err != nil && !errors.Is(err, elf.ErrNoSymbols) {
+		return nil, errors.Wrap(err, "read symbols")
+	}
+	dynamic, err := ef.DynamicSymbols()
+	if err != nil && !errors.Is(err, elf.ErrNoSymbols) {
+		return nil, errors.Wrap(err, "read dynamic symbols")
+	}
+
+	var functions []elf.Symbol
+	for _, sym := range append(symbols, dynamic...) {
+		if elf.ST_TYPE(sym.Info) == elf.STT_FUNC && sym.Value != 0 {
+			functions = append(functions, sym)
+		}
+	}
+	if len(functions) == 0 {
+		return nil, nil
+	}
+	sort.Slice(functions, func(i, j int) bool {
+		return functions[i].Value < functions[j].Value
+	})
+
+	return func(addr uint64) []symbolFrame {
+		i := sort.Search(len(functions), func(i int) bool {
+			return functions[i].Value > addr
+		}) - 1
+		if i < 0 || (functions[i].Size > 0 && addr >= functions[i].Value+functions[i].Size) {
+			return nil
+		}
+		return []symbolFrame{{function: functions[i].Name}}
+	}, nil
+}
+
+// elfBuildIDs returns GNU and Go build IDs of ef, GNU one is hex encoded
+func elfBuildIDs(ef *elf.File) (string, string) {
+	var gnuID, goID string
+	if desc := elfNote(ef, ".note.gnu.build-id", "GNU", 3); desc != nil {
+		gnuID = hex.EncodeToString(desc)
+	}
+	if desc := elfNote(ef, ".note.go.buildid", "Go", 4); desc != nil {
+		goID = string(desc)
+	}
+	return gnuID, goID
+}
+
+// elfNote returns description of note of type typ and name owner found in section
+func elfNote(ef *elf.File, section, owner string, typ uint32) []byte {
+	sect := ef.Section(section)
+	if sect == nil {
+		return nil
+	}
+	data, err := sect.Data()
+	if err != nil {
+		return nil
+	}
+
+	align := func(n uint32) uint32 {
+		return (n + 3) &^ 3
+	}
+	for len(data) >= 12 {
+		nameSize := ef.ByteOrder.Uint32(data[0:4])
+		descSize := ef.ByteOrder.Uint32(data[4:8])
+		noteType := ef.ByteOrder.Uint32(data[8:12])
+		data = data[12:]
+		if uint64(align(nameSize))+uint64(align(descSize)) > uint64(len(data)) {
+			return nil
+		}
+
+		name := bytes.TrimRight(data[:nameSize], "\x00")
+		desc := data[align(nameSize) : align(nameSize)+descSize]
+		if string(name) == owner && noteType == typ {
+			return desc
+		}
+		data = data[align(nameSize)+align(descSize):]
+	}
+	return nil
+}
@@ -0,0 +1,233 @@
+package ppmerge
+
+import (
+	"debug/elf"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	pprofile "github.com/google/pprof/profile"
+	"github.com/stretchr/testify/require"
+	"github.com/threadedstream/ppmerge/profile"
+)
+
+const symbolizeTestProgram = `package main
+
+//go:noinline
+func work(n int) int {
+	s := 0
+	for i := 0; i < n; i++ {
+		s += i
+	}
+	return s
+}
+
+func main() {
+	println(work(10))
+}
+`
+
+// buildTestBinary builds symbolizeTestProgram into dir/name
+func buildTestBinary(t *testing.T, dir, name string, args ...string) string {
+	if testing.Short() {
+		t.Skip("building binaries is skipped in short mode")
+	}
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go toolchain isn't available")
+	}
+
+	src := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(src, "go.mod"), []byte("module app\n\ngo 1.21\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(src, "main.go"), []byte(symbolizeTestProgram), 0644))
+
+	out := filepath.Join(dir, name)
+	cmd := exec.Command(goBin, append(append([]string{"build", "-o", out}, args...), ".")...)
+	cmd.Dir = src
+	cmd.Env = append(os.Environ(), "CGO_ENABLED=0", "GOFLAGS=")
+	output, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(output))
+	return out
+}
+
+// symbolAddr returns address of function symbol name of binary at path
+func symbolAddr(t *testing.T, path, name string) (uint64, *elf.File) {
+	ef, err := elf.Open(path)
+	require.NoError(t, err)
+	t.Cleanup(func() { ef.Close() })
+
+	symbols, err := ef.Symbols()
+	require.NoError(t, err)
+	for _, sym := range symbols {
+		if sym.Name == name {
+			return sym.Value, ef
+		}
+	}
+	t.Fatalf("no symbol %s in %s", name, path)
+	return 0, nil
+}
+
+// unsymbolizedProfile returns profile with a single sample of addrs, like the ones of stripped processes
+func unsymbolizedProfile(t *testing.T, mapping *pprofile.Mapping, addrs ...uint64) *profile.Profile {
+	src := &pprofile.Profile{
+		SampleType: []*pprofile.ValueType{{Type: "samples", Unit: "count"}},
+		PeriodType: &pprofile.ValueType{Type: "cpu", Unit: "nanoseconds"},
+		Mapping:    []*pprofile.Mapping{mapping},
+	}
+	sample := &pprofile.Sample{Value: []int64{1}}
+	for i, addr := range addrs {
+		loc := &pprofile.Location{ID: uint64(i + 1), Mapping: mapping, Address: addr}
+		src.Location = append(src.Location, loc)
+		sample.Location = append(sample.Location, loc)
+	}
+	src.Sample = []*pprofile.Sample{sample}
+	require.NoError(t, src.CheckValid())
+
+	p := new(profile.Profile)
+	p.From(src)
+	return p
+}
+
+func TestSymbolize(t *testing.T) {
+	dir := t.TempDir()
+	binPath := buildTestBinary(t, dir, "app")
+
+	workAddr, ef := symbolAddr(t, binPath, "main.work")
+	mainAddr, _ := symbolAddr(t, binPath, "main.main")
+	_, goBuildID := elfBuildIDs(ef)
+	require.NotEmpty(t, goBuildID)
+
+	mapping := &pprofile.Mapping{ID: 1, Start: 0x400000, Limit: 0x10000000, File: "/srv/bin/app", BuildID: goBuildID}
+	// the same binary found by name, with an address the binary doesn't have
+	renamed := &pprofile.Mapping{ID: 1, Start: 0x400000, Limit: 0x10000000, File: "/opt/app"}
+
+	profiles := []*profile.Profile{
+		unsymbolizedProfile(t, mapping, workAddr+4, mainAddr+8),
+		unsymbolizedProfile(t, mapping, mainAddr+8),
+		unsymbolizedProfile(t, renamed, workAddr+4, 0x10),
+	}
+	mergedProfile := NewProfileMerger().Merge(profiles...)
+	require.Len(t, mergedProfile.Locations, 4)
+
+	symbolized, err := NewSymbolizer(dir).Symbolize(mergedProfile)
+	require.NoError(t, err)
+	require.Equal(t, 3, symbolized)
+
+	unpacker := NewProfileUnPacker(mergedProfile)
+	for idx, expected := range [][]string{{"main.work", "main.main"}, {"main.main"}, {"main.work", ""}} {
+		p, err := unpacker.Unpack(uint64(idx))
+		require.NoError(t, err)
+		require.NoError(t, p.CheckValid())
+
+		var names []string
+		for _, loc := range p.Sample[0].Location {
+			if len(loc.Line) == 0 {
+				names = append(names, "")
+				continue
+			}
+			require.Len(t, loc.Line, 1)
+			line := loc.Line[0]
+			names = append(names, line.Function.Name)
+			require.True(t, strings.HasSuffix(line.Function.Filename, "main.go"))
+			require.Positive(t, line.Line)
+			require.LessOrEqual(t, line.Function.StartLine, line.Line)
+		}
+		require.Equal(t, expected, names)
+		require.True(t, p.Mapping[0].HasFunctions)
+		require.True(t, p.Mapping[0].HasLineNumbers)
+	}
+
+	// functions are shared by entries
+	require.Len(t, mergedProfile.Functions, 2)
+
+	// symbolized locations are left as is
+	symbolized, err = NewSymbolizer(dir).Symbolize(mergedProfile)
+	require.NoError(t, err)
+	require.Zero(t, symbolized)
+
+	// addresses of normalized mappings are relative to their starts
+	normalized := NewProfileMerger(WithNormalizedAddresses()).Merge(profiles[:2]...)
+	require.Zero(t, normalized.Mappings[0].MemoryStart)
+	symbolized, err = NewSymbolizer(dir).Symbolize(normalized)
+	require.NoError(t, err)
+	require.Equal(t, 2, symbolized)
+	p, err := NewProfileUnPacker(normalized).Unpack(0)
+	require.NoError(t, err)
+	require.Equal(t, "main.work", p.Sample[0].Location[0].Line[0].Function.Name)
+	require.Equal(t, workAddr+4, p.Sample[0].Location[0].Address)
+
+	// position dependent binary can't be loaded at different starts, so such mappings are skipped
+	moved := &pprofile.Mapping{ID: 1, Start: 0x500000, Limit: 0x10100000, File: "/srv/bin/app", BuildID: goBuildID}
+	normalized = NewProfileMerger(WithNormalizedAddresses()).Merge(profiles[0], unsymbolizedProfile(t, moved, workAddr+4+0x100000))
+	require.Len(t, normalized.Locations, 2)
+	symbolized, err = NewSymbolizer(dir).Symbolize(normalized)
+	require.NoError(t, err)
+	require.Zero(t, symbolized)
+
+	// unknown binaries are skipped
+	other := NewProfileMerger().Merge(profiles[0])
+	symbolized, err = NewSymbolizer(t.TempDir()).Symbolize(other)
+	require.NoError(t, err)
+	require.Zero(t, symbolized)
+	// malformed archive referring to missing strings is rejected
+	other.Mappings[0].Filename = int64(len(other.StringTable))
+	_, err = NewSymbolizer(dir).Symbolize(other)
+	require.ErrorIs(t, err, indexOutOfRangeErr)
+	other.Mappings[0].Filename, other.Mappings[0].BuildId = 0, -1
+	_, err = NewSymbolizer(dir).Symbolize(other)
+	require.ErrorIs(t, err, indexOutOfRangeErr)
+}
+
+func TestSymbolizePIE(t *testing.T) {
+	dir := t.TempDir()
+	binPath := buildTestBinary(t, dir, "app", "-buildmode=pie")
+
+	workAddr, ef := symbolAddr(t, binPath, "main.work")
+	require.Equal(t, elf.ET_DYN, ef.Type)
+
+	// the first segment is mapped at load address, addresses of binary are relative to its vaddr
+	const loadAddr = 0x555555554000
+	var first elf.ProgHeader
+	for _, prog := range ef.Progs {
+		if prog.Type == elf.PT_LOAD {
+			first = prog.ProgHeader
+			break
+		}
+	}
+	runtimeAddr := loadAddr + workAddr + 4 - first.Vaddr + first.Off
+	mapping := &pprofile.Mapping{ID: 1, Start: loadAddr, Limit: loadAddr + 0x10000000, File: "/srv/bin/app"}
+	mergedProfile := NewProfileMerger().Merge(unsymbolizedProfile(t, mapping, runtimeAddr))
+
+	symbolized, err := NewSymbolizer(dir).Symbolize(mergedProfile)
+	require.NoError(t, err)
+	require.Equal(t, 1, symbolized)
+
+	p, err := NewProfileUnPacker(mergedProfile).Unpack(0)
+	require.NoError(t, err)
+	require.Equal(t, "main.work", p.Sample[0].Location[0].Line[0].Function.Name)
+	require.Equal(t, runtimeAddr, p.Sample[0].Location[0].Address)
+}
+
+func TestSymbolizeResolvers(t *testing.T) {
+	binPath := buildTestBinary(t, t.TempDir(), "app")
+	workAddr, ef := symbolAddr(t, binPath, "main.work")
+
+	dwarfResolve, err := dwarfResolver(ef)
+	require.NoError(t, err)
+	require.NotNil(t, dwarfResolve)
+	frames := dwarfResolve(workAddr + 4)
+	require.Len(t, frames, 1)
+	require.Equal(t, "main.work", frames[0].function)
+	require.True(t, strings.HasSuffix(frames[0].filename, "main.go"))
+	require.Equal(t, int64(4), frames[0].startLine)
+	require.Positive(t, frames[0].line)
+	require.Empty(t, dwarfResolve(0x10))
+
+	symtabResolve, err := symtabResolver(ef)
+	require.NoError(t, err)
+	frames = symtabResolve(workAddr + 4)
+	require.Equal(t, []symbolFrame{{function: "main.work"}}, frames)
+	require.Empty(t, symtabResolve(0x10))
+}